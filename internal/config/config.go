@@ -0,0 +1,317 @@
+// Package config reads jj-github settings from the [jj-github] table of the
+// Jujutsu config, so they can be set per user or per repository:
+//
+//	jj config set --user jj-github.draft-keyword "draft"
+//	jj config set --repo jj-github.push-remote "fork"
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/prbody"
+)
+
+// Table is the name of the jj config table holding jj-github settings.
+const Table = "jj-github"
+
+// Config holds jj-github settings.
+type Config struct {
+	// PushRemote is the remote branches are pushed to, falling back to jj's
+	// git.push. Empty means origin, like jj.
+	PushRemote string
+	// PRRemote is the remote of the repository pull requests are opened
+	// against. Empty means the push remote.
+	PRRemote string
+	// Hosts lists GitHub Enterprise hosts accepted in remote URLs.
+	Hosts []string
+	// DefaultRevset is the revset submitted when none is given.
+	DefaultRevset string
+	// DraftKeyword marks a pull request as draft when found in the title.
+	// Empty disables draft detection.
+	DraftKeyword string
+	// Concurrency limits parallel GitHub API requests.
+	Concurrency int
+	// CommentMarker identifies the stack comment managed by jj-github.
+	CommentMarker string
+	// CommentFooter is appended to the stack comment.
+	CommentFooter string
+	// MergeMethod is how land merges pull requests.
+	MergeMethod github.MergeMethod
+	// Reviewers are requested on every pull request, as user logins or
+	// "org/team".
+	Reviewers []string
+	// Assignees are assigned to every pull request.
+	Assignees []string
+	// Labels are added to every pull request.
+	Labels []string
+	// Template names the pull request template to use when the repository
+	// has several in a PULL_REQUEST_TEMPLATE directory.
+	Template string
+	// TemplatePlacement is where the description goes in a template without
+	// a placeholder for it.
+	TemplatePlacement prbody.Placement
+	// StackLocation is where submit lists the pull requests of the stack.
+	StackLocation StackLocation
+	// MergeParent chooses the base of a revision that merges several
+	// revisions of the stack.
+	MergeParent MergeParentPolicy
+	// ForeignCommits is what submit does with commits pushed to a PR branch
+	// from outside jj, such as suggestions committed on GitHub.
+	ForeignCommits ForeignCommitPolicy
+	// DeleteBranches is whether sync deletes the branches and bookmarks of
+	// merged or closed pull requests.
+	DeleteBranches DeleteBranchPolicy
+}
+
+// DeleteBranchPolicy decides whether sync deletes the branches of merged or
+// closed pull requests.
+type DeleteBranchPolicy string
+
+const (
+	// DeleteBranchesAsk asks in the interactive view, and keeps the branches
+	// otherwise.
+	DeleteBranchesAsk DeleteBranchPolicy = "ask"
+	// DeleteBranchesAlways deletes them without asking.
+	DeleteBranchesAlways DeleteBranchPolicy = "always"
+	// DeleteBranchesNever keeps them, without looking for them.
+	DeleteBranchesNever DeleteBranchPolicy = "never"
+)
+
+// ForeignCommitPolicy handles commits on a PR branch that weren't pushed by
+// jj-github.
+type ForeignCommitPolicy string
+
+const (
+	// ForeignAsk asks what to do in the interactive view, and fails otherwise.
+	ForeignAsk ForeignCommitPolicy = "ask"
+	// ForeignImport squashes the commits into the revision before pushing.
+	ForeignImport ForeignCommitPolicy = "import"
+	// ForeignOverwrite pushes over the commits, discarding them.
+	ForeignOverwrite ForeignCommitPolicy = "overwrite"
+	// ForeignSkip leaves the branch and its pull request alone.
+	ForeignSkip ForeignCommitPolicy = "skip"
+)
+
+// MergeParentPolicy chooses which parent of a merge revision its pull request
+// is based on.
+type MergeParentPolicy string
+
+const (
+	// MergeParentRefuse makes submit fail on merge revisions.
+	MergeParentRefuse MergeParentPolicy = "refuse"
+	// MergeParentFirst bases the pull request on the first parent.
+	MergeParentFirst MergeParentPolicy = "first"
+	// MergeParentOpenPR bases the pull request on the first parent that
+	// already has an open pull request, or the first parent if none has.
+	MergeParentOpenPR MergeParentPolicy = "open-pr"
+)
+
+// StackLocation is where submit lists the pull requests of a stack.
+type StackLocation string
+
+const (
+	// StackInComment keeps the list in a comment on each pull request.
+	StackInComment StackLocation = "comment"
+	// StackInBody keeps the list in a section of each pull request body.
+	StackInBody StackLocation = "body"
+)
+
+// Default returns the settings used when nothing is configured.
+func Default() Config {
+	return Config{
+		DefaultRevset:     "@",
+		DraftKeyword:      "wip",
+		Concurrency:       8,
+		CommentMarker:     "<!-- managed-by: jj-github -->",
+		CommentFooter:     "*Stack managed with [jj-github](https://github.com/cbrewster/jj-github)*",
+		MergeMethod:       github.MergeMethodSquash,
+		TemplatePlacement: prbody.PlacementTop,
+		StackLocation:     StackInComment,
+		MergeParent:       MergeParentRefuse,
+		ForeignCommits:    ForeignAsk,
+		DeleteBranches:    DeleteBranchesAsk,
+	}
+}
+
+// Load reads the effective settings from the jj config, falling back to
+// Default for anything that is not set.
+func Load(jjClient *jj.Client) (Config, error) {
+	var values struct {
+		Settings settings `toml:"jj-github"`
+	}
+	if err := jjClient.ListConfig(Table, &values); err != nil {
+		return Config{}, err
+	}
+	cfg, err := parse(values.Settings)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// Without a remote of its own, push where jj pushes, so branches are
+	// looked up on the remote they were pushed to
+	if cfg.PushRemote == "" {
+		var git struct {
+			Git struct {
+				Push string `toml:"push"`
+			} `toml:"git"`
+		}
+		if err := jjClient.ListConfig("git.push", &git); err != nil {
+			return Config{}, err
+		}
+		cfg.PushRemote = git.Git.Push
+	}
+	return cfg, nil
+}
+
+// DefaultRemote is the remote jj pushes to when git.push isn't set.
+const DefaultRemote = "origin"
+
+// PushRemoteOrDefault returns the remote branches are pushed to.
+func (c Config) PushRemoteOrDefault() string {
+	if c.PushRemote != "" {
+		return c.PushRemote
+	}
+	return DefaultRemote
+}
+
+// PRRemoteOrDefault returns the remote pull requests are opened against.
+func (c Config) PRRemoteOrDefault() string {
+	if c.PRRemote != "" {
+		return c.PRRemote
+	}
+	return c.PushRemoteOrDefault()
+}
+
+// FetchRemotes returns the distinct remotes that need fetching, or nil to
+// let jj fetch from its default remotes when none are configured.
+func (c Config) FetchRemotes() []string {
+	if c.PushRemote == "" && c.PRRemote == "" {
+		return nil
+	}
+	remotes := []string{c.PushRemoteOrDefault()}
+	if pr := c.PRRemoteOrDefault(); pr != remotes[0] {
+		remotes = append(remotes, pr)
+	}
+	return remotes
+}
+
+// settings mirrors the [jj-github] config table. Settings that aren't set are
+// left nil, and unknown keys are ignored so newer configs keep working with
+// older binaries.
+type settings struct {
+	PushRemote        *string   `toml:"push-remote"`
+	PRRemote          *string   `toml:"pr-remote"`
+	Hosts             *[]string `toml:"hosts"`
+	Revset            *string   `toml:"revset"`
+	DraftKeyword      *string   `toml:"draft-keyword"`
+	Concurrency       *int      `toml:"concurrency"`
+	CommentMarker     *string   `toml:"comment-marker"`
+	CommentFooter     *string   `toml:"comment-footer"`
+	MergeMethod       *string   `toml:"merge-method"`
+	Reviewers         *[]string `toml:"reviewers"`
+	Assignees         *[]string `toml:"assignees"`
+	Labels            *[]string `toml:"labels"`
+	Template          *string   `toml:"pr-template"`
+	TemplatePlacement *string   `toml:"template-placement"`
+	StackLocation     *string   `toml:"stack-location"`
+	MergeParent       *string   `toml:"merge-parent"`
+	ForeignCommits    *string   `toml:"foreign-commits"`
+	DeleteBranches    *string   `toml:"delete-branches"`
+}
+
+// parse applies the settings that are set on top of Default.
+func parse(s settings) (Config, error) {
+	cfg := Default()
+
+	set(&cfg.PushRemote, s.PushRemote)
+	set(&cfg.PRRemote, s.PRRemote)
+	set(&cfg.Hosts, s.Hosts)
+	set(&cfg.DefaultRevset, s.Revset)
+	set(&cfg.DraftKeyword, s.DraftKeyword)
+	set(&cfg.CommentMarker, s.CommentMarker)
+	set(&cfg.CommentFooter, s.CommentFooter)
+	set(&cfg.Reviewers, s.Reviewers)
+	set(&cfg.Assignees, s.Assignees)
+	set(&cfg.Labels, s.Labels)
+	set(&cfg.Template, s.Template)
+
+	if s.Concurrency != nil {
+		if *s.Concurrency < 1 {
+			return Config{}, fmt.Errorf("config %s.concurrency: must be at least 1", Table)
+		}
+		cfg.Concurrency = *s.Concurrency
+	}
+
+	err := errors.Join(
+		setParsed(&cfg.MergeMethod, "merge-method", s.MergeMethod, github.ParseMergeMethod),
+		setParsed(&cfg.TemplatePlacement, "template-placement", s.TemplatePlacement, prbody.ParsePlacement),
+		setParsed(&cfg.StackLocation, "stack-location", s.StackLocation, parseStackLocation),
+		setParsed(&cfg.MergeParent, "merge-parent", s.MergeParent, parseMergeParentPolicy),
+		setParsed(&cfg.ForeignCommits, "foreign-commits", s.ForeignCommits, parseForeignCommitPolicy),
+		setParsed(&cfg.DeleteBranches, "delete-branches", s.DeleteBranches, parseDeleteBranchPolicy),
+	)
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// set overwrites dst with the setting, if it is set.
+func set[T any](dst *T, setting *T) {
+	if setting != nil {
+		*dst = *setting
+	}
+}
+
+// setParsed overwrites dst with the parsed setting, if it is set.
+func setParsed[T any](dst *T, key string, setting *string, parse func(string) (T, error)) error {
+	if setting == nil {
+		return nil
+	}
+	v, err := parse(*setting)
+	if err != nil {
+		return fmt.Errorf("config %s.%s: %w", Table, key, err)
+	}
+	*dst = v
+	return nil
+}
+
+func parseStackLocation(s string) (StackLocation, error) {
+	switch l := StackLocation(s); l {
+	case StackInComment, StackInBody:
+		return l, nil
+	default:
+		return "", fmt.Errorf("unknown stack location %q, expected comment or body", s)
+	}
+}
+
+func parseMergeParentPolicy(s string) (MergeParentPolicy, error) {
+	switch p := MergeParentPolicy(s); p {
+	case MergeParentRefuse, MergeParentFirst, MergeParentOpenPR:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown merge parent policy %q, expected refuse, first or open-pr", s)
+	}
+}
+
+func parseForeignCommitPolicy(s string) (ForeignCommitPolicy, error) {
+	switch p := ForeignCommitPolicy(s); p {
+	case ForeignAsk, ForeignImport, ForeignOverwrite, ForeignSkip:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown foreign commit policy %q, expected ask, import, overwrite or skip", s)
+	}
+}
+
+func parseDeleteBranchPolicy(s string) (DeleteBranchPolicy, error) {
+	switch p := DeleteBranchPolicy(s); p {
+	case DeleteBranchesAsk, DeleteBranchesAlways, DeleteBranchesNever:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown delete branch policy %q, expected ask, always or never", s)
+	}
+}
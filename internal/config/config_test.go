@@ -0,0 +1,161 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/prbody"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Output   string
+		GitPush  string // Output of listing git.push, if it is looked up
+		Expected func(*Config)
+	}{
+		{
+			Name:     "nothing configured",
+			Output:   "",
+			Expected: func(*Config) {},
+		},
+		{
+			Name: "all settings",
+			Output: `jj-github.push-remote = "fork"
+jj-github.pr-remote = 'upstream'
+jj-github.hosts = ["github.example.com", "ghe.internal"]
+jj-github.revset = "@-"
+jj-github.draft-keyword = "[draft]"
+jj-github.concurrency = 4
+jj-github.comment-marker = "<!-- stack -->"
+jj-github.comment-footer = """
+Managed by \"jj-github\"
+See the docs"""
+jj-github.merge-method = "rebase"
+jj-github.reviewers = ["alice", "org/team"]
+jj-github.assignees = ["bob"]
+jj-github.labels = ["stacked"]
+jj-github.pr-template = "feature"
+jj-github.template-placement = "bottom"
+jj-github.stack-location = "body"
+jj-github.merge-parent = "open-pr"
+jj-github.foreign-commits = "skip"
+jj-github.delete-branches = "always"
+`,
+			Expected: func(c *Config) {
+				c.PushRemote = "fork"
+				c.PRRemote = "upstream"
+				c.Hosts = []string{"github.example.com", "ghe.internal"}
+				c.DefaultRevset = "@-"
+				c.DraftKeyword = "[draft]"
+				c.Concurrency = 4
+				c.CommentMarker = "<!-- stack -->"
+				c.CommentFooter = "Managed by \"jj-github\"\nSee the docs"
+				c.MergeMethod = github.MergeMethodRebase
+				c.Reviewers = []string{"alice", "org/team"}
+				c.Assignees = []string{"bob"}
+				c.Labels = []string{"stacked"}
+				c.Template = "feature"
+				c.TemplatePlacement = prbody.PlacementBottom
+				c.StackLocation = StackInBody
+				c.MergeParent = MergeParentOpenPR
+				c.ForeignCommits = ForeignSkip
+				c.DeleteBranches = DeleteBranchesAlways
+			},
+		},
+		{
+			Name:   "disable draft detection",
+			Output: "jj-github.draft-keyword = \"\"\n",
+			Expected: func(c *Config) {
+				c.DraftKeyword = ""
+			},
+		},
+		{
+			Name: "multi-line arrays and escapes",
+			Output: `jj-github.push-remote = "fork"
+jj-github.reviewers = [
+  "alice", # lead
+  'org/team',
+]
+jj-github.draft-keyword = "\u2728"
+`,
+			Expected: func(c *Config) {
+				c.PushRemote = "fork"
+				c.Reviewers = []string{"alice", "org/team"}
+				c.DraftKeyword = "✨"
+			},
+		},
+		{
+			Name:    "push remote from git.push",
+			GitPush: "git.push = \"fork\"\n",
+			Expected: func(c *Config) {
+				c.PushRemote = "fork"
+			},
+		},
+		{
+			Name:     "unknown keys are ignored",
+			Output:   "jj-github.from-the-future = true\n",
+			Expected: func(*Config) {},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := jjtest.NewRunner().Expect(tc.Output, "config", "list", "jj-github")
+			if !strings.Contains(tc.Output, "push-remote") {
+				r.Expect(tc.GitPush, "config", "list", "git.push")
+			}
+
+			cfg, err := Load(jj.NewClientWithRunner(r))
+			require.NoError(t, err)
+			assert.Empty(t, r.Unused())
+
+			expected := Default()
+			tc.Expected(&expected)
+			assert.Equal(t, expected, cfg)
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		Name   string
+		Output string
+	}{
+		{Name: "string as int", Output: "jj-github.concurrency = \"many\"\n"},
+		{Name: "zero concurrency", Output: "jj-github.concurrency = 0\n"},
+		{Name: "int as string", Output: "jj-github.push-remote = 1\n"},
+		{Name: "string as array", Output: "jj-github.hosts = \"github.example.com\"\n"},
+		{Name: "unknown merge method", Output: "jj-github.merge-method = \"octopus\"\n"},
+		{Name: "unknown foreign commit policy", Output: "jj-github.foreign-commits = \"merge\"\n"},
+		{Name: "unknown delete branch policy", Output: "jj-github.delete-branches = \"sometimes\"\n"},
+		{Name: "unknown merge parent policy", Output: "jj-github.merge-parent = \"last\"\n"},
+		{Name: "unknown stack location", Output: "jj-github.stack-location = \"sidebar\"\n"},
+		{Name: "unknown template placement", Output: "jj-github.template-placement = \"middle\"\n"},
+		{Name: "unterminated multi-line", Output: "jj-github.comment-footer = '''\nfooter\n"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := jjtest.NewRunner().Expect(tc.Output, "config", "list", "jj-github")
+
+			_, err := Load(jj.NewClientWithRunner(r))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestFetchRemotes(t *testing.T) {
+	cfg := Default()
+	assert.Nil(t, cfg.FetchRemotes(), "jj's defaults apply")
+
+	cfg.PRRemote = "upstream"
+	assert.Equal(t, []string{"origin", "upstream"}, cfg.FetchRemotes())
+
+	cfg.PushRemote = "fork"
+	assert.Equal(t, []string{"fork", "upstream"}, cfg.FetchRemotes())
+
+	cfg.PRRemote = ""
+	assert.Equal(t, []string{"fork"}, cfg.FetchRemotes())
+}
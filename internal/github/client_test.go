@@ -0,0 +1,366 @@
+package github_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPullRequestsForBranches(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-a", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-b", "c2")
+	server.SetBranch(githubtest.DefaultRepo, "push-closed", "c3")
+
+	open := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-a")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+	server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		State:    gogithub.Ptr("closed"),
+		ClosedAt: &gogithub.Timestamp{},
+		Head:     &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-closed")},
+		Base:     &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+
+	prs, err := server.Client(t).GetPullRequestsForBranches(
+		t.Context(),
+		githubtest.DefaultRepo,
+		"",
+		[]string{"push-a", "push-b", "push-closed", "push-unpushed"},
+	)
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	assert.Equal(t, open.GetNumber(), prs["push-a"].GetNumber())
+	assert.Equal(t, "c1", prs["push-a"].GetHead().GetSHA())
+}
+
+func TestCreateAndUpdatePullRequest(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-a", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-b", "c2")
+	client := server.Client(t)
+
+	pr, err := client.CreatePullRequest(t.Context(), githubtest.DefaultRepo, github.PullRequestOptions{
+		Title:  "Add feature",
+		Body:   "Body",
+		Branch: "push-b",
+		Base:   "push-a",
+		Draft:  true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, pr.GetNumber())
+
+	_, err = client.CreatePullRequest(t.Context(), githubtest.DefaultRepo, github.PullRequestOptions{
+		Title:  "Duplicate",
+		Branch: "push-b",
+		Base:   "main",
+	})
+	require.Error(t, err, "only one open PR per head branch")
+
+	err = client.UpdatePullRequest(t.Context(), githubtest.DefaultRepo, pr.GetNumber(), github.PullRequestOptions{
+		Title:  "Add feature v2",
+		Body:   "New body",
+		Branch: "push-b",
+		Base:   "main",
+	})
+	require.NoError(t, err)
+
+	prs := server.PullRequests(githubtest.DefaultRepo)
+	require.Len(t, prs, 1)
+	assert.Equal(t, "Add feature v2", prs[0].GetTitle())
+	assert.Equal(t, "New body", prs[0].GetBody())
+	assert.Equal(t, "main", prs[0].GetBase().GetRef())
+	assert.True(t, prs[0].GetDraft())
+}
+
+func TestPullRequestComments(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-a", "c1")
+	pr := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-a")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+	client := server.Client(t)
+
+	require.NoError(t, client.CreatePullRequestComment(t.Context(), githubtest.DefaultRepo, pr.GetNumber(), "LGTM"))
+	require.NoError(t, client.CreatePullRequestComment(t.Context(), githubtest.DefaultRepo, pr.GetNumber(), "<!-- marker -->\nstack v1"))
+
+	comments, err := client.GetPRCommentsContaining(t.Context(), githubtest.DefaultRepo, []int{pr.GetNumber()}, "<!-- marker -->")
+	require.NoError(t, err)
+	require.Contains(t, comments, pr.GetNumber())
+
+	comment := comments[pr.GetNumber()]
+	require.NoError(t, client.UpdatePullRequestComment(t.Context(), githubtest.DefaultRepo, comment.GetID(), "<!-- marker -->\nstack v2"))
+
+	stored := server.Comments(githubtest.DefaultRepo, pr.GetNumber())
+	require.Len(t, stored, 2)
+	assert.Equal(t, "LGTM", stored[0].GetBody())
+	assert.Equal(t, "<!-- marker -->\nstack v2", stored[1].GetBody())
+}
+
+func TestForkPullRequests(t *testing.T) {
+	fork := github.Repo{Owner: "me", Name: "repo"}
+
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-a", "upstream")
+	server.SetBranch(fork, "push-a", "c1")
+	client := server.Client(t)
+
+	pr, err := client.CreatePullRequest(t.Context(), githubtest.DefaultRepo, github.PullRequestOptions{
+		Title:     "From fork",
+		Branch:    "push-a",
+		Base:      "main",
+		HeadOwner: fork.Owner,
+	})
+	require.NoError(t, err)
+
+	prs, err := client.GetPullRequestsForBranches(t.Context(), githubtest.DefaultRepo, fork.Owner, []string{"push-a"})
+	require.NoError(t, err)
+	require.Contains(t, prs, "push-a")
+	assert.Equal(t, pr.GetNumber(), prs["push-a"].GetNumber())
+	assert.Equal(t, "c1", prs["push-a"].GetHead().GetSHA())
+
+	// The upstream branch with the same name has no PR of its own.
+	prs, err = client.GetPullRequestsForBranches(t.Context(), githubtest.DefaultRepo, "", []string{"push-a"})
+	require.NoError(t, err)
+	assert.Empty(t, prs)
+}
+
+func TestGetClosedPullRequestsForBranches(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-a", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-b", "c2")
+
+	merged := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-a")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+	server.MergePullRequest(githubtest.DefaultRepo, merged.GetNumber())
+	server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-b")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+
+	prs, err := server.Client(t).GetClosedPullRequestsForBranches(
+		t.Context(),
+		githubtest.DefaultRepo,
+		"",
+		[]string{"push-a", "push-b"},
+	)
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	assert.Equal(t, merged.GetNumber(), prs["push-a"].GetNumber())
+	assert.NotNil(t, prs["push-a"].MergedAt)
+}
+
+func TestCloseOpenPullRequest(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-a", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-b", "c2")
+	client := server.Client(t)
+
+	login, err := client.CurrentUser(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, githubtest.DefaultUser, login)
+
+	first := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-a")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+	second := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-b")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-a")},
+	})
+
+	require.NoError(t, client.ClosePullRequest(t.Context(), githubtest.DefaultRepo, first.GetNumber()))
+	require.NoError(t, client.DeleteBranch(t.Context(), githubtest.DefaultRepo, "push-a"))
+	require.NoError(t, client.DeleteBranch(t.Context(), githubtest.DefaultRepo, "push-a"), "deleting a missing branch is not an error")
+	assert.False(t, server.HasBranch(githubtest.DefaultRepo, "push-a"))
+
+	prs, err := client.ListOpenPullRequests(t.Context(), githubtest.DefaultRepo)
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	assert.Equal(t, second.GetNumber(), prs[0].GetNumber())
+}
+
+func TestGetPullRequestStatuses(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-a", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-b", "c2")
+	server.SetBranch(githubtest.DefaultRepo, "push-c", "c3")
+	server.SetRequiredChecks(githubtest.DefaultRepo, "main", "build")
+
+	approved := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head:           &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-a")},
+		Base:           &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+		MergeableState: gogithub.Ptr("clean"),
+	})
+	server.AddReview(githubtest.DefaultRepo, approved.GetNumber(), "alice", "CHANGES_REQUESTED")
+	server.AddReview(githubtest.DefaultRepo, approved.GetNumber(), "alice", "APPROVED")
+	server.AddReview(githubtest.DefaultRepo, approved.GetNumber(), "bob", "COMMENTED")
+	server.AddCheckRun(githubtest.DefaultRepo, "c1", "build", "completed", "success")
+	server.AddCheckRun(githubtest.DefaultRepo, "c1", "lint", "completed", "failure") // not required
+
+	blocked := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head:           &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-b")},
+		Base:           &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+		MergeableState: gogithub.Ptr("blocked"),
+	})
+	server.AddReview(githubtest.DefaultRepo, blocked.GetNumber(), "alice", "APPROVED")
+	server.AddReview(githubtest.DefaultRepo, blocked.GetNumber(), "bob", "CHANGES_REQUESTED")
+	server.AddStatus(githubtest.DefaultRepo, "c2", "build", "failure")
+
+	// Unprotected base: every check counts.
+	stacked := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-c")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-b")},
+	})
+	server.AddCheckRun(githubtest.DefaultRepo, "c3", "build", "completed", "success")
+	server.AddCheckRun(githubtest.DefaultRepo, "c3", "lint", "in_progress", "")
+
+	statuses, err := server.Client(t).GetPullRequestStatuses(
+		t.Context(),
+		githubtest.DefaultRepo,
+		[]*gogithub.PullRequest{approved, blocked, stacked},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]github.PullRequestStatus{
+		approved.GetNumber(): {
+			Review:         github.ReviewApproved,
+			Checks:         github.ChecksSuccess,
+			MergeableState: "clean",
+		},
+		blocked.GetNumber(): {
+			Review:         github.ReviewChangesRequested,
+			Checks:         github.ChecksFailure,
+			MergeableState: "blocked",
+		},
+		stacked.GetNumber(): {
+			Checks: github.ChecksPending,
+		},
+	}, statuses)
+}
+
+func TestMergePullRequest(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-a", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-b", "c2")
+	client := server.Client(t)
+
+	bottom := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-a")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+	top := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-b")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-a")},
+	})
+
+	err := client.MergePullRequest(t.Context(), githubtest.DefaultRepo, bottom.GetNumber(), github.MergeMethodSquash, "stale")
+	require.Error(t, err, "head moved since it was reviewed")
+
+	err = client.MergePullRequest(t.Context(), githubtest.DefaultRepo, bottom.GetNumber(), github.MergeMethodSquash, "c1")
+	require.NoError(t, err)
+	assert.Equal(t, "squash", server.MergeMethod(githubtest.DefaultRepo, bottom.GetNumber()))
+
+	merged, err := client.GetPullRequest(t.Context(), githubtest.DefaultRepo, bottom.GetNumber())
+	require.NoError(t, err)
+	assert.True(t, merged.GetMerged())
+	assert.NotNil(t, merged.MergedAt)
+
+	require.NoError(t, client.SetPullRequestBase(t.Context(), githubtest.DefaultRepo, top.GetNumber(), "main"))
+	retargeted, err := client.GetPullRequest(t.Context(), githubtest.DefaultRepo, top.GetNumber())
+	require.NoError(t, err)
+	assert.Equal(t, "main", retargeted.GetBase().GetRef())
+}
+
+func TestParseMergeMethod(t *testing.T) {
+	method, err := github.ParseMergeMethod("rebase")
+	require.NoError(t, err)
+	assert.Equal(t, github.MergeMethodRebase, method)
+
+	_, err = github.ParseMergeMethod("fast-forward")
+	require.Error(t, err)
+}
+
+func TestReviewersAssigneesAndLabels(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-a", "c1")
+	client := server.Client(t)
+
+	pr := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-a")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+
+	err := client.RequestReviewers(t.Context(), githubtest.DefaultRepo, pr.GetNumber(), []string{githubtest.DefaultUser})
+	require.Error(t, err, "authors can't review their own PR")
+
+	err = client.RequestReviewers(t.Context(), githubtest.DefaultRepo, pr.GetNumber(), []string{"alice", "owner/reviewers"})
+	require.NoError(t, err)
+	require.NoError(t, client.AddAssignees(t.Context(), githubtest.DefaultRepo, pr.GetNumber(), []string{"bob"}))
+
+	got := server.PullRequests(githubtest.DefaultRepo)[0]
+	require.Len(t, got.RequestedReviewers, 1)
+	assert.Equal(t, "alice", got.RequestedReviewers[0].GetLogin())
+	require.Len(t, got.RequestedTeams, 1)
+	assert.Equal(t, "reviewers", got.RequestedTeams[0].GetSlug())
+	require.Len(t, got.Assignees, 1)
+	assert.Equal(t, "bob", got.Assignees[0].GetLogin())
+
+	require.NoError(t, client.AddLabels(t.Context(), githubtest.DefaultRepo, pr.GetNumber(), []string{"stacked", "db"}))
+	require.NoError(t, client.AddLabels(t.Context(), githubtest.DefaultRepo, pr.GetNumber(), []string{"db"}))
+	got = server.PullRequests(githubtest.DefaultRepo)[0]
+	require.Len(t, got.Labels, 2)
+	assert.Equal(t, "stacked", got.Labels[0].GetName())
+	assert.Equal(t, "db", got.Labels[1].GetName())
+
+	server.AddReview(githubtest.DefaultRepo, pr.GetNumber(), "alice", "APPROVED")
+	assert.Empty(t, server.PullRequests(githubtest.DefaultRepo)[0].RequestedReviewers)
+
+	reviewers, err := client.GetReviewers(t.Context(), githubtest.DefaultRepo, []int{pr.GetNumber()})
+	require.NoError(t, err)
+	assert.Equal(t, map[int][]string{pr.GetNumber(): {"alice"}}, reviewers)
+}
+
+func TestReviewsArePaginated(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-a", "c1")
+	client := server.Client(t)
+
+	pr := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-a"), SHA: gogithub.Ptr("c1")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+	var want []string
+	for i := range 150 {
+		login := fmt.Sprintf("user%d", i)
+		server.AddReview(githubtest.DefaultRepo, pr.GetNumber(), login, "COMMENTED")
+		want = append(want, login)
+	}
+	server.AddReview(githubtest.DefaultRepo, pr.GetNumber(), "user0", "CHANGES_REQUESTED")
+
+	reviewers, err := client.GetReviewers(t.Context(), githubtest.DefaultRepo, []int{pr.GetNumber()})
+	require.NoError(t, err)
+	assert.Equal(t, map[int][]string{pr.GetNumber(): want}, reviewers)
+
+	statuses, err := client.GetPullRequestStatuses(t.Context(), githubtest.DefaultRepo, []*gogithub.PullRequest{pr})
+	require.NoError(t, err)
+	assert.Equal(t, github.ReviewChangesRequested, statuses[pr.GetNumber()].Review)
+}
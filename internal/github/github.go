@@ -17,56 +17,94 @@ import (
 
 const (
 	ghConcurrency = 8
+
+	// DefaultHost is the host of github.com, which is always accepted.
+	DefaultHost = "github.com"
 )
 
 // Client wraps the GitHub API client with authentication.
 type Client struct {
-	client *github.Client
+	client      *github.Client
+	concurrency int
 }
 
-// NewClient creates a new GitHub client authenticated via the gh CLI.
-func NewClient() (*Client, error) {
-	token, err := GetGHAuthToken()
+// NewClient creates a new GitHub client for host authenticated via the gh CLI.
+// Hosts other than github.com are treated as GitHub Enterprise Server instances.
+func NewClient(host string) (*Client, error) {
+	token, err := GetGHAuthToken(host)
 	if err != nil {
 		return nil, fmt.Errorf("get auth token from gh cli: %w", err)
 	}
 
-	return &Client{
-		client: github.NewClient(nil).WithAuthToken(token),
-	}, nil
+	client := github.NewClient(nil).WithAuthToken(token)
+	if host != "" && host != DefaultHost {
+		client, err = client.WithEnterpriseURLs(
+			fmt.Sprintf("https://%s/api/v3/", host),
+			fmt.Sprintf("https://%s/api/uploads/", host),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("configure enterprise urls: %w", err)
+		}
+	}
+
+	return &Client{client: client, concurrency: ghConcurrency}, nil
+}
+
+// WithConcurrency returns a copy of the client that makes at most n
+// API requests in parallel.
+func (c *Client) WithConcurrency(n int) *Client {
+	c2 := *c
+	c2.concurrency = n
+	return &c2
+}
+
+// NewClientWithBaseURL creates a GitHub client that talks to the REST API at
+// baseURL using the given token. This is used to point the client at a fake
+// server in tests.
+func NewClientWithBaseURL(baseURL string, token string) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	if !strings.HasSuffix(parsed.Path, "/") {
+		parsed.Path += "/"
+	}
+
+	client := github.NewClient(nil).WithAuthToken(token)
+	client.BaseURL = parsed
+
+	return &Client{client: client, concurrency: ghConcurrency}, nil
 }
 
 // GetPullRequestsForBranches gets all the open pull requests for the specified branches.
+// If headOwner is set and differs from the repo owner, branches are looked up in
+// that owner's fork instead of the repo itself.
 // This expects only a single pull request to be open per branch.
 func (c *Client) GetPullRequestsForBranches(
 	ctx context.Context,
 	repo Repo,
+	headOwner string,
 	branches []string,
 ) (map[string]*github.PullRequest, error) {
 	var mu sync.Mutex
 	result := make(map[string]*github.PullRequest)
 
 	eg, ctx := errgroup.WithContext(ctx)
-	eg.SetLimit(ghConcurrency)
+	eg.SetLimit(c.concurrency)
 
 	for _, branch := range branches {
 		eg.Go(func() error {
-			prs, _, err := c.client.PullRequests.ListPullRequestsWithCommit(ctx, repo.Owner, repo.Name, branch, nil)
+			var prs []*github.PullRequest
+			var err error
+			if headOwner != "" && headOwner != repo.Owner {
+				prs, err = c.listForkPullRequests(ctx, repo, headOwner, branch)
+			} else {
+				prs, err = c.listBranchPullRequests(ctx, repo, branch)
+			}
 			if err != nil {
-				var ghErr *github.ErrorResponse
-				// This error indicates that the branch has not been pushed yet.
-				if errors.As(err, &ghErr) &&
-					ghErr.Response.StatusCode == http.StatusUnprocessableEntity {
-					return nil
-				}
 				return err
 			}
 
-			// Filter out closed PRs.
-			prs = slices.DeleteFunc(prs, func(pr *github.PullRequest) bool {
-				return pr.ClosedAt != nil || *pr.Head.Ref != branch
-			})
-
 			if len(prs) == 0 {
 				return nil
 			}
@@ -90,6 +128,37 @@ func (c *Client) GetPullRequestsForBranches(
 	return result, nil
 }
 
+// listBranchPullRequests returns the open pull requests whose head is branch in repo.
+func (c *Client) listBranchPullRequests(ctx context.Context, repo Repo, branch string) ([]*github.PullRequest, error) {
+	prs, _, err := c.client.PullRequests.ListPullRequestsWithCommit(ctx, repo.Owner, repo.Name, branch, nil)
+	if err != nil {
+		var ghErr *github.ErrorResponse
+		// This error indicates that the branch has not been pushed yet.
+		if errors.As(err, &ghErr) &&
+			ghErr.Response.StatusCode == http.StatusUnprocessableEntity {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Filter out closed PRs.
+	prs = slices.DeleteFunc(prs, func(pr *github.PullRequest) bool {
+		return pr.ClosedAt != nil || *pr.Head.Ref != branch
+	})
+
+	return prs, nil
+}
+
+// listForkPullRequests returns the open pull requests against repo whose head is
+// branch in headOwner's fork.
+func (c *Client) listForkPullRequests(ctx context.Context, repo Repo, headOwner, branch string) ([]*github.PullRequest, error) {
+	prs, _, err := c.client.PullRequests.List(ctx, repo.Owner, repo.Name, &github.PullRequestListOptions{
+		State: "open",
+		Head:  headOwner + ":" + branch,
+	})
+	return prs, err
+}
+
 // PullRequestOptions specifies options for creating or updating a pull request.
 type PullRequestOptions struct {
 	Title  string
@@ -97,6 +166,25 @@ type PullRequestOptions struct {
 	Branch string
 	Base   string
 	Draft  bool
+
+	// HeadOwner is the owner of the fork Branch lives in.
+	// Empty when the branch is in the pull request's repo.
+	HeadOwner string
+
+	// Reviewers are user logins, or teams as "org/team", to request reviews from.
+	Reviewers []string
+	// Assignees are user logins to assign.
+	Assignees []string
+	// Labels are label names to add.
+	Labels []string
+}
+
+// Head returns the head reference to open the pull request from.
+func (o PullRequestOptions) Head() string {
+	if o.HeadOwner != "" {
+		return o.HeadOwner + ":" + o.Branch
+	}
+	return o.Branch
 }
 
 // CreatePullRequest creates a new pull request.
@@ -105,9 +193,10 @@ func (c *Client) CreatePullRequest(
 	repo Repo,
 	opts PullRequestOptions,
 ) (*github.PullRequest, error) {
+	head := opts.Head()
 	pr, _, err := c.client.PullRequests.Create(ctx, repo.Owner, repo.Name, &github.NewPullRequest{
 		Title: &opts.Title,
-		Head:  &opts.Branch,
+		Head:  &head,
 		Base:  &opts.Base,
 		Body:  &opts.Body,
 		Draft: &opts.Draft,
@@ -136,6 +225,230 @@ func (c *Client) UpdatePullRequest(
 	return err
 }
 
+// RequestReviewers requests reviews from users and teams, given as "org/team".
+func (c *Client) RequestReviewers(ctx context.Context, repo Repo, number int, reviewers []string) error {
+	var request github.ReviewersRequest
+	for _, reviewer := range reviewers {
+		if _, team, ok := strings.Cut(reviewer, "/"); ok {
+			request.TeamReviewers = append(request.TeamReviewers, team)
+		} else {
+			request.Reviewers = append(request.Reviewers, reviewer)
+		}
+	}
+
+	_, _, err := c.client.PullRequests.RequestReviewers(ctx, repo.Owner, repo.Name, number, request)
+	return err
+}
+
+// AddAssignees assigns users to a pull request, keeping existing assignees.
+func (c *Client) AddAssignees(ctx context.Context, repo Repo, number int, assignees []string) error {
+	_, _, err := c.client.Issues.AddAssignees(ctx, repo.Owner, repo.Name, number, assignees)
+	return err
+}
+
+// AddLabels adds labels to a pull request, keeping existing labels.
+func (c *Client) AddLabels(ctx context.Context, repo Repo, number int, labels []string) error {
+	_, _, err := c.client.Issues.AddLabelsToIssue(ctx, repo.Owner, repo.Name, number, labels)
+	return err
+}
+
+// GetReviewers returns the logins that submitted a review on each pull request.
+func (c *Client) GetReviewers(ctx context.Context, repo Repo, numbers []int) (map[int][]string, error) {
+	var mu sync.Mutex
+	result := make(map[int][]string)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(c.concurrency)
+
+	for _, number := range numbers {
+		eg.Go(func() error {
+			reviews, err := c.listReviews(ctx, repo, number)
+			if err != nil {
+				return err
+			}
+
+			var logins []string
+			for _, review := range reviews {
+				if login := review.GetUser().GetLogin(); !slices.Contains(logins, login) {
+					logins = append(logins, login)
+				}
+			}
+
+			mu.Lock()
+			result[number] = logins
+			mu.Unlock()
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// listReviews returns every review submitted on a pull request.
+func (c *Client) listReviews(ctx context.Context, repo Repo, number int) ([]*github.PullRequestReview, error) {
+	var result []*github.PullRequestReview
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := c.client.PullRequests.ListReviews(ctx, repo.Owner, repo.Name, number, opts)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, reviews...)
+		if resp.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// SetPullRequestBase changes the base branch of a pull request.
+func (c *Client) SetPullRequestBase(ctx context.Context, repo Repo, number int, base string) error {
+	_, _, err := c.client.PullRequests.Edit(ctx, repo.Owner, repo.Name, number, &github.PullRequest{
+		Base: &github.PullRequestBranch{
+			Ref: &base,
+		},
+	})
+	return err
+}
+
+// SetPullRequestBody replaces the body of a pull request.
+func (c *Client) SetPullRequestBody(ctx context.Context, repo Repo, number int, body string) error {
+	_, _, err := c.client.PullRequests.Edit(ctx, repo.Owner, repo.Name, number, &github.PullRequest{
+		Body: &body,
+	})
+	return err
+}
+
+// ClosePullRequest closes a pull request without merging it.
+func (c *Client) ClosePullRequest(ctx context.Context, repo Repo, number int) error {
+	_, _, err := c.client.PullRequests.Edit(ctx, repo.Owner, repo.Name, number, &github.PullRequest{
+		State: github.Ptr("closed"),
+	})
+	return err
+}
+
+// ListOpenPullRequests returns every open pull request against repo.
+func (c *Client) ListOpenPullRequests(ctx context.Context, repo Repo) ([]*github.PullRequest, error) {
+	opts := &github.PullRequestListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var result []*github.PullRequest
+	for {
+		prs, resp, err := c.client.PullRequests.List(ctx, repo.Owner, repo.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, prs...)
+		if resp.NextPage == 0 {
+			return result, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// CurrentUser returns the login of the authenticated user.
+func (c *Client) CurrentUser(ctx context.Context) (string, error) {
+	user, _, err := c.client.Users.Get(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	return user.GetLogin(), nil
+}
+
+// DeleteBranch deletes a branch of repo. A branch that no longer exists is
+// not an error.
+func (c *Client) DeleteBranch(ctx context.Context, repo Repo, branch string) error {
+	_, err := c.client.Git.DeleteRef(ctx, repo.Owner, repo.Name, "heads/"+branch)
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response.StatusCode == http.StatusUnprocessableEntity {
+		return nil
+	}
+	return err
+}
+
+// GetPullRequest returns a single pull request.
+func (c *Client) GetPullRequest(ctx context.Context, repo Repo, number int) (*github.PullRequest, error) {
+	pr, _, err := c.client.PullRequests.Get(ctx, repo.Owner, repo.Name, number)
+	return pr, err
+}
+
+// GetPullRequests returns the given pull requests by number. Pull requests
+// that don't exist are left out.
+func (c *Client) GetPullRequests(ctx context.Context, repo Repo, numbers []int) (map[int]*github.PullRequest, error) {
+	var mu sync.Mutex
+	result := make(map[int]*github.PullRequest)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(c.concurrency)
+
+	for _, number := range numbers {
+		eg.Go(func() error {
+			pr, _, err := c.client.PullRequests.Get(ctx, repo.Owner, repo.Name, number)
+			if err != nil {
+				var ghErr *github.ErrorResponse
+				if errors.As(err, &ghErr) && ghErr.Response.StatusCode == http.StatusNotFound {
+					return nil
+				}
+				return err
+			}
+
+			mu.Lock()
+			result[number] = pr
+			mu.Unlock()
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// MergeMethod is how a pull request's commits are added to its base.
+type MergeMethod string
+
+const (
+	MergeMethodMerge  MergeMethod = "merge"
+	MergeMethodSquash MergeMethod = "squash"
+	MergeMethodRebase MergeMethod = "rebase"
+)
+
+// ParseMergeMethod validates a merge method name.
+func ParseMergeMethod(s string) (MergeMethod, error) {
+	switch method := MergeMethod(s); method {
+	case MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		return method, nil
+	default:
+		return "", fmt.Errorf("unknown merge method %q (expected merge, squash or rebase)", s)
+	}
+}
+
+// MergePullRequest merges a pull request, provided its head is still at
+// headSHA. The merge commit title and message are left to GitHub.
+func (c *Client) MergePullRequest(
+	ctx context.Context,
+	repo Repo,
+	number int,
+	method MergeMethod,
+	headSHA string,
+) error {
+	_, _, err := c.client.PullRequests.Merge(ctx, repo.Owner, repo.Name, number, "", &github.PullRequestOptions{
+		MergeMethod: string(method),
+		SHA:         headSHA,
+	})
+	return err
+}
+
 // CreatePullRequestComment adds a comment to a pull request.
 func (c *Client) CreatePullRequestComment(
 	ctx context.Context,
@@ -173,7 +486,7 @@ func (c *Client) GetPRCommentsContaining(
 	result := make(map[int]*github.IssueComment)
 
 	eg, ctx := errgroup.WithContext(ctx)
-	eg.SetLimit(ghConcurrency)
+	eg.SetLimit(c.concurrency)
 
 	for _, prNumber := range pullRequests {
 		eg.Go(func() error {
@@ -207,26 +520,51 @@ func (c *Client) GetPRCommentsContaining(
 
 // Repo represents a GitHub repository.
 type Repo struct {
+	Host  string
 	Owner string
 	Name  string
 }
 
+// WebHost returns the host serving the repository's web UI.
+func (r Repo) WebHost() string {
+	if r.Host == "" {
+		return DefaultHost
+	}
+	return r.Host
+}
+
+// PullRequestURL returns the web URL of the given pull request.
+func (r Repo) PullRequestURL(number int) string {
+	return fmt.Sprintf("https://%s/%s/%s/pull/%d", r.WebHost(), r.Owner, r.Name, number)
+}
+
 // GetRepoFromRemote returns repo information from the given URL.
 // This supports both HTTPS and SSH URLs:
 // - https://github.com/cbrewster/jj-github.git
 // - git@github.com:cbrewster/jj-github.git
-func GetRepoFromRemote(remote string) (Repo, error) {
+//
+// Remotes on github.com are always accepted; GitHub Enterprise hosts must be
+// listed in allowedHosts.
+func GetRepoFromRemote(remote string, allowedHosts ...string) (Repo, error) {
 	if strings.HasPrefix(remote, "https://") {
-		return parseHttpsRemote(remote)
+		return parseHttpsRemote(remote, allowedHosts)
 	}
 	if strings.HasPrefix(remote, "git@") {
-		return parseSshRemote(remote)
+		return parseSshRemote(remote, allowedHosts)
 	}
 
 	return Repo{}, errors.New("unknown remote format")
 }
 
-func parseSshRemote(remote string) (Repo, error) {
+// checkHost returns an error if host is neither github.com nor an allowed host.
+func checkHost(host string, allowedHosts []string) error {
+	if host == DefaultHost || slices.Contains(allowedHosts, host) {
+		return nil
+	}
+	return fmt.Errorf("host %q is not github.com or a configured GitHub Enterprise host", host)
+}
+
+func parseSshRemote(remote string, allowedHosts []string) (Repo, error) {
 	first, second, ok := strings.Cut(remote, ":")
 	if !ok {
 		return Repo{}, errors.New("expected ssh remote to have \":\"")
@@ -237,8 +575,8 @@ func parseSshRemote(remote string) (Repo, error) {
 		return Repo{}, errors.New("expected ssh remote to have \"@\"")
 	}
 
-	if host != "github.com" {
-		return Repo{}, errors.New("only github.com remotes are allowed")
+	if err := checkHost(host, allowedHosts); err != nil {
+		return Repo{}, err
 	}
 
 	owner, repo, ok := strings.Cut(second, "/")
@@ -251,17 +589,17 @@ func parseSshRemote(remote string) (Repo, error) {
 		return Repo{}, errors.New("expected ssh remote to end with .git")
 	}
 
-	return Repo{Owner: owner, Name: repo}, nil
+	return Repo{Host: host, Owner: owner, Name: repo}, nil
 }
 
-func parseHttpsRemote(remote string) (Repo, error) {
+func parseHttpsRemote(remote string, allowedHosts []string) (Repo, error) {
 	parsedUrl, err := url.Parse(remote)
 	if err != nil {
 		return Repo{}, err
 	}
 
-	if parsedUrl.Host != "github.com" {
-		return Repo{}, errors.New("only github.com remotes are allowed")
+	if err := checkHost(parsedUrl.Host, allowedHosts); err != nil {
+		return Repo{}, err
 	}
 
 	owner, repo, ok := strings.Cut(strings.TrimPrefix(parsedUrl.Path, "/"), "/")
@@ -274,12 +612,17 @@ func parseHttpsRemote(remote string) (Repo, error) {
 		return Repo{}, errors.New("expected https remote to end with .git")
 	}
 
-	return Repo{Owner: owner, Name: repo}, nil
+	return Repo{Host: parsedUrl.Host, Owner: owner, Name: repo}, nil
 }
 
-// GetGHAuthToken returns a GitHub auth token using the gh cli.
-func GetGHAuthToken() (string, error) {
-	out, err := exec.Command("gh", "auth", "token").Output()
+// GetGHAuthToken returns a GitHub auth token for host using the gh cli.
+func GetGHAuthToken(host string) (string, error) {
+	args := []string{"auth", "token"}
+	if host != "" {
+		args = append(args, "--hostname", host)
+	}
+
+	out, err := exec.Command("gh", args...).Output()
 	if err != nil {
 		return "", fmt.Errorf("gh auth token: %w", err)
 	}
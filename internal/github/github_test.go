@@ -11,21 +11,34 @@ func TestGetRepoFromRemote(t *testing.T) {
 	for _, tc := range []struct {
 		Name     string
 		URL      string
+		Hosts    []string
 		Expected Repo
 	}{
 		{
 			Name:     "ssh",
 			URL:      "git@github.com:cbrewster/jj-github.git",
-			Expected: Repo{Owner: "cbrewster", Name: "jj-github"},
+			Expected: Repo{Host: "github.com", Owner: "cbrewster", Name: "jj-github"},
 		},
 		{
 			Name:     "https",
 			URL:      "https://github.com/cbrewster/jj-github.git",
-			Expected: Repo{Owner: "cbrewster", Name: "jj-github"},
+			Expected: Repo{Host: "github.com", Owner: "cbrewster", Name: "jj-github"},
+		},
+		{
+			Name:     "ssh/enterprise",
+			URL:      "git@github.example.com:cbrewster/jj-github.git",
+			Hosts:    []string{"github.example.com"},
+			Expected: Repo{Host: "github.example.com", Owner: "cbrewster", Name: "jj-github"},
+		},
+		{
+			Name:     "https/enterprise",
+			URL:      "https://github.example.com/cbrewster/jj-github.git",
+			Hosts:    []string{"other.example.com", "github.example.com"},
+			Expected: Repo{Host: "github.example.com", Owner: "cbrewster", Name: "jj-github"},
 		},
 	} {
 		t.Run(tc.Name, func(t *testing.T) {
-			repo, err := GetRepoFromRemote(tc.URL)
+			repo, err := GetRepoFromRemote(tc.URL, tc.Hosts...)
 			require.NoError(t, err)
 			assert.Equal(t, tc.Expected, repo)
 		})
@@ -34,8 +47,9 @@ func TestGetRepoFromRemote(t *testing.T) {
 
 func TestGetRepoFromRemoteInvalid(t *testing.T) {
 	for _, tc := range []struct {
-		Name string
-		URL  string
+		Name  string
+		URL   string
+		Hosts []string
 	}{
 		{
 			Name: "ssh/not-github",
@@ -53,10 +67,22 @@ func TestGetRepoFromRemoteInvalid(t *testing.T) {
 			Name: "https/missing-dot-git",
 			URL:  "https://example.com/cbrewster/jj-github",
 		},
+		{
+			Name:  "https/unconfigured-enterprise",
+			URL:   "https://github.example.com/cbrewster/jj-github.git",
+			Hosts: []string{"other.example.com"},
+		},
 	} {
 		t.Run(tc.Name, func(t *testing.T) {
-			_, err := GetRepoFromRemote(tc.URL)
+			_, err := GetRepoFromRemote(tc.URL, tc.Hosts...)
 			require.Error(t, err)
 		})
 	}
 }
+
+func TestRepoPullRequestURL(t *testing.T) {
+	assert.Equal(t, "https://github.com/cbrewster/jj-github/pull/1",
+		Repo{Owner: "cbrewster", Name: "jj-github"}.PullRequestURL(1))
+	assert.Equal(t, "https://github.example.com/cbrewster/jj-github/pull/2",
+		Repo{Host: "github.example.com", Owner: "cbrewster", Name: "jj-github"}.PullRequestURL(2))
+}
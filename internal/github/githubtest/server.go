@@ -0,0 +1,981 @@
+// Package githubtest provides an in-process fake of the GitHub REST API for tests.
+package githubtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// DefaultUser is the login of the authenticated user on the fake server.
+const DefaultUser = "octocat"
+
+// DefaultRepo is the repository tests open pull requests against.
+var DefaultRepo = github.Repo{Owner: "owner", Name: "repo"}
+
+// Server is a stateful fake of the subset of the GitHub REST API used by jj-github.
+// It models repositories, branches, pull requests, issue comments, reviews,
+// review requests, assignees, labels, commit checks and required status checks.
+type Server struct {
+	*httptest.Server
+
+	// ResolveBranch, if set, is consulted for branches that were not set with
+	// SetBranch. It returns the commit SHA the branch points at.
+	ResolveBranch func(repo github.Repo, branch string) (string, bool)
+
+	mu            sync.Mutex
+	repos         map[github.Repo]*repoState
+	nextCommentID int64
+}
+
+type repoState struct {
+	branches       map[string]string
+	pullRequests   []*gogithub.PullRequest
+	comments       map[int][]*gogithub.IssueComment
+	reviews        map[int][]*gogithub.PullRequestReview
+	checkRuns      map[string][]*gogithub.CheckRun
+	statuses       map[string][]*gogithub.RepoStatus
+	requiredChecks map[string][]string
+	mergeMethods   map[int]string
+	// Pull requests whose mergeability is being recomputed, e.g. after their
+	// base changed. It is known once the pull request is fetched again.
+	recomputing map[int]bool
+	// Merges to reject before accepting one, per pull request
+	rejectedMerges map[int]int
+}
+
+// NewServer starts a fake GitHub server that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		repos:         make(map[github.Repo]*repoState),
+		nextCommentID: 1000,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/{owner}/{repo}/commits/{rest...}", s.getCommitResource)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls", s.listPullRequests)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}", s.getPullRequest)
+	mux.HandleFunc("POST /repos/{owner}/{repo}/pulls", s.createPullRequest)
+	mux.HandleFunc("PATCH /repos/{owner}/{repo}/pulls/{number}", s.editPullRequest)
+	mux.HandleFunc("PUT /repos/{owner}/{repo}/pulls/{number}/merge", s.mergePullRequest)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}/reviews", s.listReviews)
+	mux.HandleFunc("POST /repos/{owner}/{repo}/pulls/{number}/requested_reviewers", s.requestReviewers)
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues/{number}/assignees", s.addAssignees)
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues/{number}/labels", s.addLabels)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/branches/{branch}/protection/required_status_checks", s.getRequiredChecks)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/issues/{number}/comments", s.listComments)
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues/{number}/comments", s.createComment)
+	mux.HandleFunc("PATCH /repos/{owner}/{repo}/issues/comments/{id}", s.editComment)
+	mux.HandleFunc("DELETE /repos/{owner}/{repo}/git/refs/heads/{branch...}", s.deleteBranch)
+	mux.HandleFunc("GET /user", s.getUser)
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Client returns a jj-github client pointed at the fake server.
+func (s *Server) Client(t testing.TB) *github.Client {
+	t.Helper()
+
+	client, err := github.NewClientWithBaseURL(s.URL, "test-token")
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	return client
+}
+
+// SetBranch creates or moves a branch in the repository.
+func (s *Server) SetBranch(repo github.Repo, branch, sha string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.repo(repo).branches[branch] = sha
+}
+
+// AddPullRequest seeds a pull request and returns it with its assigned number.
+func (s *Server) AddPullRequest(repo github.Repo, pr *gogithub.PullRequest) *gogithub.PullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.repo(repo)
+	pr.Number = gogithub.Ptr(len(state.pullRequests) + 1)
+	if pr.State == nil {
+		pr.State = gogithub.Ptr("open")
+	}
+	if pr.User == nil {
+		pr.User = &gogithub.User{Login: gogithub.Ptr(DefaultUser)}
+	}
+	if pr.Head != nil && pr.Head.Repo == nil {
+		pr.Head.Repo = &gogithub.Repository{
+			Name:  gogithub.Ptr(repo.Name),
+			Owner: &gogithub.User{Login: gogithub.Ptr(repo.Owner)},
+		}
+	}
+	pr.HTMLURL = gogithub.Ptr(fmt.Sprintf("%s/%s/%s/pull/%d", s.URL, repo.Owner, repo.Name, pr.GetNumber()))
+	state.pullRequests = append(state.pullRequests, pr)
+
+	return s.snapshot(repo, pr)
+}
+
+// OpenPullRequest seeds an open pull request from branch, which is set to
+// sha, into base. Other fields, such as the user or body, are taken from pr if
+// it isn't nil; the title defaults to "Change on <branch>".
+func (s *Server) OpenPullRequest(repo github.Repo, branch, base, sha string, pr *gogithub.PullRequest) *gogithub.PullRequest {
+	if pr == nil {
+		pr = &gogithub.PullRequest{}
+	}
+	if pr.Title == nil {
+		pr.Title = gogithub.Ptr("Change on " + branch)
+	}
+	pr.Head = &gogithub.PullRequestBranch{Ref: gogithub.Ptr(branch)}
+	pr.Base = &gogithub.PullRequestBranch{Ref: gogithub.Ptr(base)}
+
+	s.SetBranch(repo, branch, sha)
+	return s.AddPullRequest(repo, pr)
+}
+
+// MergePullRequest marks a pull request as merged and closed, as if it was
+// merged through the web UI.
+func (s *Server) MergePullRequest(repo github.Repo, number int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr := s.findPullRequest(repo, number)
+	if pr == nil {
+		panic(fmt.Sprintf("githubtest: no pull request #%d in %s/%s", number, repo.Owner, repo.Name))
+	}
+	s.merge(repo, pr)
+}
+
+// MergeMethod returns the method a pull request was merged with through the
+// API, or "" if it wasn't.
+func (s *Server) MergeMethod(repo github.Repo, number int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.repo(repo).mergeMethods[number]
+}
+
+// RejectMerge makes the next attempt to merge a pull request fail with 405, as
+// GitHub does while it recomputes whether the pull request can be merged.
+func (s *Server) RejectMerge(repo github.Repo, number int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.repo(repo).rejectedMerges[number]++
+}
+
+// merge closes pr as merged and advances its base branch. Callers must hold s.mu.
+func (s *Server) merge(repo github.Repo, pr *gogithub.PullRequest) {
+	now := &gogithub.Timestamp{Time: time.Now()}
+	pr.State = gogithub.Ptr("closed")
+	pr.Merged = gogithub.Ptr(true)
+	pr.MergedAt = now
+	pr.ClosedAt = now
+	pr.UpdatedAt = now
+
+	base := pr.GetBase().GetRef()
+	if _, ok := s.repo(repo).branches[base]; ok {
+		s.repo(repo).branches[base] = fmt.Sprintf("merge-%d", pr.GetNumber())
+	}
+}
+
+// AddReview records a review on a pull request. state is one of APPROVED,
+// CHANGES_REQUESTED, COMMENTED or DISMISSED.
+func (s *Server) AddReview(repo github.Repo, number int, user, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reviews := s.repo(repo).reviews
+	reviews[number] = append(reviews[number], &gogithub.PullRequestReview{
+		ID:    gogithub.Ptr(int64(len(reviews[number]) + 1)),
+		User:  &gogithub.User{Login: gogithub.Ptr(user)},
+		State: gogithub.Ptr(state),
+	})
+
+	// Like GitHub, submitting a review fulfills the review request.
+	if pr := s.findPullRequest(repo, number); pr != nil {
+		pr.RequestedReviewers = slices.DeleteFunc(slices.Clone(pr.RequestedReviewers), func(u *gogithub.User) bool {
+			return u.GetLogin() == user
+		})
+	}
+}
+
+// SetPullRequestBody replaces the body of a pull request, as if edited on GitHub.
+func (s *Server) SetPullRequestBody(repo github.Repo, number int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pr := s.findPullRequest(repo, number); pr != nil {
+		pr.Body = gogithub.Ptr(body)
+	}
+}
+
+// AddLabel adds a label to a pull request, as if added on GitHub.
+func (s *Server) AddLabel(repo github.Repo, number int, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pr := s.findPullRequest(repo, number); pr != nil {
+		addLabel(pr, name)
+	}
+}
+
+// AddCheckRun records a check run on a commit. conclusion is ignored unless
+// status is "completed".
+func (s *Server) AddCheckRun(repo github.Repo, sha, name, status, conclusion string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := &gogithub.CheckRun{
+		Name:    gogithub.Ptr(name),
+		HeadSHA: gogithub.Ptr(sha),
+		Status:  gogithub.Ptr(status),
+	}
+	if status == "completed" {
+		run.Conclusion = gogithub.Ptr(conclusion)
+	}
+	s.repo(repo).checkRuns[sha] = append(s.repo(repo).checkRuns[sha], run)
+}
+
+// AddStatus records a commit status. state is one of success, pending,
+// failure or error.
+func (s *Server) AddStatus(repo github.Repo, sha, context, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.repo(repo).statuses[sha] = append(s.repo(repo).statuses[sha], &gogithub.RepoStatus{
+		Context: gogithub.Ptr(context),
+		State:   gogithub.Ptr(state),
+	})
+}
+
+// SetRequiredChecks protects branch, requiring the named checks to pass.
+func (s *Server) SetRequiredChecks(repo github.Repo, branch string, checks ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.repo(repo).requiredChecks[branch] = checks
+}
+
+// HasBranch reports whether the branch exists in the repository.
+func (s *Server) HasBranch(repo github.Repo, branch string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.repo(repo).branches[branch]
+	return ok
+}
+
+// PullRequests returns a snapshot of every pull request in the repository.
+func (s *Server) PullRequests(repo github.Repo) []*gogithub.PullRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*gogithub.PullRequest
+	for _, pr := range s.repo(repo).pullRequests {
+		result = append(result, s.snapshot(repo, pr))
+	}
+	return result
+}
+
+// Comments returns a snapshot of the comments on a pull request.
+func (s *Server) Comments(repo github.Repo, number int) []*gogithub.IssueComment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*gogithub.IssueComment
+	for _, comment := range s.repo(repo).comments[number] {
+		copied := *comment
+		result = append(result, &copied)
+	}
+	return result
+}
+
+// repo returns the state for repo, creating it if needed. Callers must hold s.mu.
+func (s *Server) repo(repo github.Repo) *repoState {
+	state, ok := s.repos[repo]
+	if !ok {
+		state = &repoState{
+			branches:       make(map[string]string),
+			comments:       make(map[int][]*gogithub.IssueComment),
+			reviews:        make(map[int][]*gogithub.PullRequestReview),
+			checkRuns:      make(map[string][]*gogithub.CheckRun),
+			statuses:       make(map[string][]*gogithub.RepoStatus),
+			requiredChecks: make(map[string][]string),
+			mergeMethods:   make(map[int]string),
+			recomputing:    make(map[int]bool),
+			rejectedMerges: make(map[int]int),
+		}
+		s.repos[repo] = state
+	}
+	return state
+}
+
+// resolve returns the commit a branch points at. Callers must hold s.mu.
+func (s *Server) resolve(repo github.Repo, branch string) (string, bool) {
+	if sha, ok := s.repo(repo).branches[branch]; ok {
+		return sha, true
+	}
+	if s.ResolveBranch != nil {
+		return s.ResolveBranch(repo, branch)
+	}
+	return "", false
+}
+
+// snapshot copies pr with its head SHA resolved from the current branch state.
+// Callers must hold s.mu.
+func (s *Server) snapshot(repo github.Repo, pr *gogithub.PullRequest) *gogithub.PullRequest {
+	copied := *pr
+	head := *pr.GetHead()
+	if sha, ok := s.resolve(headRepo(repo, pr), head.GetRef()); ok {
+		head.SHA = gogithub.Ptr(sha)
+	}
+	copied.Head = &head
+	base := *pr.GetBase()
+	copied.Base = &base
+	return &copied
+}
+
+// headRepo returns the repository the head branch of pr lives in.
+func headRepo(repo github.Repo, pr *gogithub.PullRequest) github.Repo {
+	if r := pr.GetHead().GetRepo(); r != nil {
+		return github.Repo{Owner: r.GetOwner().GetLogin(), Name: r.GetName()}
+	}
+	return repo
+}
+
+// parseHead splits a head reference, which may be "owner:branch" for forks,
+// into the repository and branch it refers to.
+func parseHead(repo github.Repo, head string) (github.Repo, string) {
+	owner, branch, ok := strings.Cut(head, ":")
+	if !ok {
+		return repo, head
+	}
+	if owner == repo.Owner {
+		return repo, branch
+	}
+	return github.Repo{Owner: owner, Name: repo.Name}, branch
+}
+
+// findPullRequest returns the pull request with the given number. Callers must hold s.mu.
+func (s *Server) findPullRequest(repo github.Repo, number int) *gogithub.PullRequest {
+	for _, pr := range s.repo(repo).pullRequests {
+		if pr.GetNumber() == number {
+			return pr
+		}
+	}
+	return nil
+}
+
+// getCommitResource serves the commits/{ref}/... endpoints. Refs may contain
+// slashes, so they can't be matched with a single path wildcard.
+func (s *Server) getCommitResource(w http.ResponseWriter, r *http.Request) {
+	rest := r.PathValue("rest")
+	if ref, ok := strings.CutSuffix(rest, "/pulls"); ok {
+		s.listPullRequestsWithCommit(w, r, ref)
+		return
+	}
+	if ref, ok := strings.CutSuffix(rest, "/check-runs"); ok {
+		s.listCheckRuns(w, r, ref)
+		return
+	}
+	if ref, ok := strings.CutSuffix(rest, "/status"); ok {
+		s.getCombinedStatus(w, r, ref)
+		return
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) listPullRequestsWithCommit(w http.ResponseWriter, r *http.Request, ref string) {
+	repo := repoFromRequest(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sha, ok := s.resolve(repo, ref)
+	if !ok {
+		sha = ref
+		if !s.isKnownCommit(repo, sha) {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("No commit found for SHA: %s", ref))
+			return
+		}
+	}
+
+	result := []*gogithub.PullRequest{}
+	for _, pr := range s.repo(repo).pullRequests {
+		snapshot := s.snapshot(repo, pr)
+		if snapshot.GetHead().GetSHA() == sha {
+			result = append(result, snapshot)
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// isKnownCommit reports whether sha is the head of any branch. Callers must hold s.mu.
+func (s *Server) isKnownCommit(repo github.Repo, sha string) bool {
+	for _, branchSHA := range s.repo(repo).branches {
+		if branchSHA == sha {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) listPullRequests(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		state = "open"
+	}
+	head := r.URL.Query().Get("head")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []*gogithub.PullRequest{}
+	for _, pr := range s.repo(repo).pullRequests {
+		if state != "all" && pr.GetState() != state {
+			continue
+		}
+		if head != "" {
+			fork, branch := parseHead(repo, head)
+			if headRepo(repo, pr) != fork || pr.GetHead().GetRef() != branch {
+				continue
+			}
+		}
+		result = append(result, s.snapshot(repo, pr))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getPullRequest(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr := s.findPullRequest(repo, number)
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.snapshot(repo, pr))
+
+	// Like GitHub, mergeability is unknown in the first response after the
+	// base changed and computed in the background
+	if state := s.repo(repo); state.recomputing[number] {
+		delete(state.recomputing, number)
+		pr.Mergeable = gogithub.Ptr(pr.GetMergeableState() != "dirty")
+	}
+}
+
+func (s *Server) createPullRequest(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+
+	var req gogithub.NewPullRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fork, branch := parseHead(repo, req.GetHead())
+
+	s.mu.Lock()
+	if _, ok := s.resolve(fork, branch); !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: head is invalid")
+		return
+	}
+	if _, ok := s.resolve(repo, req.GetBase()); !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: base is invalid")
+		return
+	}
+	for _, pr := range s.repo(repo).pullRequests {
+		if pr.GetState() == "open" && headRepo(repo, pr) == fork && pr.GetHead().GetRef() == branch {
+			s.mu.Unlock()
+			writeError(w, http.StatusUnprocessableEntity,
+				fmt.Sprintf("Validation Failed: a pull request already exists for %s", req.GetHead()))
+			return
+		}
+	}
+	s.mu.Unlock()
+
+	now := gogithub.Timestamp{Time: time.Now()}
+	pr := s.AddPullRequest(repo, &gogithub.PullRequest{
+		Title: req.Title,
+		Body:  req.Body,
+		Draft: gogithub.Ptr(req.GetDraft()),
+		Head: &gogithub.PullRequestBranch{
+			Ref:   gogithub.Ptr(branch),
+			Label: gogithub.Ptr(fork.Owner + ":" + branch),
+			Repo: &gogithub.Repository{
+				Name:  gogithub.Ptr(fork.Name),
+				Owner: &gogithub.User{Login: gogithub.Ptr(fork.Owner)},
+			},
+		},
+		Base:      &gogithub.PullRequestBranch{Ref: req.Base},
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	})
+	writeJSON(w, http.StatusCreated, pr)
+}
+
+func (s *Server) editPullRequest(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	// Only the fields GitHub allows editing are decoded.
+	var req struct {
+		Title *string `json:"title"`
+		Body  *string `json:"body"`
+		State *string `json:"state"`
+		Base  *string `json:"base"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr := s.findPullRequest(repo, number)
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	if req.Base != nil {
+		if _, ok := s.resolve(repo, *req.Base); !ok {
+			writeError(w, http.StatusUnprocessableEntity, "Validation Failed: base is invalid")
+			return
+		}
+		pr.Base = &gogithub.PullRequestBranch{Ref: req.Base}
+		pr.Mergeable = nil
+		s.repo(repo).recomputing[number] = true
+	}
+	if req.Title != nil {
+		pr.Title = req.Title
+	}
+	if req.Body != nil {
+		pr.Body = req.Body
+	}
+	if req.State != nil {
+		pr.State = req.State
+		if *req.State == "closed" {
+			pr.ClosedAt = &gogithub.Timestamp{Time: time.Now()}
+		} else {
+			pr.ClosedAt = nil
+		}
+	}
+	pr.UpdatedAt = &gogithub.Timestamp{Time: time.Now()}
+
+	writeJSON(w, http.StatusOK, s.snapshot(repo, pr))
+}
+
+func (s *Server) listCheckRuns(w http.ResponseWriter, r *http.Request, ref string) {
+	repo := repoFromRequest(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sha, ok := s.resolve(repo, ref)
+	if !ok {
+		sha = ref
+	}
+	runs := append([]*gogithub.CheckRun{}, s.repo(repo).checkRuns[sha]...)
+	writeJSON(w, http.StatusOK, &gogithub.ListCheckRunsResults{
+		Total:     gogithub.Ptr(len(runs)),
+		CheckRuns: runs,
+	})
+}
+
+func (s *Server) getCombinedStatus(w http.ResponseWriter, r *http.Request, ref string) {
+	repo := repoFromRequest(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sha, ok := s.resolve(repo, ref)
+	if !ok {
+		sha = ref
+	}
+	statuses := append([]*gogithub.RepoStatus{}, s.repo(repo).statuses[sha]...)
+	writeJSON(w, http.StatusOK, &gogithub.CombinedStatus{
+		SHA:        gogithub.Ptr(sha),
+		TotalCount: gogithub.Ptr(len(statuses)),
+		Statuses:   statuses,
+	})
+}
+
+func (s *Server) listReviews(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findPullRequest(repo, number) == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	result := []*gogithub.PullRequestReview{}
+	result = append(result, paginate(w, r, s.repo(repo).reviews[number])...)
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getRequiredChecks(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checks, ok := s.repo(repo).requiredChecks[r.PathValue("branch")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Branch not protected")
+		return
+	}
+
+	required := []*gogithub.RequiredStatusCheck{}
+	for _, name := range checks {
+		required = append(required, &gogithub.RequiredStatusCheck{Context: name})
+	}
+	writeJSON(w, http.StatusOK, &gogithub.RequiredStatusChecks{Checks: &required})
+}
+
+func (s *Server) mergePullRequest(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var req struct {
+		SHA         string `json:"sha"`
+		MergeMethod string `json:"merge_method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr := s.findPullRequest(repo, number)
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if pr.GetState() != "open" || pr.GetDraft() || pr.GetMergeableState() == "dirty" || pr.GetMergeableState() == "blocked" {
+		writeError(w, http.StatusMethodNotAllowed, "Pull Request is not mergeable")
+		return
+	}
+	if state := s.repo(repo); state.recomputing[number] || state.rejectedMerges[number] > 0 {
+		if state.rejectedMerges[number] > 0 {
+			state.rejectedMerges[number]--
+		}
+		writeError(w, http.StatusMethodNotAllowed, "Base branch was modified. Review and try the merge again.")
+		return
+	}
+	if req.SHA != "" && s.snapshot(repo, pr).GetHead().GetSHA() != req.SHA {
+		writeError(w, http.StatusConflict, "Head branch was modified. Review and try the merge again.")
+		return
+	}
+
+	method := req.MergeMethod
+	if method == "" {
+		method = "merge"
+	}
+	s.repo(repo).mergeMethods[number] = method
+	s.merge(repo, pr)
+
+	writeJSON(w, http.StatusOK, &gogithub.PullRequestMergeResult{
+		SHA:     gogithub.Ptr(fmt.Sprintf("merge-%d", number)),
+		Merged:  gogithub.Ptr(true),
+		Message: gogithub.Ptr("Pull Request successfully merged"),
+	})
+}
+
+func (s *Server) requestReviewers(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var req gogithub.ReviewersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr := s.findPullRequest(repo, number)
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	for _, login := range req.Reviewers {
+		if login == pr.GetUser().GetLogin() {
+			writeError(w, http.StatusUnprocessableEntity,
+				"Review cannot be requested from pull request author.")
+			return
+		}
+	}
+	for _, login := range req.Reviewers {
+		if !slices.ContainsFunc(pr.RequestedReviewers, func(u *gogithub.User) bool { return u.GetLogin() == login }) {
+			pr.RequestedReviewers = append(slices.Clone(pr.RequestedReviewers), &gogithub.User{Login: gogithub.Ptr(login)})
+		}
+	}
+	for _, slug := range req.TeamReviewers {
+		if !slices.ContainsFunc(pr.RequestedTeams, func(t *gogithub.Team) bool { return t.GetSlug() == slug }) {
+			pr.RequestedTeams = append(slices.Clone(pr.RequestedTeams), &gogithub.Team{Slug: gogithub.Ptr(slug)})
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, s.snapshot(repo, pr))
+}
+
+func (s *Server) addAssignees(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var req struct {
+		Assignees []string `json:"assignees"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr := s.findPullRequest(repo, number)
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	for _, login := range req.Assignees {
+		if !slices.ContainsFunc(pr.Assignees, func(u *gogithub.User) bool { return u.GetLogin() == login }) {
+			pr.Assignees = append(slices.Clone(pr.Assignees), &gogithub.User{Login: gogithub.Ptr(login)})
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, &gogithub.Issue{Number: pr.Number, Assignees: pr.Assignees})
+}
+
+func (s *Server) addLabels(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var names []string
+	if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pr := s.findPullRequest(repo, number)
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	for _, name := range names {
+		addLabel(pr, name)
+	}
+
+	writeJSON(w, http.StatusOK, pr.Labels)
+}
+
+func addLabel(pr *gogithub.PullRequest, name string) {
+	if !slices.ContainsFunc(pr.Labels, func(l *gogithub.Label) bool { return l.GetName() == name }) {
+		pr.Labels = append(slices.Clone(pr.Labels), &gogithub.Label{Name: gogithub.Ptr(name)})
+	}
+}
+
+func (s *Server) listComments(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findPullRequest(repo, number) == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	result := []*gogithub.IssueComment{}
+	result = append(result, s.repo(repo).comments[number]...)
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var req gogithub.IssueComment
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findPullRequest(repo, number) == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.nextCommentID++
+	comment := &gogithub.IssueComment{
+		ID:   gogithub.Ptr(s.nextCommentID),
+		Body: req.Body,
+		User: &gogithub.User{Login: gogithub.Ptr(DefaultUser)},
+	}
+	s.repo(repo).comments[number] = append(s.repo(repo).comments[number], comment)
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (s *Server) editComment(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var req gogithub.IssueComment
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, comments := range s.repo(repo).comments {
+		for _, comment := range comments {
+			if comment.GetID() == id {
+				comment.Body = req.Body
+				writeJSON(w, http.StatusOK, comment)
+				return
+			}
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) deleteBranch(w http.ResponseWriter, r *http.Request) {
+	repo := repoFromRequest(r)
+	branch := r.PathValue("branch")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	branches := s.repo(repo).branches
+	if _, ok := branches[branch]; !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Reference does not exist")
+		return
+	}
+	delete(branches, branch)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, &gogithub.User{Login: gogithub.Ptr(DefaultUser)})
+}
+
+func repoFromRequest(r *http.Request) github.Repo {
+	return github.Repo{Owner: r.PathValue("owner"), Name: r.PathValue("repo")}
+}
+
+// paginate returns the page of items selected by the page and per_page query
+// parameters, setting a Link header when another page follows.
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) []T {
+	perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 30
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	if end < len(items) {
+		next := *r.URL
+		query := next.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+	return items[start:end]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
@@ -0,0 +1,285 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/google/go-github/v80/github"
+	"golang.org/x/sync/errgroup"
+)
+
+// ReviewDecision summarizes the latest reviews on a pull request.
+type ReviewDecision string
+
+const (
+	ReviewNone             ReviewDecision = ""
+	ReviewApproved         ReviewDecision = "approved"
+	ReviewChangesRequested ReviewDecision = "changes_requested"
+)
+
+// CheckState summarizes the checks on a pull request head.
+type CheckState string
+
+const (
+	ChecksNone    CheckState = ""
+	ChecksPending CheckState = "pending"
+	ChecksSuccess CheckState = "success"
+	ChecksFailure CheckState = "failure"
+)
+
+// PullRequestStatus holds the review, check and merge state of an open pull request.
+type PullRequestStatus struct {
+	Review ReviewDecision
+	// Checks only considers the checks required by the base branch's
+	// protection rules, or every check if they can't be read.
+	Checks CheckState
+	// MergeableState is GitHub's mergeable_state, e.g. "clean", "blocked",
+	// "behind" or "dirty". It is "unknown" while GitHub computes it.
+	MergeableState string
+}
+
+// GetClosedPullRequestsForBranches returns the most recently closed pull
+// request for each branch that has one. Merged pull requests are closed too;
+// check MergedAt to tell them apart.
+func (c *Client) GetClosedPullRequestsForBranches(
+	ctx context.Context,
+	repo Repo,
+	headOwner string,
+	branches []string,
+) (map[string]*github.PullRequest, error) {
+	if headOwner == "" {
+		headOwner = repo.Owner
+	}
+
+	var mu sync.Mutex
+	result := make(map[string]*github.PullRequest)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(c.concurrency)
+
+	for _, branch := range branches {
+		eg.Go(func() error {
+			prs, _, err := c.client.PullRequests.List(ctx, repo.Owner, repo.Name, &github.PullRequestListOptions{
+				State: "closed",
+				Head:  headOwner + ":" + branch,
+			})
+			if err != nil {
+				return err
+			}
+			if len(prs) == 0 {
+				return nil
+			}
+
+			latest := slices.MaxFunc(prs, func(a, b *github.PullRequest) int {
+				return a.GetClosedAt().Compare(b.GetClosedAt().Time)
+			})
+
+			mu.Lock()
+			result[branch] = latest
+			mu.Unlock()
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetPullRequestStatuses returns the status of each pull request keyed by number.
+func (c *Client) GetPullRequestStatuses(
+	ctx context.Context,
+	repo Repo,
+	prs []*github.PullRequest,
+) (map[int]PullRequestStatus, error) {
+	var mu sync.Mutex
+	result := make(map[int]PullRequestStatus)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(c.concurrency)
+
+	for _, pr := range prs {
+		eg.Go(func() error {
+			status, err := c.getPullRequestStatus(ctx, repo, pr)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			result[pr.GetNumber()] = status
+			mu.Unlock()
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *Client) getPullRequestStatus(ctx context.Context, repo Repo, pr *github.PullRequest) (PullRequestStatus, error) {
+	// mergeable_state is only included when fetching a single pull request.
+	full, _, err := c.client.PullRequests.Get(ctx, repo.Owner, repo.Name, pr.GetNumber())
+	if err != nil {
+		return PullRequestStatus{}, err
+	}
+
+	reviews, err := c.listReviews(ctx, repo, pr.GetNumber())
+	if err != nil {
+		return PullRequestStatus{}, err
+	}
+
+	checks, err := c.getCheckState(ctx, repo, full.GetBase().GetRef(), full.GetHead().GetSHA())
+	if err != nil {
+		return PullRequestStatus{}, err
+	}
+
+	return PullRequestStatus{
+		Review:         reviewDecision(reviews),
+		Checks:         checks,
+		MergeableState: full.GetMergeableState(),
+	}, nil
+}
+
+// reviewDecision combines the latest approving or blocking review of each reviewer.
+func reviewDecision(reviews []*github.PullRequestReview) ReviewDecision {
+	latest := make(map[string]string)
+	for _, review := range reviews {
+		switch state := review.GetState(); state {
+		case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+			latest[review.GetUser().GetLogin()] = state
+		}
+	}
+
+	decision := ReviewNone
+	for _, state := range latest {
+		switch state {
+		case "CHANGES_REQUESTED":
+			return ReviewChangesRequested
+		case "APPROVED":
+			decision = ReviewApproved
+		}
+	}
+	return decision
+}
+
+// getCheckState combines the check runs and commit statuses on sha, limited to
+// the checks base requires when its protection rules are readable.
+func (c *Client) getCheckState(ctx context.Context, repo Repo, base, sha string) (CheckState, error) {
+	required, err := c.requiredChecks(ctx, repo, base)
+	if err != nil {
+		return ChecksNone, err
+	}
+
+	states := make(map[string]CheckState)
+
+	runs, _, err := c.client.Checks.ListCheckRunsForRef(ctx, repo.Owner, repo.Name, sha,
+		&github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}})
+	if err != nil {
+		return ChecksNone, err
+	}
+	for _, run := range runs.CheckRuns {
+		states[run.GetName()] = checkRunState(run)
+	}
+
+	combined, _, err := c.client.Repositories.GetCombinedStatus(ctx, repo.Owner, repo.Name, sha,
+		&github.ListOptions{PerPage: 100})
+	if err != nil {
+		return ChecksNone, err
+	}
+	for _, status := range combined.Statuses {
+		states[status.GetContext()] = commitStatusState(status)
+	}
+
+	if required != nil {
+		filtered := make(map[string]CheckState)
+		for _, name := range required {
+			state, ok := states[name]
+			if !ok {
+				// Required checks that haven't reported yet block merging.
+				state = ChecksPending
+			}
+			filtered[name] = state
+		}
+		states = filtered
+	}
+
+	return combineCheckStates(states), nil
+}
+
+// requiredChecks returns the check names required on branch, or nil if the
+// branch is unprotected or its protection can't be read.
+func (c *Client) requiredChecks(ctx context.Context, repo Repo, branch string) ([]string, error) {
+	checks, _, err := c.client.Repositories.GetRequiredStatusChecks(ctx, repo.Owner, repo.Name, branch)
+	if err != nil {
+		var ghErr *github.ErrorResponse
+		if errors.Is(err, github.ErrBranchNotProtected) ||
+			(errors.As(err, &ghErr) && (ghErr.Response.StatusCode == http.StatusForbidden ||
+				ghErr.Response.StatusCode == http.StatusNotFound)) {
+			// Reading branch protection requires admin access.
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	names := []string{}
+	if checks.Checks != nil {
+		for _, check := range *checks.Checks {
+			names = append(names, check.Context)
+		}
+	} else if checks.Contexts != nil {
+		names = append(names, *checks.Contexts...)
+	}
+	return names, nil
+}
+
+func checkRunState(run *github.CheckRun) CheckState {
+	if run.GetStatus() != "completed" {
+		return ChecksPending
+	}
+	switch run.GetConclusion() {
+	case "success", "neutral", "skipped":
+		return ChecksSuccess
+	default:
+		return ChecksFailure
+	}
+}
+
+func commitStatusState(status *github.RepoStatus) CheckState {
+	switch status.GetState() {
+	case "success":
+		return ChecksSuccess
+	case "pending":
+		return ChecksPending
+	default:
+		return ChecksFailure
+	}
+}
+
+// combineCheckStates reports failure if any check failed, pending if any is
+// still running and success otherwise.
+func combineCheckStates(states map[string]CheckState) CheckState {
+	if len(states) == 0 {
+		return ChecksNone
+	}
+
+	result := ChecksSuccess
+	for _, state := range states {
+		switch state {
+		case ChecksFailure:
+			return ChecksFailure
+		case ChecksPending:
+			result = ChecksPending
+		}
+	}
+	return result
+}
@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"os/exec"
 	"strings"
+
+	"github.com/BurntSushi/toml"
 )
 
 const (
-	logTemplate = `"{\"id\": \"" ++ change_id ++ "\", \"short_id\": \"" ++ change_id.shortest() ++ "\", \"commit_id\": \"" ++ commit_id ++ "\", \"immutable\": " ++ immutable ++ ", \"description\": " ++ json(description) ++ ", \"bookmarks\": " ++ json(bookmarks) ++ ", \"git_push_bookmark\": \"" ++ %s ++ "\", \"parents\": " ++ json(parents) ++ "}"`
+	logTemplate = `"{\"id\": \"" ++ change_id ++ "\", \"short_id\": \"" ++ change_id.shortest() ++ "\", \"commit_id\": \"" ++ commit_id ++ "\", \"immutable\": " ++ immutable ++ ", \"description\": " ++ json(description) ++ ", \"bookmarks\": " ++ json(bookmarks) ++ ", \"remote_bookmarks\": " ++ json(remote_bookmarks) ++ ", \"git_push_bookmark\": \"" ++ %s ++ "\", \"parents\": " ++ json(parents) ++ "}"`
 )
 
 // Change represents a Jujutsu revision with its metadata.
@@ -24,15 +26,72 @@ type Change struct {
 	Bookmarks       []struct {
 		Name string `json:"name"`
 	} `json:"bookmarks"`
+	// RemoteBookmarks are the remote bookmarks pointing at this commit.
+	RemoteBookmarks []struct {
+		Name   string `json:"name"`
+		Remote string `json:"remote"`
+	} `json:"remote_bookmarks"`
 	Parents []struct {
 		ChangeID string `json:"change_id"`
 		CommitID string `json:"commit_id"`
 	} `json:"parents"`
 }
 
+// PushedTo reports whether the push bookmark of the change already points at
+// this commit on the named remote.
+func (c Change) PushedTo(remote string) bool {
+	for _, b := range c.RemoteBookmarks {
+		if b.Name == c.GitPushBookmark && b.Remote == remote {
+			return true
+		}
+	}
+	return false
+}
+
+// Runner executes jj commands.
+type Runner interface {
+	// Output runs jj with the given arguments and returns its standard output.
+	Output(args ...string) ([]byte, error)
+	// CombinedOutput runs jj with the given arguments and returns its combined
+	// standard output and standard error.
+	CombinedOutput(args ...string) ([]byte, error)
+}
+
+// execRunner runs the jj binary found in PATH.
+type execRunner struct{}
+
+func (execRunner) Output(args ...string) ([]byte, error) {
+	return exec.Command("jj", args...).Output()
+}
+
+func (execRunner) CombinedOutput(args ...string) ([]byte, error) {
+	return exec.Command("jj", args...).CombinedOutput()
+}
+
+// Client runs Jujutsu operations through a Runner.
+type Client struct {
+	runner Runner
+}
+
+// NewClient creates a client that runs the jj binary.
+func NewClient() *Client {
+	return NewClientWithRunner(execRunner{})
+}
+
+// NewClientWithRunner creates a client that runs jj commands through runner.
+func NewClientWithRunner(runner Runner) *Client {
+	return &Client{runner: runner}
+}
+
+// LogTemplate returns the template used by GetChanges for the given
+// git_push_bookmark template expression.
+func LogTemplate(gitPushBookmark string) string {
+	return fmt.Sprintf(logTemplate, gitPushBookmark)
+}
+
 // GetChanges returns changes matching the given revsets in topological order.
-func GetChanges(revsets ...string) ([]Change, error) {
-	gitPushBookmark, err := GetTemplate("git_push_bookmark")
+func (c *Client) GetChanges(revsets ...string) ([]Change, error) {
+	gitPushBookmark, err := c.GetTemplate("git_push_bookmark")
 	if err != nil {
 		return nil, err
 	}
@@ -41,14 +100,14 @@ func GetChanges(revsets ...string) ([]Change, error) {
 		"log",
 		"--no-graph",
 		"--reversed",
-		"-T", fmt.Sprintf(logTemplate, gitPushBookmark),
+		"-T", LogTemplate(gitPushBookmark),
 	}
 
 	for _, revset := range revsets {
 		args = append(args, "-r", revset)
 	}
 
-	out, err := exec.Command("jj", args...).Output()
+	out, err := c.runner.Output(args...)
 	if err != nil {
 		var ee *exec.ExitError
 		if errors.As(err, &ee) {
@@ -71,9 +130,26 @@ func GetChanges(revsets ...string) ([]Change, error) {
 	return changes, nil
 }
 
+// StackRevset returns a revset selecting the non-empty mutable ancestors of
+// revset plus the immutable parents of their roots, which PR bases are derived
+// from. This works even if revset is not directly on top of trunk().
+func StackRevset(revset string) string {
+	return fmt.Sprintf("(roots(::(%s) & mutable())- | ::(%s) & mutable()) & ~empty()", revset, revset)
+}
+
+// Root returns the root directory of the current workspace.
+func (c *Client) Root() (string, error) {
+	output, err := c.runner.Output("root")
+	if err != nil {
+		return "", fmt.Errorf("jj root: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
 // GetTemplate returns a Jujutsu template value from the user's config.
-func GetTemplate(name string) (string, error) {
-	output, err := exec.Command("jj", "config", "get", "templates."+name).Output()
+func (c *Client) GetTemplate(name string) (string, error) {
+	output, err := c.runner.Output("config", "get", "templates."+name)
 	if err != nil {
 		return "", fmt.Errorf("get template %q: %w", name, err)
 	}
@@ -81,9 +157,24 @@ func GetTemplate(name string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// ListConfig decodes the effective config values under the given table into
+// v, as with toml.Unmarshal. Keys keep their full dotted name, so v nests the
+// table (e.g. a "jj-github" field holding "push-remote"); repo-level settings
+// already take precedence over user-level ones.
+func (c *Client) ListConfig(table string, v any) error {
+	output, err := c.runner.Output("config", "list", table)
+	if err != nil {
+		return fmt.Errorf("jj config list %s: %w", table, err)
+	}
+	if err := toml.Unmarshal(output, v); err != nil {
+		return fmt.Errorf("jj config list %s: %w", table, err)
+	}
+	return nil
+}
+
 // GetRemote returns the URL for the named Git remote.
-func GetRemote(name string) (string, error) {
-	output, err := exec.Command("jj", "git", "remote", "list").Output()
+func (c *Client) GetRemote(name string) (string, error) {
+	output, err := c.runner.Output("git", "remote", "list")
 	if err != nil {
 		return "", fmt.Errorf("jj git remote list: %w", err)
 	}
@@ -107,14 +198,36 @@ func GetRemote(name string) (string, error) {
 	return "", fmt.Errorf("remote named %q not found", name)
 }
 
-// GitPush pushes the specified change to its Git branch.
-func GitPush(changeID string) error {
-	return exec.Command("jj", "git", "push", "-c", fmt.Sprintf("change_id(%s)", changeID)).Run()
+// GitPush pushes the specified changes to their Git branches, named by
+// templates.git_push_bookmark, and the specified bookmarks as they are, on the
+// named remote in a single push. An empty remote uses jj's default push
+// remote.
+func (c *Client) GitPush(remote string, changeIDs, bookmarks []string) error {
+	args := []string{"git", "push"}
+	if remote != "" {
+		args = append(args, "--remote", remote)
+	}
+	for _, changeID := range changeIDs {
+		args = append(args, "-c", fmt.Sprintf("change_id(%s)", changeID))
+	}
+	for _, bookmark := range bookmarks {
+		args = append(args, "-b", bookmark)
+	}
+
+	_, err := c.runner.Output(args...)
+	return err
 }
 
-// GitFetch fetches from the Git remote to get the latest state.
-func GitFetch() error {
-	return exec.Command("jj", "git", "fetch").Run()
+// GitFetch fetches from the given Git remotes to get the latest state.
+// With no remotes, jj's default fetch remotes are used.
+func (c *Client) GitFetch(remotes ...string) error {
+	args := []string{"git", "fetch"}
+	for _, remote := range remotes {
+		args = append(args, "--remote", remote)
+	}
+
+	_, err := c.runner.Output(args...)
+	return err
 }
 
 // Bookmark represents a jj bookmark with its associated revision.
@@ -133,9 +246,9 @@ type Bookmark struct {
 // empty, which --skip-emptied will handle).
 //
 // Only returns roots that are NOT already parented on the current trunk commit.
-func GetStackRootsToRebase() ([]Bookmark, error) {
+func (c *Client) GetStackRootsToRebase() ([]Bookmark, error) {
 	// Get the current trunk commit ID to check if roots are already parented on it
-	trunkChanges, err := GetChanges("trunk()")
+	trunkChanges, err := c.GetChanges("trunk()")
 	if err != nil {
 		return nil, fmt.Errorf("get trunk: %w", err)
 	}
@@ -147,7 +260,7 @@ func GetStackRootsToRebase() ([]Bookmark, error) {
 	// Find "roots" - mutable revisions whose parent is immutable.
 	// The revset "roots(mutable())" gives us all mutable revisions that have no mutable ancestors.
 	// These are the starting points of all working stacks.
-	changes, err := GetChanges("roots(mutable())")
+	changes, err := c.GetChanges("roots(mutable())")
 	if err != nil {
 		return nil, fmt.Errorf("get stack roots: %w", err)
 	}
@@ -193,9 +306,8 @@ type RebaseResult struct {
 // which handles the case where a PR was squash-merged into trunk.
 // Returns whether the rebase resulted in conflicts or skipped empty commits.
 // jj treats conflicts as first-class, so we continue even if there's a conflict.
-func Rebase(source, destination string) (RebaseResult, error) {
-	cmd := exec.Command("jj", "rebase", "-s", source, "-d", destination, "--skip-emptied")
-	output, err := cmd.CombinedOutput()
+func (c *Client) Rebase(source, destination string) (RebaseResult, error) {
+	output, err := c.runner.CombinedOutput("rebase", "-s", source, "-d", destination, "--skip-emptied")
 	outputStr := string(output)
 
 	// Check for conflicts and skipped commits in output
@@ -218,10 +330,88 @@ func Rebase(source, destination string) (RebaseResult, error) {
 	return RebaseResult{HasConflict: hasConflict, SkippedEmpty: skippedEmpty}, nil
 }
 
+// Abandon abandons the given revisions, rebasing their descendants onto
+// their parents.
+func (c *Client) Abandon(revisions ...string) error {
+	output, err := c.runner.CombinedOutput(append([]string{"abandon"}, revisions...)...)
+	if err != nil {
+		return fmt.Errorf("abandon: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Squash moves the changes of the from revisions into the into revision,
+// keeping the description of into.
+func (c *Client) Squash(from, into string) error {
+	output, err := c.runner.CombinedOutput("squash", "--from", from, "--into", into, "--use-destination-message")
+	if err != nil {
+		return fmt.Errorf("squash: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// MoveBookmark points the local bookmark name at revision, creating it if
+// needed. The bookmark may move backwards or sideways.
+func (c *Client) MoveBookmark(name, revision string) error {
+	output, err := c.runner.CombinedOutput("bookmark", "set", name, "-r", revision, "--allow-backwards")
+	if err != nil {
+		return fmt.Errorf("bookmark set %s: %s", name, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// DeleteBookmarks deletes the named local bookmarks. Tracked remote
+// bookmarks are left for the next fetch or push.
+func (c *Client) DeleteBookmarks(names ...string) error {
+	args := []string{"bookmark", "delete"}
+	for _, name := range names {
+		args = append(args, "exact:"+name)
+	}
+	output, err := c.runner.CombinedOutput(args...)
+	if err != nil {
+		return fmt.Errorf("bookmark delete: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// ListFiles returns the paths of the files in revision matching any of the
+// filesets. Paths are relative to the current directory, as printed by jj.
+func (c *Client) ListFiles(revision string, filesets ...string) ([]string, error) {
+	args := append([]string{"file", "list", "-r", revision, "--"}, filesets...)
+	output, err := c.runner.Output(args...)
+	if err != nil {
+		return nil, fmt.Errorf("jj file list: %w", err)
+	}
+
+	var paths []string
+	for line := range strings.Lines(string(output)) {
+		if path := strings.TrimRight(line, "\r\n"); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// ShowFile returns the contents of the file at path in revision. path is
+// relative to the current directory, as returned by ListFiles.
+func (c *Client) ShowFile(revision, path string) (string, error) {
+	output, err := c.runner.Output("file", "show", "-r", revision, "--", FilePattern(path))
+	if err != nil {
+		return "", fmt.Errorf("jj file show %s: %w", path, err)
+	}
+	return string(output), nil
+}
+
+// FilePattern returns a fileset matching exactly the file at path.
+func FilePattern(path string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(path)
+	return `file:"` + escaped + `"`
+}
+
 // GetTrunkName returns the name of the trunk bookmark (e.g., "main" or "master").
-func GetTrunkName() (string, error) {
+func (c *Client) GetTrunkName() (string, error) {
 	// Get the trunk revision and its bookmarks
-	changes, err := GetChanges("trunk()")
+	changes, err := c.GetChanges("trunk()")
 	if err != nil {
 		return "", err
 	}
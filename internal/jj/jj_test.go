@@ -0,0 +1,237 @@
+package jj
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectLog scripts the two jj invocations made by GetChanges for revset.
+func expectLog(r *jjtest.Runner, revset string, output string) {
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(output, "log", "--no-graph", "--reversed", "-T", LogTemplate(jjtest.PushBookmark), "-r", revset)
+}
+
+func TestGetChanges(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Output   string
+		Expected []Change
+	}{
+		{
+			Name:   "empty",
+			Output: "",
+		},
+		{
+			Name: "multiple changes",
+			Output: `{"id": "zzz", "short_id": "z", "commit_id": "000", "immutable": true, "description": "", "bookmarks": [{"name": "main"}], "git_push_bookmark": "push-zzz", "parents": []}` +
+				`{"id": "abc", "short_id": "a", "commit_id": "111", "immutable": false, "description": "Add feature\n\nBody\n", "bookmarks": [], "git_push_bookmark": "push-abc", "parents": [{"change_id": "zzz", "commit_id": "000"}]}`,
+			Expected: []Change{
+				{
+					ID:              "zzz",
+					ShortID:         "z",
+					CommitID:        "000",
+					Immutable:       true,
+					GitPushBookmark: "push-zzz",
+					Bookmarks: []struct {
+						Name string `json:"name"`
+					}{{Name: "main"}},
+					Parents: []struct {
+						ChangeID string `json:"change_id"`
+						CommitID string `json:"commit_id"`
+					}{},
+				},
+				{
+					ID:              "abc",
+					ShortID:         "a",
+					CommitID:        "111",
+					GitPushBookmark: "push-abc",
+					Description:     "Add feature\n\nBody\n",
+					Bookmarks: []struct {
+						Name string `json:"name"`
+					}{},
+					Parents: []struct {
+						ChangeID string `json:"change_id"`
+						CommitID string `json:"commit_id"`
+					}{{ChangeID: "zzz", CommitID: "000"}},
+				},
+			},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := jjtest.NewRunner()
+			expectLog(r, "@", tc.Output)
+
+			changes, err := NewClientWithRunner(r).GetChanges("@")
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, changes)
+			assert.Empty(t, r.Unused())
+		})
+	}
+}
+
+func TestPushedTo(t *testing.T) {
+	r := jjtest.NewRunner()
+	expectLog(r, "@", `{"id": "abc", "short_id": "a", "commit_id": "111", "immutable": false, "description": "", "bookmarks": [], "remote_bookmarks": [{"name": "push-abc", "remote": "fork"}, {"name": "other", "remote": "origin"}], "git_push_bookmark": "push-abc", "parents": []}`)
+
+	changes, err := NewClientWithRunner(r).GetChanges("@")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.True(t, changes[0].PushedTo("fork"))
+	assert.False(t, changes[0].PushedTo("origin"), "another bookmark on the remote doesn't count")
+}
+
+func TestGetChangesInvalidOutput(t *testing.T) {
+	r := jjtest.NewRunner()
+	expectLog(r, "@", `{"id": `)
+
+	_, err := NewClientWithRunner(r).GetChanges("@")
+	require.Error(t, err)
+}
+
+func TestGetRemote(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Output   string
+		Remote   string
+		Expected string
+		Error    bool
+	}{
+		{
+			Name:     "single remote",
+			Output:   "origin git@github.com:cbrewster/jj-github.git\n",
+			Remote:   "origin",
+			Expected: "git@github.com:cbrewster/jj-github.git",
+		},
+		{
+			Name:     "multiple remotes",
+			Output:   "origin git@github.com:me/jj-github.git\n\nupstream https://github.com/cbrewster/jj-github.git\n",
+			Remote:   "upstream",
+			Expected: "https://github.com/cbrewster/jj-github.git",
+		},
+		{
+			Name:   "not found",
+			Output: "origin git@github.com:cbrewster/jj-github.git\n",
+			Remote: "upstream",
+			Error:  true,
+		},
+		{
+			Name:   "malformed line",
+			Output: "origin\n",
+			Remote: "origin",
+			Error:  true,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := jjtest.NewRunner().Expect(tc.Output, "git", "remote", "list")
+
+			url, err := NewClientWithRunner(r).GetRemote(tc.Remote)
+			if tc.Error {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, url)
+		})
+	}
+}
+
+func TestRebase(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Output   string
+		Err      error
+		Expected RebaseResult
+		Error    bool
+	}{
+		{
+			Name:   "clean",
+			Output: "Rebased 3 commits onto destination\n",
+		},
+		{
+			Name:     "conflict",
+			Output:   "Rebased 2 commits\nNew conflicts appeared in 1 commits:\n",
+			Expected: RebaseResult{HasConflict: true},
+		},
+		{
+			Name:     "skipped empty",
+			Output:   "Skipped rebase of 1 commits that were already in place\n",
+			Expected: RebaseResult{SkippedEmpty: true},
+		},
+		{
+			Name:     "abandoned empty",
+			Output:   "Abandoned 1 newly emptied commits\nWorking copy became empty\n",
+			Expected: RebaseResult{SkippedEmpty: true},
+		},
+		{
+			Name:     "exit error with conflict",
+			Output:   "There are unresolved conflicts\n",
+			Err:      jjtest.ExitError(""),
+			Expected: RebaseResult{HasConflict: true},
+		},
+		{
+			Name:   "exit error",
+			Output: "Error: Revision \"xyz\" doesn't exist\n",
+			Err:    jjtest.ExitError(""),
+			Error:  true,
+		},
+		{
+			Name:  "other error",
+			Err:   errors.New("exec: \"jj\": executable file not found in $PATH"),
+			Error: true,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := jjtest.NewRunner().ExpectError(tc.Output, tc.Err, "rebase", "-s", "abc", "-d", "trunk()", "--skip-emptied")
+
+			result, err := NewClientWithRunner(r).Rebase("abc", "trunk()")
+			if tc.Error {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, result)
+		})
+	}
+}
+
+func TestGetTrunkName(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Output   string
+		Expected string
+	}{
+		{
+			Name:     "trunk bookmark",
+			Output:   `{"id": "zzz", "short_id": "z", "commit_id": "000", "immutable": true, "description": "", "bookmarks": [{"name": "master"}], "git_push_bookmark": "push-zzz", "parents": []}`,
+			Expected: "master",
+		},
+		{
+			Name:     "no bookmarks",
+			Output:   `{"id": "zzz", "short_id": "z", "commit_id": "000", "immutable": true, "description": "", "bookmarks": [], "git_push_bookmark": "push-zzz", "parents": []}`,
+			Expected: "main",
+		},
+		{
+			Name:     "no trunk",
+			Output:   "",
+			Expected: "main",
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := jjtest.NewRunner()
+			expectLog(r, "trunk()", tc.Output)
+
+			name, err := NewClientWithRunner(r).GetTrunkName()
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, name)
+		})
+	}
+}
+
+func TestUnexpectedCall(t *testing.T) {
+	_, err := NewClientWithRunner(jjtest.NewRunner()).GetTemplate("git_push_bookmark")
+	require.Error(t, err)
+}
@@ -0,0 +1,120 @@
+// Package jjtest provides a scripted jj.Runner for tests.
+package jjtest
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// PushBookmark is the templates.git_push_bookmark setting tests script.
+const PushBookmark = `"push-" ++ change_id.short()`
+
+// Trunk is the jj log output for trunk: change zzzz at commit c0, bookmarked
+// main.
+const Trunk = `{"id": "zzzz", "short_id": "z", "commit_id": "c0", "immutable": true, "description": "Initial commit\n", "bookmarks": [{"name": "main"}], "git_push_bookmark": "push-zzzz", "parents": []}`
+
+// Change returns the jj log output for a mutable change on top of parent,
+// with the bookmark PushBookmark names.
+func Change(id, commitID, description, parentID, parentCommitID string) string {
+	return fmt.Sprintf(`{"id": %q, "short_id": %q, "commit_id": %q, "immutable": false, "description": %q, "bookmarks": [], "git_push_bookmark": "push-%s", "parents": [{"change_id": %q, "commit_id": %q}]}`,
+		id, id[:1], commitID, description, id, parentID, parentCommitID)
+}
+
+// Response is a scripted result for a jj invocation with the given arguments.
+type Response struct {
+	Args   []string
+	Output string
+	Err    error
+}
+
+// Runner is a fake jj.Runner that replays scripted responses.
+// Each call consumes the first unused response whose arguments match exactly;
+// calls without a matching response fail the invocation.
+type Runner struct {
+	mu        sync.Mutex
+	responses []Response
+	used      []bool
+	calls     [][]string
+}
+
+// NewRunner creates a runner with no scripted responses.
+func NewRunner() *Runner {
+	return &Runner{}
+}
+
+// Expect scripts a successful invocation with the given output.
+func (r *Runner) Expect(output string, args ...string) *Runner {
+	return r.ExpectResponse(Response{Args: args, Output: output})
+}
+
+// ExpectError scripts a failing invocation with the given output and error.
+func (r *Runner) ExpectError(output string, err error, args ...string) *Runner {
+	return r.ExpectResponse(Response{Args: args, Output: output, Err: err})
+}
+
+// ExpectResponse scripts an arbitrary response.
+func (r *Runner) ExpectResponse(resp Response) *Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.responses = append(r.responses, resp)
+	r.used = append(r.used, false)
+	return r
+}
+
+// Output implements jj.Runner.
+func (r *Runner) Output(args ...string) ([]byte, error) {
+	return r.run(args)
+}
+
+// CombinedOutput implements jj.Runner.
+func (r *Runner) CombinedOutput(args ...string) ([]byte, error) {
+	return r.run(args)
+}
+
+// Calls returns the arguments of every invocation so far.
+func (r *Runner) Calls() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.calls)
+}
+
+// Unused returns the scripted responses that were never consumed.
+func (r *Runner) Unused() []Response {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Response
+	for i, resp := range r.responses {
+		if !r.used[i] {
+			unused = append(unused, resp)
+		}
+	}
+	return unused
+}
+
+func (r *Runner) run(args []string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, args)
+
+	for i, resp := range r.responses {
+		if r.used[i] || !slices.Equal(resp.Args, args) {
+			continue
+		}
+		r.used[i] = true
+		return []byte(resp.Output), resp.Err
+	}
+
+	return nil, fmt.Errorf("jjtest: unexpected call: jj %s", strings.Join(args, " "))
+}
+
+// ExitError returns an error that looks like a non-zero exit of the jj binary.
+func ExitError(stderr string) error {
+	return &exec.ExitError{Stderr: []byte(stderr)}
+}
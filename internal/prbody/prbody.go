@@ -0,0 +1,164 @@
+// Package prbody manages the sections of a pull request body owned by
+// jj-github. Sections are delimited by HTML comments, which GitHub doesn't
+// render, so the rest of the body (such as a filled-in pull request template)
+// can be edited on GitHub without being overwritten by the next submit.
+package prbody
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Section names used in pull request bodies.
+const (
+	// Description holds the body of the jj revision description.
+	Description = "description"
+	// Stack lists the pull requests of the stack.
+	Stack = "stack"
+)
+
+// Placement is where the description goes in a pull request template that
+// has no placeholder for it.
+type Placement string
+
+const (
+	PlacementTop    Placement = "top"
+	PlacementBottom Placement = "bottom"
+)
+
+// ParsePlacement validates a placement name.
+func ParsePlacement(s string) (Placement, error) {
+	switch p := Placement(strings.ToLower(s)); p {
+	case PlacementTop, PlacementBottom:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown placement %q, expected top or bottom", s)
+	}
+}
+
+// Placeholder returns the comment a template uses to mark where section name
+// goes, e.g. "<!-- jj-github:description -->".
+func Placeholder(name string) string {
+	return "<!-- jj-github:" + name + " -->"
+}
+
+func endMarker(name string) string {
+	return "<!-- /jj-github:" + name + " -->"
+}
+
+// Wrap returns content delimited as section name.
+func Wrap(name, content string) string {
+	return Placeholder(name) + "\n" + strings.Trim(content, "\n") + "\n" + endMarker(name)
+}
+
+// Get returns the content of section name in body.
+func Get(body, name string) (string, bool) {
+	start, end, ok := find(body, name)
+	if !ok {
+		return "", false
+	}
+	return strings.Trim(strings.ReplaceAll(body[start:end], "\r\n", "\n"), "\n"), true
+}
+
+// Replace sets the content of section name in body, leaving everything else
+// untouched. It reports false if body has no such section.
+func Replace(body, name, content string) (string, bool) {
+	start, end, ok := find(body, name)
+	if !ok {
+		return body, false
+	}
+	return body[:start] + "\n" + strings.Trim(content, "\n") + "\n" + body[end:], true
+}
+
+// Set is Replace, but adds the section at the end of body if it is missing.
+func Set(body, name, content string) string {
+	if replaced, ok := Replace(body, name, content); ok {
+		return replaced
+	}
+	if strings.TrimSpace(body) == "" {
+		return Wrap(name, content)
+	}
+	return strings.TrimRight(body, "\r\n") + "\n\n" + Wrap(name, content)
+}
+
+// Remove deletes section name, including its markers, from body.
+func Remove(body, name string) string {
+	start, end, ok := find(body, name)
+	if !ok {
+		return body
+	}
+
+	before := strings.TrimRight(body[:start-len(Placeholder(name))], "\r\n")
+	after := strings.TrimLeft(body[end+len(endMarker(name)):], "\r\n")
+	if before == "" || after == "" {
+		return before + after
+	}
+	return before + "\n\n" + after
+}
+
+// find returns the bounds of the content of section name, between its markers.
+func find(body, name string) (int, int, bool) {
+	open := strings.Index(body, Placeholder(name))
+	if open < 0 {
+		return 0, 0, false
+	}
+	start := open + len(Placeholder(name))
+
+	end := strings.Index(body[start:], endMarker(name))
+	if end < 0 {
+		return 0, 0, false
+	}
+	return start, start + end, true
+}
+
+// changeIDPrefix starts the comment recording the change a body belongs to
+const changeIDPrefix = "<!-- jj-github:change-id "
+
+// ChangeIDMarker returns the hidden comment recording the jj change a pull
+// request belongs to, e.g. "<!-- jj-github:change-id kxqz -->".
+func ChangeIDMarker(changeID string) string {
+	return changeIDPrefix + changeID + " -->"
+}
+
+// ChangeID returns the change ID recorded in body by ChangeIDMarker.
+func ChangeID(body string) (string, bool) {
+	_, rest, ok := strings.Cut(body, changeIDPrefix)
+	if !ok {
+		return "", false
+	}
+	id, _, ok := strings.Cut(rest, " -->")
+	if !ok || id == "" || strings.ContainsAny(id, " \r\n") {
+		return "", false
+	}
+	return id, true
+}
+
+// SetChangeID records changeID in body, replacing the change recorded before.
+// A new marker goes at the end of body.
+func SetChangeID(body, changeID string) string {
+	if old, ok := ChangeID(body); ok {
+		return strings.Replace(body, ChangeIDMarker(old), ChangeIDMarker(changeID), 1)
+	}
+	if strings.TrimSpace(body) == "" {
+		return ChangeIDMarker(changeID)
+	}
+	return strings.TrimRight(body, "\r\n") + "\n\n" + ChangeIDMarker(changeID)
+}
+
+// ApplyTemplate places section name holding content into template: at its
+// placeholder if it has one, otherwise above or below it.
+func ApplyTemplate(template, name, content string, placement Placement) string {
+	section := Wrap(name, content)
+	template = strings.Trim(template, "\n")
+
+	if strings.Contains(template, Placeholder(name)) {
+		return strings.Replace(template, Placeholder(name), section, 1)
+	}
+	if template == "" {
+		return section
+	}
+	if placement == PlacementBottom {
+		return template + "\n\n" + section
+	}
+	return section + "\n\n" + template
+}
@@ -0,0 +1,78 @@
+package prbody
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		Name      string
+		Template  string
+		Placement Placement
+		Expected  string
+	}{
+		{
+			Name:      "top",
+			Template:  "## Checklist\n",
+			Placement: PlacementTop,
+			Expected:  "<!-- jj-github:description -->\nBody\n<!-- /jj-github:description -->\n\n## Checklist",
+		},
+		{
+			Name:      "bottom",
+			Template:  "## Checklist\n",
+			Placement: PlacementBottom,
+			Expected:  "## Checklist\n\n<!-- jj-github:description -->\nBody\n<!-- /jj-github:description -->",
+		},
+		{
+			Name:      "placeholder",
+			Template:  "## Summary\n\n<!-- jj-github:description -->\n\n## Checklist\n",
+			Placement: PlacementBottom,
+			Expected:  "## Summary\n\n<!-- jj-github:description -->\nBody\n<!-- /jj-github:description -->\n\n## Checklist",
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, ApplyTemplate(tc.Template, Description, "\nBody\n", tc.Placement))
+		})
+	}
+}
+
+func TestReplace(t *testing.T) {
+	body := "Intro\r\n" + Wrap(Description, "Old") + "\r\n- [x] Done\r\n"
+
+	replaced, ok := Replace(body, Description, "New\nlines")
+	assert.True(t, ok)
+	assert.Equal(t, "Intro\r\n<!-- jj-github:description -->\nNew\nlines\n<!-- /jj-github:description -->\r\n- [x] Done\r\n", replaced)
+
+	content, ok := Get(replaced, Description)
+	assert.True(t, ok)
+	assert.Equal(t, "New\nlines", content)
+
+	assert.Equal(t, "Intro\n\n"+Wrap(Stack, "- #1"), Set("Intro\r\n", Stack, "- #1"))
+	assert.Equal(t, Wrap(Stack, "- #1"), Set("", Stack, "- #1"))
+	assert.Equal(t, "Intro\n\nOutro", Remove("Intro\n"+Wrap(Stack, "- #1")+"\n\nOutro", Stack))
+	assert.Equal(t, "Intro", Remove("Intro\n\n"+Wrap(Stack, "- #1")+"\n", Stack))
+
+	_, ok = Replace("No sections here", Description, "New")
+	assert.False(t, ok)
+	_, ok = Get("<!-- jj-github:description -->\nunterminated", Description)
+	assert.False(t, ok)
+}
+
+func TestChangeID(t *testing.T) {
+	body := SetChangeID("Description\n", "kxqz")
+	assert.Equal(t, "Description\n\n<!-- jj-github:change-id kxqz -->", body)
+
+	id, ok := ChangeID(body)
+	assert.True(t, ok)
+	assert.Equal(t, "kxqz", id)
+
+	assert.Equal(t, "Description\n\n<!-- jj-github:change-id abcd -->", SetChangeID(body, "abcd"), "the marker is replaced")
+	assert.Equal(t, ChangeIDMarker("kxqz"), SetChangeID("", "kxqz"))
+
+	_, ok = ChangeID("No marker")
+	assert.False(t, ok)
+	_, ok = ChangeID("<!-- jj-github:change-id not an id -->")
+	assert.False(t, ok)
+}
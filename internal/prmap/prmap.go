@@ -0,0 +1,216 @@
+// Package prmap remembers which pull request belongs to which jj change, so
+// a pull request is found again after its branch is renamed, for example by
+// changing templates.git_push_bookmark.
+package prmap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/prbody"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// Path returns where the store of the workspace at root is kept, next to
+// jj's own data so it never ends up in a commit.
+func Path(root string) string {
+	return filepath.Join(root, ".jj", "jj-github", "prs.json")
+}
+
+// Store maps change IDs to pull request numbers per GitHub repository. A nil
+// Store remembers nothing.
+type Store struct {
+	path string
+	// prs maps "host/owner/name" to change IDs to pull request numbers.
+	prs      map[string]map[string]int
+	readOnly bool
+}
+
+// version is the layout of the file written by Save. Version 1 keyed
+// repositories without their host.
+const version = 2
+
+// file is the JSON layout of the store
+type file struct {
+	Version      int                       `json:"version"`
+	PullRequests map[string]map[string]int `json:"pull_requests"`
+}
+
+// Load reads the store at path. A missing file is an empty store.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, prs: make(map[string]map[string]int)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if f.Version < 2 {
+		// Before GitHub Enterprise hosts were told apart, everything was
+		// assumed to be on github.com
+		for repo, changes := range f.PullRequests {
+			s.prs[github.DefaultHost+"/"+repo] = changes
+		}
+		return s, nil
+	}
+	if f.PullRequests != nil {
+		s.prs = f.PullRequests
+	}
+	return s, nil
+}
+
+// Get returns the pull request recorded for changeID in repo.
+func (s *Store) Get(repo github.Repo, changeID string) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+	number, ok := s.prs[key(repo)][changeID]
+	return number, ok
+}
+
+// Set records the pull request of changeID in repo.
+func (s *Store) Set(repo github.Repo, changeID string, number int) {
+	if s == nil {
+		return
+	}
+	if s.prs[key(repo)] == nil {
+		s.prs[key(repo)] = make(map[string]int)
+	}
+	s.prs[key(repo)][changeID] = number
+}
+
+// Delete forgets the pull request of changeID in repo.
+func (s *Store) Delete(repo github.Repo, changeID string) {
+	if s == nil {
+		return
+	}
+	delete(s.prs[key(repo)], changeID)
+}
+
+// DeletePullRequest forgets pull request number in repo, whichever change it
+// was recorded for.
+func (s *Store) DeletePullRequest(repo github.Repo, number int) {
+	if s == nil {
+		return
+	}
+	maps.DeleteFunc(s.prs[key(repo)], func(_ string, n int) bool { return n == number })
+}
+
+// ReadOnly returns a copy of the store that is never saved, for commands that
+// must not change anything, such as a dry run.
+func (s *Store) ReadOnly() *Store {
+	if s == nil {
+		return nil
+	}
+	prs := make(map[string]map[string]int, len(s.prs))
+	for repo, changes := range s.prs {
+		prs[repo] = maps.Clone(changes)
+	}
+	return &Store{path: s.path, prs: prs, readOnly: true}
+}
+
+// Save writes the store back to its file. The file is replaced atomically,
+// so an interrupted save never leaves it truncated.
+func (s *Store) Save() error {
+	if s == nil || s.readOnly {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(file{Version: version, PullRequests: s.prs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".prs-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Find returns the open pull requests of changes in repo, keyed by change ID.
+// The PR recorded for a change is looked up by number first, so it is found
+// even after the change's bookmark was renamed and its branch no longer
+// matches. A recorded PR that was closed or merged, or whose body names
+// another change, is forgotten, and the change is looked up by branch like
+// the others. Every PR found is recorded; Save keeps them for the next run.
+func (s *Store) Find(ctx context.Context, gh *github.Client, repo github.Repo, headOwner string, changes []jj.Change) (map[string]*gogithub.PullRequest, error) {
+	var numbers []int
+	for _, change := range changes {
+		if number, ok := s.Get(repo, change.ID); ok {
+			numbers = append(numbers, number)
+		}
+	}
+
+	recorded, err := gh.GetPullRequests(ctx, repo, numbers)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]*gogithub.PullRequest)
+	var branches []string
+	for _, change := range changes {
+		number, ok := s.Get(repo, change.ID)
+		if !ok {
+			branches = append(branches, change.GitPushBookmark)
+			continue
+		}
+
+		pr, ok := recorded[number]
+		if id, marked := prbody.ChangeID(pr.GetBody()); !ok || pr.GetState() != "open" || (marked && id != change.ID) {
+			s.Delete(repo, change.ID)
+			branches = append(branches, change.GitPushBookmark)
+			continue
+		}
+		found[change.ID] = pr
+	}
+
+	byBranch, err := gh.GetPullRequestsForBranches(ctx, repo, headOwner, branches)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range changes {
+		if _, ok := found[change.ID]; ok {
+			continue
+		}
+		if pr, ok := byBranch[change.GitPushBookmark]; ok {
+			found[change.ID] = pr
+		}
+	}
+
+	for id, pr := range found {
+		s.Set(repo, id, pr.GetNumber())
+	}
+	return found, nil
+}
+
+func key(repo github.Repo) string {
+	return repo.WebHost() + "/" + repo.Owner + "/" + repo.Name
+}
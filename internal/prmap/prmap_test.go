@@ -0,0 +1,93 @@
+package prmap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	repo := github.Repo{Owner: "owner", Name: "repo"}
+	fork := github.Repo{Owner: "me", Name: "repo"}
+	enterprise := github.Repo{Host: "github.example.com", Owner: "owner", Name: "repo"}
+	path := Path(t.TempDir())
+
+	s, err := Load(path)
+	require.NoError(t, err)
+	_, ok := s.Get(repo, "aaaa")
+	assert.False(t, ok, "a missing file is an empty store")
+
+	s.Set(repo, "aaaa", 1)
+	s.Set(repo, "bbbb", 2)
+	s.Set(fork, "aaaa", 7)
+	s.Set(enterprise, "aaaa", 9)
+	s.Delete(repo, "bbbb")
+	require.NoError(t, s.Save())
+
+	s, err = Load(path)
+	require.NoError(t, err)
+	number, ok := s.Get(repo, "aaaa")
+	assert.True(t, ok)
+	assert.Equal(t, 1, number)
+	number, _ = s.Get(fork, "aaaa")
+	assert.Equal(t, 7, number, "repositories are kept apart")
+	number, _ = s.Get(enterprise, "aaaa")
+	assert.Equal(t, 9, number, "so are hosts")
+	_, ok = s.Get(repo, "bbbb")
+	assert.False(t, ok)
+}
+
+func TestLoadVersion1(t *testing.T) {
+	path := Path(t.TempDir())
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 1, "pull_requests": {"owner/repo": {"aaaa": 1}}}`), 0o644))
+
+	s, err := Load(path)
+	require.NoError(t, err)
+	number, ok := s.Get(github.Repo{Owner: "owner", Name: "repo"}, "aaaa")
+	assert.True(t, ok, "repositories without a host are on github.com")
+	assert.Equal(t, 1, number)
+	_, ok = s.Get(github.Repo{Host: "github.example.com", Owner: "owner", Name: "repo"}, "aaaa")
+	assert.False(t, ok)
+}
+
+func TestReadOnly(t *testing.T) {
+	repo := github.Repo{Owner: "owner", Name: "repo"}
+	path := Path(t.TempDir())
+
+	s, err := Load(path)
+	require.NoError(t, err)
+	s.Set(repo, "aaaa", 1)
+
+	ro := s.ReadOnly()
+	ro.Set(repo, "bbbb", 2)
+	ro.Delete(repo, "aaaa")
+	require.NoError(t, ro.Save())
+	assert.NoFileExists(t, path)
+
+	number, ok := s.Get(repo, "aaaa")
+	assert.True(t, ok, "the original store is unchanged")
+	assert.Equal(t, 1, number)
+	_, ok = s.Get(repo, "bbbb")
+	assert.False(t, ok)
+}
+
+func TestNilStore(t *testing.T) {
+	var s *Store
+	s.Set(github.Repo{}, "aaaa", 1)
+	_, ok := s.Get(github.Repo{}, "aaaa")
+	assert.False(t, ok)
+	assert.NoError(t, s.Save())
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prs.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+
+	_, err := Load(path)
+	assert.ErrorContains(t, err, "parse")
+}
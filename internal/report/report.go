@@ -0,0 +1,105 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SchemaVersion is the version of the JSON report schema.
+// It must be bumped whenever a field is removed or its meaning changes.
+const SchemaVersion = 1
+
+// Action describes what submit did with a revision's pull request
+type Action string
+
+const (
+	ActionCreated   Action = "created"
+	ActionUpdated   Action = "updated"
+	ActionUnchanged Action = "unchanged"
+	ActionFailed    Action = "failed"
+	ActionSkipped   Action = "skipped" // Not attempted because an earlier revision failed
+	// Left alone because its branch has commits that weren't pushed from jj
+	ActionSkippedForeign Action = "skipped-foreign"
+)
+
+// SubmitReport is the machine-readable result of `jj-github submit`
+type SubmitReport struct {
+	Version   int              `json:"version"`
+	Command   string           `json:"command"`
+	Revisions []SubmitRevision `json:"revisions"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// SubmitRevision describes the outcome for a single revision
+type SubmitRevision struct {
+	ChangeID string `json:"change_id"`
+	CommitID string `json:"commit_id"`
+	Branch   string `json:"branch"`
+	Base     string `json:"base"`
+	PRNumber int    `json:"pr_number,omitempty"`
+	PRURL    string `json:"pr_url,omitempty"`
+	Action   Action `json:"action"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NewSubmitReport creates an empty submit report with the current schema version
+func NewSubmitReport() SubmitReport {
+	return SubmitReport{
+		Version:   SchemaVersion,
+		Command:   "submit",
+		Revisions: []SubmitRevision{},
+	}
+}
+
+// StackState is the final state of a stack after sync
+type StackState string
+
+const (
+	StackPending  StackState = "pending"
+	StackRebased  StackState = "rebased"
+	StackSkipped  StackState = "skipped"
+	StackConflict StackState = "conflict"
+	StackFailed   StackState = "failed"
+)
+
+// SyncReport is the machine-readable result of `jj-github sync`
+type SyncReport struct {
+	Version int         `json:"version"`
+	Command string      `json:"command"`
+	Trunk   string      `json:"trunk"`
+	Stacks  []SyncStack `json:"stacks"`
+	// DeletedBranches are the branches of merged or closed PRs that were
+	// deleted along with their bookmarks
+	DeletedBranches []string `json:"deleted_branches,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// SyncStack describes the outcome for a single rebased stack
+type SyncStack struct {
+	ChangeID string     `json:"change_id"`
+	CommitID string     `json:"commit_id"`
+	Bookmark string     `json:"bookmark,omitempty"`
+	State    StackState `json:"state"`
+	Merged   []int      `json:"merged,omitempty"` // PRs of the stack merged into trunk
+	// Diverged are merged PRs whose revisions changed since, so were rebased
+	Diverged []int `json:"diverged,omitempty"`
+	// PullRequests are the PRs of the stack after `sync --submit`
+	PullRequests []SubmitRevision `json:"pull_requests,omitempty"`
+	Error        string           `json:"error,omitempty"`
+}
+
+// NewSyncReport creates an empty sync report with the current schema version
+func NewSyncReport() SyncReport {
+	return SyncReport{
+		Version: SchemaVersion,
+		Command: "sync",
+		Stacks:  []SyncStack{},
+	}
+}
+
+// Write encodes the report as indented JSON
+func Write(w io.Writer, report any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,34 @@
+// Package reporttest provides golden file assertions for JSON reports in tests.
+package reporttest
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// AssertGolden compares the JSON encoding of v against testdata/name.
+// Run `go test -update` to regenerate the golden file.
+func AssertGolden(t *testing.T, name string, v any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, report.Write(&buf, v))
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), buf.String())
+}
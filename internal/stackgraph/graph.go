@@ -0,0 +1,292 @@
+// Package stackgraph lays out a stack of revisions as a text graph, like
+// `jj log`, and renders it as the Markdown used in stack comments.
+package stackgraph
+
+import (
+	"slices"
+	"strings"
+)
+
+// Glyphs drawn for nodes.
+const (
+	GlyphNode    = "○"
+	GlyphCurrent = "●"
+	GlyphTrunk   = "◆"
+)
+
+// Node is a revision in a stack.
+type Node struct {
+	ID string
+	// Parents are the IDs of the parent revisions. Parents that aren't in the
+	// stack are on trunk.
+	Parents []string
+	// Glyph marks the node in the graph. Defaults to GlyphNode.
+	Glyph string
+}
+
+// Row is a line of the graph: either a node or the edges between nodes.
+type Row struct {
+	Graph string
+	// Node is the index of the node drawn on this row, len(nodes) for trunk,
+	// or -1 for rows that only draw edges.
+	Node int
+	// Column is the column of the node. Each column is two cells wide.
+	Column int
+	// Continuation is the graph to draw on any extra lines below a node row,
+	// before the next row.
+	Continuation string
+}
+
+// TopoOrder returns the indices of nodes with parents before their children,
+// keeping each branch together: a revision is followed by its first child's
+// descendants before its other children. Ties keep the order of nodes.
+func TopoOrder(nodes []Node) []int {
+	index := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		index[node.ID] = i
+	}
+
+	children := make([][]int, len(nodes))
+	pending := make([]int, len(nodes))
+	for i, node := range nodes {
+		var seen []int
+		for _, parent := range node.Parents {
+			p, ok := index[parent]
+			if !ok || slices.Contains(seen, p) {
+				continue
+			}
+			seen = append(seen, p)
+			children[p] = append(children[p], i)
+			pending[i]++
+		}
+	}
+
+	// Ready nodes are taken from the end, so push them in reverse.
+	var ready []int
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	order := make([]int, 0, len(nodes))
+	for len(ready) > 0 {
+		i := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		order = append(order, i)
+
+		for _, child := range slices.Backward(children[i]) {
+			pending[child]--
+			if pending[child] == 0 {
+				ready = append(ready, child)
+			}
+		}
+	}
+	return order
+}
+
+// Lineage returns the IDs of id and its ancestors and descendants among nodes.
+func Lineage(nodes []Node, id string) map[string]bool {
+	parents := make(map[string][]string, len(nodes))
+	children := make(map[string][]string, len(nodes))
+	for _, node := range nodes {
+		parents[node.ID] = node.Parents
+		for _, parent := range node.Parents {
+			children[parent] = append(children[parent], node.ID)
+		}
+	}
+
+	result := map[string]bool{id: true}
+	for _, edges := range []map[string][]string{parents, children} {
+		queue := []string{id}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			for _, next := range edges[current] {
+				if _, ok := parents[next]; ok && !result[next] {
+					result[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// trunkID is the column ID of trunk, which can't clash with change IDs.
+const trunkID = "\x00trunk"
+
+// Layout draws nodes, given children before their parents, as a graph with
+// trunk at the bottom. Sibling stacks get their own columns, which join again
+// at their common parent.
+func Layout(nodes []Node) []Row {
+	inStack := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		inStack[node.ID] = true
+	}
+
+	l := &layout{}
+	for i, node := range nodes {
+		glyph := node.Glyph
+		if glyph == "" {
+			glyph = GlyphNode
+		}
+
+		var parents []string
+		for _, parent := range node.Parents {
+			if !inStack[parent] {
+				parent = trunkID
+			}
+			if !slices.Contains(parents, parent) {
+				parents = append(parents, parent)
+			}
+		}
+		if len(parents) == 0 {
+			parents = []string{trunkID}
+		}
+
+		l.draw(i, node.ID, glyph, parents)
+	}
+	l.draw(len(nodes), trunkID, GlyphTrunk, nil)
+
+	return l.rows
+}
+
+type layout struct {
+	// columns holds the ID of the node each column leads to, or "" for a
+	// column that is free.
+	columns []string
+	rows    []Row
+}
+
+// draw adds the rows for a node: edges joining the columns that lead to it,
+// the node itself, and edges splitting off to its extra parents.
+func (l *layout) draw(node int, id, glyph string, parents []string) {
+	idx := slices.Index(l.columns, id)
+	if idx < 0 {
+		idx = l.freeColumn(0)
+		l.columns[idx] = id
+	}
+
+	var joined []int
+	for p := idx + 1; p < len(l.columns); p++ {
+		if l.columns[p] == id {
+			joined = append(joined, p)
+		}
+	}
+	if len(joined) > 0 {
+		l.edgeRow(idx, joined, "╯", "┴")
+		for _, p := range joined {
+			l.columns[p] = ""
+		}
+	}
+
+	cells := make([]string, len(l.columns))
+	for p, column := range l.columns {
+		switch {
+		case p == idx:
+			cells[p] = glyph
+		case column == "":
+			cells[p] = " "
+		default:
+			cells[p] = "│"
+		}
+	}
+	row := Row{Graph: joinCells(cells, -1, -1), Node: node, Column: idx}
+
+	if len(parents) == 0 {
+		l.columns[idx] = ""
+		l.trim()
+		l.rows = append(l.rows, row)
+		return
+	}
+
+	l.columns[idx] = parents[0]
+	row.Continuation = l.continuation()
+	l.rows = append(l.rows, row)
+	var split []int
+	for _, parent := range parents[1:] {
+		p := l.freeColumn(idx + 1)
+		l.columns[p] = parent
+		split = append(split, p)
+	}
+	if len(split) > 0 {
+		l.edgeRow(idx, split, "╮", "┬")
+	}
+	l.trim()
+}
+
+// edgeRow draws a horizontal edge from column from to each of the targets,
+// which must be to its right and in increasing order.
+func (l *layout) edgeRow(from int, targets []int, end, mid string) {
+	last := targets[len(targets)-1]
+	cells := make([]string, len(l.columns))
+	for p, column := range l.columns {
+		switch {
+		case p == from:
+			cells[p] = "├"
+		case p == last:
+			cells[p] = end
+		case slices.Contains(targets, p):
+			cells[p] = mid
+		case p > from && p < last && column != "":
+			cells[p] = "┼"
+		case p > from && p < last:
+			cells[p] = "─"
+		case column == "":
+			cells[p] = " "
+		default:
+			cells[p] = "│"
+		}
+	}
+	l.rows = append(l.rows, Row{Graph: joinCells(cells, from, last), Node: -1, Column: from})
+}
+
+// continuation draws the columns passing through a line
+func (l *layout) continuation() string {
+	cells := make([]string, len(l.columns))
+	for p, column := range l.columns {
+		if column == "" {
+			cells[p] = " "
+		} else {
+			cells[p] = "│"
+		}
+	}
+	return joinCells(cells, -1, -1)
+}
+
+// freeColumn returns the first free column at or after start, adding one if
+// there is none.
+func (l *layout) freeColumn(start int) int {
+	for p := start; p < len(l.columns); p++ {
+		if l.columns[p] == "" {
+			return p
+		}
+	}
+	l.columns = append(l.columns, "")
+	return len(l.columns) - 1
+}
+
+// trim drops free columns at the right edge.
+func (l *layout) trim() {
+	for len(l.columns) > 0 && l.columns[len(l.columns)-1] == "" {
+		l.columns = l.columns[:len(l.columns)-1]
+	}
+}
+
+// joinCells joins the cells of a row, connecting the cells between from and
+// to with horizontal lines.
+func joinCells(cells []string, from, to int) string {
+	var b strings.Builder
+	for p, cell := range cells {
+		if p > 0 {
+			if p > from && p <= to {
+				b.WriteString("─")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(cell)
+	}
+	return strings.TrimRight(b.String(), " ")
+}
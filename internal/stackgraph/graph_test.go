@@ -0,0 +1,181 @@
+package stackgraph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// render draws the layout with the node IDs next to their rows.
+func render(nodes []Node) string {
+	var b strings.Builder
+	for _, row := range Layout(nodes) {
+		b.WriteString(row.Graph)
+		switch {
+		case row.Node == len(nodes):
+			b.WriteString("  trunk")
+		case row.Node >= 0:
+			b.WriteString("  " + nodes[row.Node].ID)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestLayout(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Nodes    []Node
+		Expected string
+	}{
+		{
+			Name:     "empty",
+			Expected: "◆  trunk\n",
+		},
+		{
+			Name: "linear",
+			Nodes: []Node{
+				{ID: "c", Parents: []string{"b"}},
+				{ID: "b", Parents: []string{"a"}, Glyph: GlyphCurrent},
+				{ID: "a", Parents: []string{"main"}},
+			},
+			Expected: `○  c
+●  b
+○  a
+◆  trunk
+`,
+		},
+		{
+			Name: "siblings",
+			Nodes: []Node{
+				{ID: "c", Parents: []string{"a"}},
+				{ID: "b", Parents: []string{"a"}},
+				{ID: "a", Parents: []string{"main"}},
+			},
+			Expected: `○  c
+│ ○  b
+├─╯
+○  a
+◆  trunk
+`,
+		},
+		{
+			Name: "separate stacks on trunk",
+			Nodes: []Node{
+				{ID: "b", Parents: []string{"main"}},
+				{ID: "a", Parents: []string{"main"}},
+			},
+			Expected: `○  b
+│ ○  a
+├─╯
+◆  trunk
+`,
+		},
+		{
+			Name: "merge",
+			Nodes: []Node{
+				{ID: "m", Parents: []string{"a", "b"}},
+				{ID: "b", Parents: []string{"main"}},
+				{ID: "a", Parents: []string{"main"}},
+			},
+			Expected: `○  m
+├─╮
+│ ○  b
+○ │  a
+├─╯
+◆  trunk
+`,
+		},
+		{
+			Name: "three children joining",
+			Nodes: []Node{
+				{ID: "d", Parents: []string{"a"}},
+				{ID: "c", Parents: []string{"a"}},
+				{ID: "b", Parents: []string{"a"}},
+				{ID: "a", Parents: []string{"main"}},
+			},
+			Expected: `○  d
+│ ○  c
+│ │ ○  b
+├─┴─╯
+○  a
+◆  trunk
+`,
+		},
+		{
+			Name: "join crossing another column",
+			Nodes: []Node{
+				{ID: "e", Parents: []string{"b"}},
+				{ID: "d", Parents: []string{"a"}},
+				{ID: "c", Parents: []string{"b"}},
+				{ID: "b", Parents: []string{"main"}},
+				{ID: "a", Parents: []string{"main"}},
+			},
+			Expected: `○  e
+│ ○  d
+│ │ ○  c
+├─┼─╯
+○ │  b
+│ ○  a
+├─╯
+◆  trunk
+`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, render(tc.Nodes))
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	prs := []PullRequest{
+		{Node: Node{ID: "c", Parents: []string{"a"}}, Number: 3, Title: "Add *logout*", State: StateDraft},
+		{Node: Node{ID: "b", Parents: []string{"a"}}, Number: 2, Title: "Add login", State: StateOpen},
+		{Node: Node{ID: "a", Parents: []string{"main"}}, Number: 1, Title: "Add auth", State: StateMerged},
+	}
+
+	expected := "`○` ⚪ #3 Add \\*logout\\*\\\n" +
+		"`│ ●` 🟢 **#2 Add login** ←\\\n" +
+		"`├─╯`\\\n" +
+		"`○` 🟣 #1 Add auth\\\n" +
+		"`◆` `main`\n"
+	assert.Equal(t, expected, Markdown(prs, 2, "main"))
+	assert.NotContains(t, Markdown(prs, 0, "main"), "←", "no PR is highlighted")
+
+	prs[0].AlsoDependsOn = []int{4, 5}
+	assert.Contains(t, Markdown(prs, 3, "main"), "**#3 Add \\*logout\\*** ← (also depends on #4, #5)\\\n")
+}
+
+func TestTopoOrder(t *testing.T) {
+	// a has two branches, b-d and c, given interleaved like jj log does.
+	nodes := []Node{
+		{ID: "a"},
+		{ID: "b", Parents: []string{"a"}},
+		{ID: "c", Parents: []string{"a"}},
+		{ID: "d", Parents: []string{"b"}},
+		{ID: "e"},
+	}
+	assert.Equal(t, []int{0, 1, 3, 2, 4}, TopoOrder(nodes))
+
+	// A merge waits for all its parents.
+	nodes = []Node{
+		{ID: "a"},
+		{ID: "m", Parents: []string{"a", "b"}},
+		{ID: "b"},
+	}
+	assert.Equal(t, []int{0, 2, 1}, TopoOrder(nodes))
+}
+
+func TestLineage(t *testing.T) {
+	nodes := []Node{
+		{ID: "d", Parents: []string{"b"}},
+		{ID: "c", Parents: []string{"a"}},
+		{ID: "b", Parents: []string{"a"}},
+		{ID: "a", Parents: []string{"main"}},
+	}
+	assert.Equal(t, map[string]bool{"a": true, "b": true, "d": true}, Lineage(nodes, "b"))
+	assert.Equal(t, map[string]bool{"a": true, "c": true}, Lineage(nodes, "c"))
+	assert.Len(t, Lineage(nodes, "a"), 4)
+}
@@ -0,0 +1,100 @@
+package stackgraph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// State is the state of a pull request.
+type State string
+
+const (
+	StateOpen   State = "open"
+	StateDraft  State = "draft"
+	StateMerged State = "merged"
+	StateClosed State = "closed"
+)
+
+// stateIcons are shown before each pull request.
+var stateIcons = map[State]string{
+	StateOpen:   "🟢",
+	StateDraft:  "⚪",
+	StateMerged: "🟣",
+	StateClosed: "🔴",
+}
+
+// PullRequest is a pull request in a stack.
+type PullRequest struct {
+	Node
+	Number int
+	Title  string
+	State  State
+	// AlsoDependsOn lists the pull requests merged into this one besides its
+	// base, which GitHub can't show.
+	AlsoDependsOn []int
+}
+
+// Markdown renders the stack of pull requests, given children before their
+// parents, as a graph with trunk at the bottom. The pull request numbered
+// current is highlighted. Lines end with a backslash, a Markdown hard line
+// break, so GitHub keeps them apart.
+func Markdown(prs []PullRequest, current int, trunkName string) string {
+	nodes := make([]Node, len(prs))
+	for i, pr := range prs {
+		nodes[i] = pr.Node
+		if pr.Number == current {
+			nodes[i].Glyph = GlyphCurrent
+		}
+	}
+
+	rows := Layout(nodes)
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		line := "`" + row.Graph + "`"
+		switch {
+		case row.Node == len(prs):
+			line += " `" + trunkName + "`"
+		case row.Node >= 0:
+			line += " " + label(prs[row.Node], current)
+		}
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\\\n") + "\n"
+}
+
+// label describes a pull request on its graph row
+func label(pr PullRequest, current int) string {
+	text := fmt.Sprintf("#%d %s", pr.Number, escape(pr.Title))
+	if pr.Number == current {
+		text = "**" + text + "** ←"
+	}
+	if icon, ok := stateIcons[pr.State]; ok {
+		text = icon + " " + text
+	}
+	if len(pr.AlsoDependsOn) > 0 {
+		numbers := make([]string, len(pr.AlsoDependsOn))
+		for i, n := range pr.AlsoDependsOn {
+			numbers[i] = fmt.Sprintf("#%d", n)
+		}
+		text += " (also depends on " + strings.Join(numbers, ", ") + ")"
+	}
+	return text
+}
+
+// markdownEscaper escapes characters that would format a title
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+	">", `\>`,
+	"~", `\~`,
+)
+
+func escape(s string) string {
+	return markdownEscaper.Replace(s)
+}
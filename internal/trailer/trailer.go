@@ -0,0 +1,111 @@
+// Package trailer extracts git-style trailers such as "Reviewers: @alice"
+// from the last paragraph of a revision description.
+package trailer
+
+import (
+	"strings"
+)
+
+// Extract removes the trailers named by keys (case-insensitive) from the
+// trailer block at the end of body and returns the remaining body with their
+// values, one per trailer, keyed by the lowercased trailer name. Use Users or
+// List to split the values.
+//
+// The trailer block is the last paragraph of body, and only counts if every
+// line in it looks like "Key: value". Other trailers, such as Signed-off-by,
+// are left in place.
+func Extract(body string, keys ...string) (string, map[string][]string) {
+	values := make(map[string][]string)
+
+	trimmed := strings.Trim(body, "\n")
+	if trimmed == "" {
+		return body, values
+	}
+
+	head, block := "", trimmed
+	if i := strings.LastIndex(trimmed, "\n\n"); i >= 0 {
+		head, block = trimmed[:i], strings.TrimLeft(trimmed[i:], "\n")
+	}
+
+	var kept []string
+	for line := range strings.Lines(block) {
+		line = strings.TrimRight(line, "\n")
+		key, value, ok := parseLine(line)
+		if !ok {
+			// Not a trailer block after all.
+			return body, make(map[string][]string)
+		}
+		if !matches(key, keys) {
+			kept = append(kept, line)
+			continue
+		}
+		key = strings.ToLower(key)
+		values[key] = append(values[key], value)
+	}
+
+	if len(values) == 0 {
+		return body, values
+	}
+
+	rest := strings.TrimRight(head, "\n")
+	if len(kept) > 0 {
+		if rest != "" {
+			rest += "\n\n"
+		}
+		rest += strings.Join(kept, "\n")
+	}
+	if rest == "" {
+		return "", values
+	}
+
+	// Keep the blank line that separates the body from the title.
+	leading := body[:len(body)-len(strings.TrimLeft(body, "\n"))]
+	return leading + rest + "\n", values
+}
+
+// parseLine splits a "Key: value" trailer line
+func parseLine(line string) (string, string, bool) {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok || key == "" || strings.ContainsAny(key, " \t") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+func matches(key string, keys []string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Users splits comma or space separated lists of GitHub users or teams,
+// removing leading "@"s
+func Users(values ...string) []string {
+	var result []string
+	for _, value := range values {
+		for _, field := range strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		}) {
+			if field = strings.TrimPrefix(field, "@"); field != "" {
+				result = append(result, field)
+			}
+		}
+	}
+	return result
+}
+
+// List splits comma separated lists, such as labels, which may contain spaces
+func List(values ...string) []string {
+	var result []string
+	for _, value := range values {
+		for field := range strings.SplitSeq(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				result = append(result, field)
+			}
+		}
+	}
+	return result
+}
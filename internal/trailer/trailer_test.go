@@ -0,0 +1,76 @@
+package trailer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Body     string
+		Rest     string
+		Expected map[string][]string
+	}{
+		{
+			Name:     "no trailers",
+			Body:     "\nSome details\n",
+			Rest:     "\nSome details\n",
+			Expected: map[string][]string{},
+		},
+		{
+			Name: "trailers after body",
+			Body: "\nSome details\n\nReviewers: @alice, @org/team\nassignees: bob\n",
+			Rest: "\nSome details\n",
+			Expected: map[string][]string{
+				"reviewers": {"@alice, @org/team"},
+				"assignees": {"bob"},
+			},
+		},
+		{
+			Name: "only trailers",
+			Body: "\nReviewer: @alice @carol\n",
+			Rest: "",
+			Expected: map[string][]string{
+				"reviewer": {"@alice @carol"},
+			},
+		},
+		{
+			Name: "unrelated trailers are kept",
+			Body: "\nDetails\n\nReviewers: alice\nSigned-off-by: Bob <bob@example.com>\n",
+			Rest: "\nDetails\n\nSigned-off-by: Bob <bob@example.com>\n",
+			Expected: map[string][]string{
+				"reviewers": {"alice"},
+			},
+		},
+		{
+			Name:     "prose in the last paragraph",
+			Body:     "\nDetails\n\nReviewers: alice\nplease take a look\n",
+			Rest:     "\nDetails\n\nReviewers: alice\nplease take a look\n",
+			Expected: map[string][]string{},
+		},
+		{
+			Name:     "trailer not in the last paragraph",
+			Body:     "\nReviewers: alice\n\nMore details\n",
+			Rest:     "\nReviewers: alice\n\nMore details\n",
+			Expected: map[string][]string{},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			rest, values := Extract(tc.Body, "reviewer", "reviewers", "assignee", "assignees")
+			assert.Equal(t, tc.Rest, rest)
+			assert.Equal(t, tc.Expected, values)
+		})
+	}
+}
+
+func TestUsers(t *testing.T) {
+	assert.Equal(t, []string{"alice", "org/team", "carol", "bob"}, Users("@alice, @org/team @carol", "bob"))
+	assert.Nil(t, Users("", " , "))
+}
+
+func TestList(t *testing.T) {
+	assert.Equal(t, []string{"good first issue", "db", "api"}, List("good first issue, db", "api"))
+	assert.Nil(t, List("", " , "))
+}
@@ -0,0 +1,472 @@
+// Package cleanup closes the pull requests of revisions that no longer exist,
+// such as abandoned revisions or ones squashed into another revision.
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/prbody"
+	"github.com/cbrewster/jj-github/internal/prmap"
+	"github.com/cbrewster/jj-github/internal/tui/components"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// Phase represents the current phase of the cleanup workflow
+type Phase int
+
+const (
+	PhaseLoading Phase = iota
+	PhaseNothingToClean
+	PhaseConfirmation
+	PhaseCleaning
+	PhaseComplete
+	PhaseError
+)
+
+// closeComment explains on an orphaned pull request why it was closed
+const closeComment = "Closed by jj-github: revision `%s` no longer exists, e.g. because it was abandoned or squashed into another revision."
+
+// Orphan is an open pull request whose revision no longer exists
+type Orphan struct {
+	PR *gogithub.PullRequest
+	// ChangeID is the change the PR was opened for. PRs found by branch name
+	// only know a prefix of it.
+	ChangeID string
+}
+
+// Retarget is a pull request based on the branch of an orphan, which moves to
+// the nearest base that is kept
+type Retarget struct {
+	PR   *gogithub.PullRequest
+	Base string
+}
+
+// ItemState is the progress of a single pull request
+type ItemState int
+
+const (
+	StatePending ItemState = iota
+	StateInProgress
+	StateDone
+	StateError
+)
+
+// Messages for async operations
+type (
+	LoadedMsg struct {
+		Orphans   []Orphan
+		Retargets []Retarget
+		Err       error
+	}
+
+	RetargetedMsg struct {
+		Number int
+		Err    error
+	}
+
+	ClosedMsg struct {
+		Number int
+		Err    error
+	}
+)
+
+// Model is the main bubbletea model for the cleanup TUI
+type Model struct {
+	// State
+	phase   Phase
+	spinner components.Spinner
+	keys    KeyMap
+	err     error
+	width   int
+
+	// Dependencies
+	ctx      context.Context
+	jj       *jj.Client
+	gh       *github.Client
+	cfg      config.Config
+	repo     github.Repo
+	headRepo github.Repo
+	prStore  *prmap.Store
+
+	// Data from loading phase
+	orphans   []Orphan
+	retargets []Retarget
+
+	// Progress tracking. Retargets run first, so no PR loses its base when
+	// a branch is deleted.
+	states map[int]ItemState // Keyed by PR number
+	index  int
+}
+
+// Options configures which repositories cleanup works on
+type Options struct {
+	// Repo is the repository pull requests are opened against.
+	Repo github.Repo
+	// HeadRepo is the repository branches are pushed to. Defaults to Repo.
+	HeadRepo github.Repo
+	// Config holds the jj-github settings.
+	Config config.Config
+	// PRs remembers the pull request of each change. Closed PRs are
+	// forgotten. Optional.
+	PRs *prmap.Store
+}
+
+// NewModel creates a new cleanup TUI model
+func NewModel(ctx context.Context, jjClient *jj.Client, gh *github.Client, opts Options) Model {
+	headRepo := opts.HeadRepo
+	if headRepo == (github.Repo{}) {
+		headRepo = opts.Repo
+	}
+
+	return Model{
+		phase:    PhaseLoading,
+		spinner:  components.NewSpinner(),
+		keys:     DefaultKeyMap(),
+		ctx:      ctx,
+		jj:       jjClient,
+		gh:       gh,
+		cfg:      opts.Config,
+		repo:     opts.Repo,
+		headRepo: headRepo,
+		prStore:  opts.PRs,
+		states:   make(map[int]ItemState),
+	}
+}
+
+// Init initializes the model and starts looking for orphaned pull requests
+func (m Model) Init() tea.Cmd {
+	return tea.Batch(
+		m.spinner.Tick(),
+		m.loadCmd(),
+	)
+}
+
+// Update handles messages and updates the model
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Clean) && m.phase == PhaseConfirmation:
+			return m.startCleanup()
+		}
+
+	case LoadedMsg:
+		if msg.Err != nil {
+			m.phase = PhaseError
+			m.err = msg.Err
+			return m, tea.Quit
+		}
+
+		m.orphans = msg.Orphans
+		m.retargets = msg.Retargets
+		if len(m.orphans) == 0 {
+			m.phase = PhaseNothingToClean
+			return m, tea.Quit
+		}
+
+		m.phase = PhaseConfirmation
+		return m, nil
+
+	case RetargetedMsg:
+		return m.finish(msg.Number, msg.Err)
+
+	case ClosedMsg:
+		return m.finish(msg.Number, msg.Err)
+	}
+
+	var cmd tea.Cmd
+	m.spinner, cmd = m.spinner.Update(msg)
+	return m, cmd
+}
+
+// startCleanup leaves the confirmation phase and starts on the first PR
+func (m Model) startCleanup() (Model, tea.Cmd) {
+	m.phase = PhaseCleaning
+	m.index = 0
+	cmd := m.nextCmd()
+	return m, cmd
+}
+
+// finish records the outcome for a PR and moves on to the next one
+func (m Model) finish(number int, err error) (Model, tea.Cmd) {
+	if err != nil {
+		m.states[number] = StateError
+		m.phase = PhaseError
+		m.err = err
+		return m, tea.Quit
+	}
+
+	m.states[number] = StateDone
+	m.index++
+	cmd := m.nextCmd()
+	return m, cmd
+}
+
+// nextCmd starts on the next PR, finishing the workflow once there are none
+// left
+func (m *Model) nextCmd() tea.Cmd {
+	if m.index < len(m.retargets) {
+		return m.retargetCmd(m.retargets[m.index])
+	}
+	if i := m.index - len(m.retargets); i < len(m.orphans) {
+		return m.closeCmd(m.orphans[i])
+	}
+	m.phase = PhaseComplete
+	return tea.Quit
+}
+
+// View renders the UI
+func (m Model) View() string {
+	var sb strings.Builder
+
+	switch m.phase {
+	case PhaseLoading:
+		sb.WriteString(m.spinner.View())
+		sb.WriteString(" Looking for orphaned pull requests...\n")
+
+	case PhaseNothingToClean:
+		sb.WriteString(components.SuccessStyle.Render(components.GraphSuccess))
+		sb.WriteString(" No orphaned pull requests.\n")
+
+	case PhaseConfirmation:
+		sb.WriteString(m.renderItems())
+		fmt.Fprintf(&sb, "\n%d pull request(s) will be closed and their branches deleted.\n\n", len(m.orphans))
+		sb.WriteString(components.AccentStyle.Render(m.keys.Clean.Help().Key + " " + m.keys.Clean.Help().Desc))
+		sb.WriteString(components.MutedStyle.Render(" • " + m.keys.Quit.Help().Key + " " + m.keys.Quit.Help().Desc))
+		sb.WriteString("\n")
+
+	case PhaseCleaning:
+		sb.WriteString(m.renderItems())
+		sb.WriteString("\nCleaning up...\n")
+
+	case PhaseComplete:
+		sb.WriteString(m.renderItems())
+		sb.WriteString("\n")
+		sb.WriteString(components.SuccessStyle.Render(fmt.Sprintf("%d pull request(s) closed.", len(m.orphans))))
+		sb.WriteString("\n")
+
+	case PhaseError:
+		if len(m.orphans) > 0 {
+			sb.WriteString(m.renderItems())
+			sb.WriteString("\n")
+		}
+		sb.WriteString(components.ErrorStyle.Render(components.GraphError + " Cleanup failed"))
+		sb.WriteString("\n\n")
+		if m.err != nil {
+			sb.WriteString(components.ErrorStyle.Render(m.err.Error()))
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// renderItems lists the orphaned PRs and the PRs based on them
+func (m Model) renderItems() string {
+	var sb strings.Builder
+	sb.WriteString("Pull requests of revisions that no longer exist:\n\n")
+	for _, orphan := range m.orphans {
+		sb.WriteString(m.renderItem(orphan.PR, components.MutedStyle.Render(" ("+orphan.PR.GetHead().GetRef()+")")))
+	}
+
+	if len(m.retargets) > 0 {
+		sb.WriteString("\nPull requests based on them:\n\n")
+		for _, retarget := range m.retargets {
+			sb.WriteString(m.renderItem(retarget.PR, components.MutedStyle.Render(" → "+retarget.Base)))
+		}
+	}
+	return sb.String()
+}
+
+// renderItem renders a single PR with its progress
+func (m Model) renderItem(pr *gogithub.PullRequest, suffix string) string {
+	var glyph string
+	switch m.states[pr.GetNumber()] {
+	case StatePending:
+		glyph = components.MutedStyle.Render(components.GraphPending)
+	case StateInProgress:
+		glyph = components.YellowStyle.Render(m.spinner.View())
+	case StateDone:
+		glyph = components.SuccessStyle.Render(components.GraphSuccess)
+	case StateError:
+		glyph = components.ErrorStyle.Render(components.GraphError)
+	}
+	return fmt.Sprintf("%s #%d %s%s\n", glyph, pr.GetNumber(), pr.GetTitle(), suffix)
+}
+
+// Commands for async operations
+
+func (m Model) loadCmd() tea.Cmd {
+	return func() tea.Msg {
+		// Revisions pushed from another clone are only known after a fetch
+		if err := m.jj.GitFetch(m.cfg.FetchRemotes()...); err != nil {
+			return LoadedMsg{Err: fmt.Errorf("git fetch: %w", err)}
+		}
+
+		template, err := m.jj.GetTemplate("git_push_bookmark")
+		if err != nil {
+			return LoadedMsg{Err: err}
+		}
+
+		login, err := m.gh.CurrentUser(m.ctx)
+		if err != nil {
+			return LoadedMsg{Err: fmt.Errorf("get current user: %w", err)}
+		}
+
+		prs, err := m.gh.ListOpenPullRequests(m.ctx, m.repo)
+		if err != nil {
+			return LoadedMsg{Err: err}
+		}
+
+		candidates := m.candidates(prs, login, bookmarkPrefix(template))
+		if len(candidates) == 0 {
+			return LoadedMsg{}
+		}
+
+		// Commits only kept by their remote bookmark, like a pushed revision
+		// that was abandoned since, aren't visible heads.
+		revsets := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			revsets[i] = fmt.Sprintf("change_id(%s)", candidate.ChangeID)
+		}
+		changes, err := m.jj.GetChanges(fmt.Sprintf("(%s) & ::visible_heads()", strings.Join(revsets, " | ")))
+		if err != nil {
+			return LoadedMsg{Err: err}
+		}
+
+		orphans := slices.DeleteFunc(candidates, func(o Orphan) bool {
+			return slices.ContainsFunc(changes, func(c jj.Change) bool {
+				return strings.HasPrefix(c.ID, o.ChangeID)
+			})
+		})
+
+		return LoadedMsg{Orphans: orphans, Retargets: retargets(prs, orphans)}
+	}
+}
+
+// candidates returns the open PRs of login that were opened by jj-github from
+// the head repository, with the change each one belongs to: the change ID
+// recorded in the body, or else the one in a branch named by the push
+// bookmark template.
+func (m Model) candidates(prs []*gogithub.PullRequest, login, prefix string) []Orphan {
+	var candidates []Orphan
+	for _, pr := range prs {
+		if pr.GetUser().GetLogin() != login || pr.GetHead().GetRepo().GetOwner().GetLogin() != m.headRepo.Owner {
+			continue
+		}
+
+		id, ok := prbody.ChangeID(pr.GetBody())
+		if !ok && prefix != "" {
+			id, ok = strings.CutPrefix(pr.GetHead().GetRef(), prefix)
+		}
+		if ok && isChangeID(id) {
+			candidates = append(candidates, Orphan{PR: pr, ChangeID: id})
+		}
+	}
+	return candidates
+}
+
+// retargets returns the open PRs based on the branch of an orphan, with the
+// base to move each one to: the base of the orphan, or of the orphan below it
+// if that is one too.
+func retargets(prs []*gogithub.PullRequest, orphans []Orphan) []Retarget {
+	baseOf := make(map[string]string, len(orphans))
+	closing := make(map[int]bool, len(orphans))
+	for _, orphan := range orphans {
+		baseOf[orphan.PR.GetHead().GetRef()] = orphan.PR.GetBase().GetRef()
+		closing[orphan.PR.GetNumber()] = true
+	}
+
+	var result []Retarget
+	for _, pr := range prs {
+		base := pr.GetBase().GetRef()
+		if closing[pr.GetNumber()] {
+			continue
+		}
+		if _, ok := baseOf[base]; !ok {
+			continue
+		}
+		for range orphans {
+			next, ok := baseOf[base]
+			if !ok {
+				break
+			}
+			base = next
+		}
+		result = append(result, Retarget{PR: pr, Base: base})
+	}
+	return result
+}
+
+// bookmarkPrefix returns the literal text templates.git_push_bookmark starts
+// with, e.g. "push-" for `"push-" ++ change_id.short()`, or "" if the
+// template doesn't start with a string followed by more.
+func bookmarkPrefix(template string) string {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(template), `"`)
+	if !ok {
+		return ""
+	}
+	prefix, rest, ok := strings.Cut(rest, `"`)
+	if !ok || strings.Contains(prefix, `\`) || !strings.HasPrefix(strings.TrimSpace(rest), "++") {
+		return ""
+	}
+	return prefix
+}
+
+// isChangeID reports whether s looks like a jj change ID, which is written
+// with the letters k to z
+func isChangeID(s string) bool {
+	return s != "" && strings.Trim(s, "klmnopqrstuvwxyz") == ""
+}
+
+func (m Model) retargetCmd(retarget Retarget) tea.Cmd {
+	number := retarget.PR.GetNumber()
+	m.states[number] = StateInProgress
+
+	return func() tea.Msg {
+		err := m.gh.SetPullRequestBase(m.ctx, m.repo, number, retarget.Base)
+		if err != nil {
+			err = fmt.Errorf("retarget PR #%d: %w", number, err)
+		}
+		return RetargetedMsg{Number: number, Err: err}
+	}
+}
+
+// closeCmd explains why an orphan is closed, closes it and deletes its branch
+func (m Model) closeCmd(orphan Orphan) tea.Cmd {
+	number := orphan.PR.GetNumber()
+	m.states[number] = StateInProgress
+
+	return func() tea.Msg {
+		body := fmt.Sprintf(closeComment, orphan.ChangeID)
+		if err := m.gh.CreatePullRequestComment(m.ctx, m.repo, number, body); err != nil {
+			return ClosedMsg{Number: number, Err: fmt.Errorf("comment on PR #%d: %w", number, err)}
+		}
+		if err := m.gh.ClosePullRequest(m.ctx, m.repo, number); err != nil {
+			return ClosedMsg{Number: number, Err: fmt.Errorf("close PR #%d: %w", number, err)}
+		}
+		if err := m.gh.DeleteBranch(m.ctx, m.headRepo, orphan.PR.GetHead().GetRef()); err != nil {
+			return ClosedMsg{Number: number, Err: fmt.Errorf("delete branch %s: %w", orphan.PR.GetHead().GetRef(), err)}
+		}
+
+		m.prStore.DeletePullRequest(m.repo, number)
+		if err := m.prStore.Save(); err != nil {
+			return ClosedMsg{Number: number, Err: fmt.Errorf("save pull request map: %w", err)}
+		}
+		return ClosedMsg{Number: number}
+	}
+}
@@ -0,0 +1,116 @@
+package cleanup
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/prmap"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHeadless(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	abandoned := server.OpenPullRequest(githubtest.DefaultRepo, "push-kkkk", "main", "c-kkkk", nil)
+	kept := server.OpenPullRequest(githubtest.DefaultRepo, "push-llll", "main", "c-llll", nil)
+	child := server.OpenPullRequest(githubtest.DefaultRepo, "push-mmmm", "push-kkkk", "c-mmmm", nil)
+	others := server.OpenPullRequest(githubtest.DefaultRepo, "push-nnnn", "main", "c-nnnn", &gogithub.PullRequest{
+		User: &gogithub.User{Login: gogithub.Ptr("alice")},
+	})
+	unrelated := server.OpenPullRequest(githubtest.DefaultRepo, "feature", "main", "c-feature", nil)
+	renamed := server.OpenPullRequest(githubtest.DefaultRepo, "jj/oooo", "main", "c-oooo", &gogithub.PullRequest{
+		Body: gogithub.Ptr("Body\n\n<!-- jj-github:change-id oooo -->"),
+	})
+
+	path := prmap.Path(t.TempDir())
+	store, err := prmap.Load(path)
+	require.NoError(t, err)
+	store.Set(githubtest.DefaultRepo, "kkkkkkkk", abandoned.GetNumber())
+	store.Set(githubtest.DefaultRepo, "llllllll", kept.GetNumber())
+
+	r := jjtest.NewRunner()
+	r.Expect("", "git", "fetch")
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.Change("llll", "c-llll", "Change llll\n", "zzzz", "c0")+jjtest.Change("mmmm", "c-mmmm", "Change mmmm\n", "zzzz", "c0"), "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark),
+		"-r", "(change_id(kkkk) | change_id(llll) | change_id(mmmm) | change_id(oooo)) & ::visible_heads()")
+
+	var out strings.Builder
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), server.Client(t), Options{
+		Repo:   githubtest.DefaultRepo,
+		Config: config.Default(),
+		PRs:    store,
+	}), &out)
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.Equal(t, PhaseComplete, m.phase)
+	assert.Contains(t, out.String(), fmt.Sprintf("PR #%d: now targets main\n", child.GetNumber()))
+	assert.Contains(t, out.String(), fmt.Sprintf("PR #%d: closed, deleted push-kkkk\n", abandoned.GetNumber()))
+
+	prs := server.PullRequests(githubtest.DefaultRepo)
+	state := func(pr *gogithub.PullRequest) string { return prs[pr.GetNumber()-1].GetState() }
+	assert.Equal(t, "closed", state(abandoned))
+	assert.Equal(t, "closed", state(renamed), "found by the change ID in its body")
+	assert.Equal(t, "open", state(kept))
+	assert.Equal(t, "open", state(child))
+	assert.Equal(t, "open", state(others), "PRs of other users are left alone")
+	assert.Equal(t, "open", state(unrelated), "branches not named by jj are left alone")
+	assert.Equal(t, "main", prs[child.GetNumber()-1].GetBase().GetRef())
+
+	assert.False(t, server.HasBranch(githubtest.DefaultRepo, "push-kkkk"))
+	assert.False(t, server.HasBranch(githubtest.DefaultRepo, "jj/oooo"))
+	assert.True(t, server.HasBranch(githubtest.DefaultRepo, "push-llll"))
+
+	comments := server.Comments(githubtest.DefaultRepo, abandoned.GetNumber())
+	require.Len(t, comments, 1)
+	assert.Contains(t, comments[0].GetBody(), "`kkkk` no longer exists")
+
+	saved, err := prmap.Load(path)
+	require.NoError(t, err)
+	_, ok := saved.Get(githubtest.DefaultRepo, "kkkkkkkk")
+	assert.False(t, ok, "closed PRs are forgotten")
+	_, ok = saved.Get(githubtest.DefaultRepo, "llllllll")
+	assert.True(t, ok)
+}
+
+func TestRunHeadlessNothingToClean(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.OpenPullRequest(githubtest.DefaultRepo, "feature", "main", "c-feature", nil)
+
+	r := jjtest.NewRunner()
+	r.Expect("", "git", "fetch")
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+
+	var out strings.Builder
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), server.Client(t), Options{
+		Repo:   githubtest.DefaultRepo,
+		Config: config.Default(),
+	}), &out)
+	require.NoError(t, err)
+	assert.Equal(t, PhaseNothingToClean, m.phase)
+	assert.Contains(t, out.String(), "No orphaned pull requests.")
+}
+
+func TestBookmarkPrefix(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`"push-" ++ change_id.short()`, "push-"},
+		{`"jj/" ++ change_id.shortest(8)`, "jj/"},
+		{`change_id.short()`, ""},
+		{`"fixed"`, ""},
+		{`"a\"b" ++ change_id`, ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, bookmarkPrefix(tt.template), tt.template)
+	}
+}
@@ -0,0 +1,76 @@
+package cleanup
+
+import (
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// RunHeadless drives the cleanup workflow without a bubbletea program.
+// It skips the confirmation prompt and writes one line of progress per step to w.
+// Returns the final model and the workflow error, if any.
+func RunHeadless(m Model, w io.Writer) (Model, error) {
+	fmt.Fprintln(w, "Looking for orphaned pull requests...")
+
+	cmd := m.loadCmd()
+	for cmd != nil {
+		msg := cmd()
+		if _, ok := msg.(tea.QuitMsg); ok {
+			break
+		}
+
+		next, nextCmd := m.Update(msg)
+		m = next.(Model)
+		cmd = nextCmd
+
+		m.logProgress(w, msg)
+
+		if m.phase == PhaseConfirmation {
+			m, cmd = m.startCleanup()
+		}
+	}
+
+	switch m.phase {
+	case PhaseError:
+		return m, m.err
+	case PhaseNothingToClean:
+		fmt.Fprintln(w, "No orphaned pull requests.")
+	case PhaseComplete:
+		fmt.Fprintf(w, "%d pull request(s) closed.\n", len(m.orphans))
+	}
+
+	return m, nil
+}
+
+// logProgress writes a single line describing the result of msg
+func (m Model) logProgress(w io.Writer, msg tea.Msg) {
+	switch msg := msg.(type) {
+	case LoadedMsg:
+		if msg.Err == nil && len(msg.Orphans) > 0 {
+			fmt.Fprintf(w, "%d pull request(s) will be closed and their branches deleted.\n", len(msg.Orphans))
+		}
+
+	case RetargetedMsg:
+		if msg.Err != nil {
+			fmt.Fprintf(w, "PR #%d: %v\n", msg.Number, msg.Err)
+			return
+		}
+		for _, retarget := range m.retargets {
+			if retarget.PR.GetNumber() == msg.Number {
+				fmt.Fprintf(w, "PR #%d: now targets %s\n", msg.Number, retarget.Base)
+			}
+		}
+
+	case ClosedMsg:
+		if msg.Err != nil {
+			fmt.Fprintf(w, "PR #%d: %v\n", msg.Number, msg.Err)
+			return
+		}
+		for _, orphan := range m.orphans {
+			if orphan.PR.GetNumber() == msg.Number {
+				fmt.Fprintf(w, "PR #%d: closed, deleted %s\n", msg.Number, orphan.PR.GetHead().GetRef())
+			}
+		}
+	}
+}
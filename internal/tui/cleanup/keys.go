@@ -0,0 +1,23 @@
+package cleanup
+
+import "github.com/charmbracelet/bubbles/key"
+
+// KeyMap defines the key bindings for the cleanup TUI
+type KeyMap struct {
+	Clean key.Binding
+	Quit  key.Binding
+}
+
+// DefaultKeyMap returns the default key bindings
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Clean: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "close"),
+		),
+		Quit: key.NewBinding(
+			key.WithKeys("q", "ctrl+c"),
+			key.WithHelp("q", "quit"),
+		),
+	}
+}
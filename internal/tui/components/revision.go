@@ -1,9 +1,9 @@
 package components
 
 import (
-	"fmt"
 	"strings"
 
+	"github.com/cbrewster/jj-github/internal/github"
 	"github.com/cbrewster/jj-github/internal/jj"
 	"github.com/rivo/uniseg"
 )
@@ -27,6 +27,13 @@ type Revision struct {
 	Error       error  // Error if state is StateError
 	IsImmutable bool   // Is this an immutable revision (trunk)?
 	NeedsSync   bool   // Whether this revision needs to be synced
+	Details     string // Extra line shown below the revision when there is no status message
+
+	// Graph columns drawn around the revision for branching stacks
+	GraphBefore string   // Left of the symbol
+	GraphAfter  string   // Right of the symbol
+	GraphBelow  string   // On the line below; defaults to a single line
+	GraphEdges  []string // Rows joining or splitting branches, drawn after the revision
 }
 
 // NewRevision creates a new revision from a jj.Change
@@ -50,9 +57,9 @@ func NewTrunkRevision(branchName string) Revision {
 
 // ViewOptions contains options for rendering a revision
 type ViewOptions struct {
-	RepoOwner string
-	RepoName  string
-	Width     int
+	Repo     github.Repo // Repository the PR links point to
+	Width    int
+	NoPRText string // Shown instead of a PR link when there is no PR, defaults to "(new PR)"
 }
 
 // View renders the revision row
@@ -63,13 +70,16 @@ func (r Revision) View(spinner Spinner, showConnector bool, opts ViewOptions) st
 	symbol := r.graphSymbol(spinner)
 
 	// Build the main line: symbol + change ID + description + PR link
+	sb.WriteString(r.GraphBefore)
 	if r.IsImmutable {
 		// Trunk/immutable revision
 		sb.WriteString(MutedStyle.Render(symbol))
+		sb.WriteString(r.GraphAfter)
 		sb.WriteString("  ")
 		sb.WriteString(MutedStyle.Render(r.Change.Description))
 	} else {
 		sb.WriteString(symbol)
+		sb.WriteString(r.GraphAfter)
 		sb.WriteString("  ")
 		// Short change ID (first 8 chars)
 		changeID := r.Change.ID
@@ -84,7 +94,9 @@ func (r Revision) View(spinner Spinner, showConnector bool, opts ViewOptions) st
 		// Build PR link or "(new PR)" text
 		var prText string
 		if r.PRNumber > 0 {
-			prText = fmt.Sprintf("https://github.com/%s/%s/pull/%d", opts.RepoOwner, opts.RepoName, r.PRNumber)
+			prText = opts.Repo.PullRequestURL(r.PRNumber)
+		} else if opts.NoPRText != "" {
+			prText = opts.NoPRText
 		} else {
 			prText = "(new PR)"
 		}
@@ -92,11 +104,12 @@ func (r Revision) View(spinner Spinner, showConnector bool, opts ViewOptions) st
 		// Calculate available width for description
 		// Layout: symbol(1-2) + "  " + changeID(8) + "  " + description + "  " + prLink
 		// Symbol width varies (✓, ○, etc.) but we'll use 2 as a safe estimate
-		symbolWidth := 2  // graph symbol width
-		spacing := 2 + 2 + 2  // three "  " separators
-		changeIDWidth := 8    // fixed change ID width
+		// Graph symbol width, including the graph columns around it
+		symbolWidth := 2 + uniseg.StringWidth(r.GraphBefore+r.GraphAfter)
+		spacing := 2 + 2 + 2 // three "  " separators
+		changeIDWidth := 8   // fixed change ID width
 		prTextWidth := uniseg.StringWidth(prText)
-		
+
 		fixedWidth := symbolWidth + spacing + changeIDWidth + prTextWidth
 		availableWidth := opts.Width - fixedWidth
 		if availableWidth < 10 {
@@ -117,7 +130,11 @@ func (r Revision) View(spinner Spinner, showConnector bool, opts ViewOptions) st
 
 	// Connector line to next revision (if not the last one)
 	if showConnector {
-		sb.WriteString(GraphLine)
+		if r.GraphBelow != "" {
+			sb.WriteString(r.GraphBelow)
+		} else {
+			sb.WriteString(GraphLine)
+		}
 	}
 
 	// Status message line (if in progress or error)
@@ -128,10 +145,18 @@ func (r Revision) View(spinner Spinner, showConnector bool, opts ViewOptions) st
 		} else {
 			sb.WriteString(MutedStyle.Render(r.StatusMsg))
 		}
+	} else if r.Details != "" && !r.IsImmutable {
+		sb.WriteString("  ")
+		sb.WriteString(MutedStyle.Render(r.Details))
 	}
 
 	sb.WriteString("\n")
 
+	for _, edges := range r.GraphEdges {
+		sb.WriteString(edges)
+		sb.WriteString("\n")
+	}
+
 	return sb.String()
 }
 
@@ -166,13 +191,13 @@ func truncateString(s string, maxWidth int) string {
 	if maxWidth <= 0 {
 		return ""
 	}
-	
+
 	// If the string width is already within limits, return as-is
 	width := uniseg.StringWidth(s)
 	if width <= maxWidth {
 		return s
 	}
-	
+
 	// Need to truncate - determine if we can fit ellipsis
 	targetWidth := maxWidth
 	addEllipsis := false
@@ -180,10 +205,10 @@ func truncateString(s string, maxWidth int) string {
 		targetWidth = maxWidth - 3
 		addEllipsis = true
 	}
-	
+
 	var result strings.Builder
 	currentWidth := 0
-	
+
 	gr := uniseg.NewGraphemes(s)
 	for gr.Next() {
 		grapheme := gr.Str()
@@ -194,7 +219,7 @@ func truncateString(s string, maxWidth int) string {
 		result.WriteString(grapheme)
 		currentWidth += graphemeWidth
 	}
-	
+
 	if addEllipsis {
 		result.WriteString("...")
 	}
@@ -1,9 +1,11 @@
 package components
 
 import (
+	"slices"
 	"strings"
 
 	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/stackgraph"
 )
 
 // Stack represents the full revision stack with trunk at bottom
@@ -14,28 +16,75 @@ type Stack struct {
 
 // NewStack creates a new stack from a list of changes
 // Changes should be in topological order (trunk first, current last)
-// The stack will display in reverse order (current at top, trunk at bottom)
+// The stack will display in reverse order (current at top, trunk at bottom),
+// with sibling branches drawn side by side like `jj log`. Each branch is kept
+// together, so walking the revisions from the bottom visits parents first.
 func NewStack(changes []jj.Change, trunkName string) Stack {
-	revisions := make([]Revision, 0, len(changes)+1)
-
-	// Add changes in reverse order (current at top)
-	for i := len(changes) - 1; i >= 0; i-- {
-		change := changes[i]
+	var mutable []jj.Change
+	var nodes []stackgraph.Node
+	for _, change := range changes {
 		if change.Immutable {
 			continue // Skip immutable changes, we'll add trunk at the end
 		}
-		revisions = append(revisions, NewRevision(change))
+		mutable = append(mutable, change)
+		nodes = append(nodes, ChangeNode(change))
+	}
+
+	// Add changes in reverse order (current at top)
+	order := stackgraph.TopoOrder(nodes)
+	slices.Reverse(order)
+
+	ordered := make([]stackgraph.Node, len(order))
+	revisions := make([]Revision, 0, len(order)+1)
+	for i, idx := range order {
+		ordered[i] = nodes[idx]
+		revisions = append(revisions, NewRevision(mutable[idx]))
 	}
 
 	// Add trunk at the bottom
 	revisions = append(revisions, NewTrunkRevision(trunkName))
 
+	// Attach the graph drawn around each revision
+	current := -1
+	for _, row := range stackgraph.Layout(ordered) {
+		if row.Node < 0 {
+			if current >= 0 {
+				revisions[current].GraphEdges = append(revisions[current].GraphEdges, row.Graph)
+			}
+			continue
+		}
+		current = row.Node
+		rev := &revisions[current]
+		rev.GraphBefore, rev.GraphAfter = splitGraph(row.Graph, row.Column)
+		rev.GraphBelow = row.Continuation
+	}
+
 	return Stack{
 		Revisions: revisions,
 		TrunkName: trunkName,
 	}
 }
 
+// ChangeNode returns the stack graph node of a change
+func ChangeNode(change jj.Change) stackgraph.Node {
+	node := stackgraph.Node{ID: change.ID}
+	for _, parent := range change.Parents {
+		node.Parents = append(node.Parents, parent.ChangeID)
+	}
+	return node
+}
+
+// splitGraph returns the graph left and right of the node in column
+func splitGraph(graph string, column int) (string, string) {
+	cells := []rune(graph)
+	before := string(cells[:2*column])
+	after := ""
+	if 2*column+1 < len(cells) {
+		after = string(cells[2*column+1:])
+	}
+	return before, after
+}
+
 // SetRevisionState updates the state of a revision by change ID
 func (s *Stack) SetRevisionState(changeID string, state RevisionState, statusMsg string) {
 	for i := range s.Revisions {
@@ -57,6 +106,16 @@ func (s *Stack) SetRevisionPR(changeID string, prNumber int) {
 	}
 }
 
+// SetRevisionDetails sets the detail line shown below a revision
+func (s *Stack) SetRevisionDetails(changeID, details string) {
+	for i := range s.Revisions {
+		if s.Revisions[i].Change.ID == changeID {
+			s.Revisions[i].Details = details
+			return
+		}
+	}
+}
+
 // SetRevisionError sets an error state for a revision
 func (s *Stack) SetRevisionError(changeID string, err error) {
 	for i := range s.Revisions {
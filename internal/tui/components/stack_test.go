@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/cbrewster/jj-github/internal/github"
 	"github.com/cbrewster/jj-github/internal/jj"
 	"github.com/stretchr/testify/assert"
 )
@@ -151,9 +152,8 @@ func TestTruncateString(t *testing.T) {
 func TestRevisionViewWithPRLink(t *testing.T) {
 	spinner := NewSpinner()
 	opts := ViewOptions{
-		RepoOwner: "testowner",
-		RepoName:  "testrepo",
-		Width:     120,
+		Repo:  github.Repo{Owner: "testowner", Name: "testrepo"},
+		Width: 120,
 	}
 
 	// Revision with PR number should show full link
@@ -193,9 +193,8 @@ func TestRevisionViewWithPRLink(t *testing.T) {
 func TestRevisionViewTruncation(t *testing.T) {
 	spinner := NewSpinner()
 	opts := ViewOptions{
-		RepoOwner: "owner",
-		RepoName:  "repo",
-		Width:     80, // narrower width to test truncation
+		Repo:  github.Repo{Owner: "owner", Name: "repo"},
+		Width: 80, // narrower width to test truncation
 	}
 
 	// Revision with long description should be truncated
@@ -219,3 +218,65 @@ func TestRevisionViewTruncation(t *testing.T) {
 	assert.True(t, strings.Contains(output, "...") || len(rev.Change.Description) <= 40,
 		"Long descriptions should be truncated")
 }
+
+func TestRevisionViewEnterpriseHost(t *testing.T) {
+	spinner := NewSpinner()
+	opts := ViewOptions{
+		Repo:  github.Repo{Host: "github.example.com", Owner: "owner", Name: "repo"},
+		Width: 120,
+	}
+
+	rev := Revision{
+		Change: jj.Change{
+			ID:          "abcdefgh12345678",
+			ShortID:     "abc",
+			Description: "Test description",
+		},
+		PRNumber: 7,
+	}
+
+	output := rev.View(spinner, true, opts)
+	assert.Contains(t, output, "https://github.example.com/owner/repo/pull/7")
+}
+
+func TestNewStackBranches(t *testing.T) {
+	change := func(id, parent string) jj.Change {
+		c := jj.Change{ID: id + "0000000", ShortID: id, Description: "Change " + id}
+		c.Parents = append(c.Parents, struct {
+			ChangeID string `json:"change_id"`
+			CommitID string `json:"commit_id"`
+		}{ChangeID: parent + "0000000"})
+		return c
+	}
+
+	// a has two branches, b-d and c, in the order jj log --reversed gives.
+	stack := NewStack([]jj.Change{
+		{ID: "z0000000", Immutable: true},
+		change("a", "z"),
+		change("b", "a"),
+		change("c", "a"),
+		change("d", "b"),
+	}, "main")
+
+	var order []string
+	for _, rev := range stack.MutableRevisions() {
+		order = append(order, rev.Change.ShortID)
+	}
+	assert.Equal(t, []string{"c", "d", "b", "a"}, order, "branches are kept together")
+
+	var lines []string
+	for line := range strings.Lines(stack.View(NewSpinner(), ViewOptions{Width: 120})) {
+		// Keep the graph only; revisions are up to date, so drawn as ✓
+		graph, _, _ := strings.Cut(strings.TrimRight(line, "\n"), "  ")
+		lines = append(lines, graph)
+	}
+	assert.Equal(t, []string{
+		"", "Revisions:", "",
+		"✓", "│",
+		"│ ✓", "│ │",
+		"│ ✓", "│ │",
+		"├─╯",
+		"✓", "│",
+		"◆", "",
+	}, lines)
+}
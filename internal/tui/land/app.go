@@ -0,0 +1,635 @@
+// Package land merges the bottom of a stack on GitHub and moves the rest of
+// the stack onto the updated trunk.
+package land
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/prmap"
+	"github.com/cbrewster/jj-github/internal/tui/components"
+	"github.com/cbrewster/jj-github/internal/tui/submit"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// Phase represents the current phase of the land workflow
+type Phase int
+
+const (
+	PhaseLoading Phase = iota
+	PhaseNothingToLand
+	PhaseConfirmation
+	PhaseMerging
+	PhaseRebasing
+	PhasePushing
+	PhaseComplete
+	PhaseError
+)
+
+const (
+	// mergePollInterval is how often a merged PR is polled until GitHub reports it merged
+	mergePollInterval = 2 * time.Second
+	// mergeTimeout bounds how long to wait for a single PR to merge
+	mergeTimeout = 5 * time.Minute
+	// mergeablePollInterval is how often a PR is polled until GitHub has
+	// computed whether it can be merged
+	mergeablePollInterval = time.Second
+	// mergeablePolls bounds how often a PR is polled for its mergeability
+	mergeablePolls = 10
+	// mergeAttempts bounds how often merging a PR is tried while GitHub
+	// rejects it, e.g. because its base was just changed
+	mergeAttempts = 3
+)
+
+// Messages for async operations
+type (
+	LoadedMsg struct {
+		Changes   []jj.Change
+		TrunkName string
+		Stack     []jj.Change                      // Linear stack from the bottom up
+		PRs       map[string]*gogithub.PullRequest // Open PRs keyed by change ID
+		Land      []string                         // Change IDs to merge, bottom first
+		Blocker   string                           // Why the next change can't be landed
+		Err       error
+	}
+
+	MergedMsg struct {
+		ChangeID string
+		Err      error
+	}
+
+	RetargetedMsg struct {
+		ChangeID string
+		Err      error
+	}
+
+	RebasedMsg struct {
+		HasConflict bool
+		Err         error
+	}
+)
+
+// Model is the main bubbletea model for the land TUI
+type Model struct {
+	// State
+	phase   Phase
+	stack   components.Stack
+	spinner components.Spinner
+	keys    KeyMap
+	err     error
+	width   int
+
+	// Dependencies
+	ctx      context.Context
+	jj       *jj.Client
+	gh       *github.Client
+	cfg      config.Config
+	repo     github.Repo
+	headRepo github.Repo
+	revset   string
+	prStore  *prmap.Store
+	limit    int
+
+	// Data from loading phase
+	trunkName string
+	changes   []jj.Change
+	prs       map[string]*gogithub.PullRequest
+	land      []string
+	blocker   string
+
+	// Progress tracking
+	mergedCount int
+
+	// Resubmitting the rest of the stack once it is rebased
+	submit   submit.Model
+	headless bool
+}
+
+// Options configures what land merges
+type Options struct {
+	// Repo is the repository pull requests are opened against.
+	Repo github.Repo
+	// HeadRepo is the repository branches are pushed to. Defaults to Repo.
+	HeadRepo github.Repo
+	// Revset selects the stack to land from.
+	Revset string
+	// Limit is the most pull requests to merge. Zero merges every ready
+	// pull request from the bottom of the stack.
+	Limit int
+	// Config holds the jj-github settings, including the merge method.
+	Config config.Config
+	// PRs remembers the pull request of each change, so it is found even
+	// after its bookmark is renamed. Optional.
+	PRs *prmap.Store
+}
+
+// NewModel creates a new land TUI model
+func NewModel(ctx context.Context, jjClient *jj.Client, gh *github.Client, opts Options) Model {
+	headRepo := opts.HeadRepo
+	if headRepo == (github.Repo{}) {
+		headRepo = opts.Repo
+	}
+
+	return Model{
+		phase:    PhaseLoading,
+		spinner:  components.NewSpinner(),
+		keys:     DefaultKeyMap(),
+		ctx:      ctx,
+		jj:       jjClient,
+		gh:       gh,
+		cfg:      opts.Config,
+		repo:     opts.Repo,
+		headRepo: headRepo,
+		revset:   opts.Revset,
+		prStore:  opts.PRs,
+		limit:    opts.Limit,
+	}
+}
+
+// headOwner returns the owner of the fork branches are pushed to, or "" when
+// branches are pushed to the pull request repository itself
+func (m Model) headOwner() string {
+	if m.headRepo.Owner == m.repo.Owner {
+		return ""
+	}
+	return m.headRepo.Owner
+}
+
+// Init initializes the model and starts loading the stack
+func (m Model) Init() tea.Cmd {
+	return tea.Batch(
+		m.spinner.Tick(),
+		m.loadCmd(),
+	)
+}
+
+// Update handles messages and updates the model
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.phase == PhasePushing {
+		return m.updateSubmit(msg)
+	}
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Land) && m.phase == PhaseConfirmation:
+			return m.startLand()
+		}
+
+	case LoadedMsg:
+		if msg.Err != nil {
+			m.phase = PhaseError
+			m.err = msg.Err
+			return m, tea.Quit
+		}
+
+		m.trunkName = msg.TrunkName
+		m.changes = msg.Stack
+		m.prs = msg.PRs
+		m.land = msg.Land
+		m.blocker = msg.Blocker
+		m.stack = components.NewStack(msg.Changes, msg.TrunkName)
+
+		for i := range m.stack.Revisions {
+			rev := &m.stack.Revisions[i]
+			if rev.IsImmutable {
+				continue
+			}
+			rev.PRNumber = m.prs[rev.Change.ID].GetNumber()
+			rev.NeedsSync = slices.Contains(m.land, rev.Change.ID)
+		}
+		if next := m.changeAfter(len(m.land) - 1); next != nil && m.blocker != "" {
+			m.setDetails(next.ID, m.blocker)
+		}
+
+		if len(m.land) == 0 {
+			m.phase = PhaseNothingToLand
+			return m, tea.Quit
+		}
+
+		m.phase = PhaseConfirmation
+		return m, nil
+
+	case MergedMsg:
+		if msg.Err != nil {
+			return m.fail(msg.ChangeID, msg.Err)
+		}
+
+		m.stack.SetRevisionState(msg.ChangeID, components.StateSuccess, "")
+		m.setDetails(msg.ChangeID, "merged")
+		m.mergedCount++
+
+		if next := m.changeAfter(m.mergedCount - 1); next != nil && m.prs[next.ID] != nil {
+			return m, m.retargetCmd(*next)
+		}
+		return m.startRebase()
+
+	case RetargetedMsg:
+		if msg.Err != nil {
+			return m.fail(msg.ChangeID, msg.Err)
+		}
+
+		m.stack.SetRevisionState(msg.ChangeID, components.StatePending, "")
+		if m.mergedCount < len(m.land) {
+			return m, m.mergeCmd(m.land[m.mergedCount])
+		}
+		return m.startRebase()
+
+	case RebasedMsg:
+		if msg.Err != nil {
+			m.phase = PhaseError
+			m.err = msg.Err
+			return m, tea.Quit
+		}
+		if msg.HasConflict {
+			m.phase = PhaseError
+			m.err = fmt.Errorf("the rest of the stack has conflicts after rebasing onto %s; resolve them and run submit", m.trunkName)
+			return m, tea.Quit
+		}
+
+		return m.startSubmit()
+	}
+
+	var cmd tea.Cmd
+	m.spinner, cmd = m.spinner.Update(msg)
+	return m, cmd
+}
+
+// startLand leaves the confirmation phase and merges the first pull request
+func (m Model) startLand() (Model, tea.Cmd) {
+	m.phase = PhaseMerging
+	m.mergedCount = 0
+	return m, m.mergeCmd(m.land[0])
+}
+
+// startRebase fetches the merged trunk and rebases what is left of the stack
+func (m Model) startRebase() (Model, tea.Cmd) {
+	m.phase = PhaseRebasing
+	return m, m.rebaseCmd()
+}
+
+// startSubmit resubmits what is left of the stack like submit does, which
+// pushes the rebased revisions and updates the bases, descriptions and stack
+// comments of their PRs. Revisions without a PR are left alone.
+func (m Model) startSubmit() (Model, tea.Cmd) {
+	if !slices.ContainsFunc(m.changes[m.mergedCount:], func(c jj.Change) bool { return m.prs[c.ID] != nil }) {
+		m.phase = PhaseComplete
+		return m, tea.Quit
+	}
+
+	m.phase = PhasePushing
+	m.submit = submit.NewModel(m.ctx, m.jj, m.gh, submit.Options{
+		Repo:         m.repo,
+		HeadRepo:     m.headRepo,
+		Revset:       m.revset,
+		Config:       m.cfg,
+		PRs:          m.prStore,
+		ExistingOnly: true,
+		NoFetch:      true,
+	})
+	if m.width > 0 {
+		next, _ := m.submit.Update(tea.WindowSizeMsg{Width: m.width})
+		m.submit = next.(submit.Model)
+	}
+	return m, m.submit.Init()
+}
+
+// updateSubmit hands msg to the submit of the rest of the stack, completing
+// the workflow once it is done. Landing was confirmed already, so the submit
+// starts without asking again.
+func (m Model) updateSubmit(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && key.Matches(msg, m.keys.Quit) {
+		return m, tea.Quit
+	}
+
+	next, cmd := m.submit.Update(msg)
+	m.submit = next.(submit.Model)
+	var confirmCmd tea.Cmd
+	m.submit, confirmCmd = m.submit.Confirm(!m.headless)
+
+	switch m.submit.Phase() {
+	case submit.PhaseComplete, submit.PhaseUpToDate:
+		for _, rev := range m.submit.Report().Revisions {
+			if rev.PRNumber != 0 {
+				m.stack.SetRevisionState(rev.ChangeID, components.StateSuccess, "")
+			}
+		}
+		m.phase = PhaseComplete
+		return m, tea.Quit
+
+	case submit.PhaseError:
+		m.phase = PhaseError
+		m.err = fmt.Errorf("submit: %w", m.submit.Err())
+		return m, tea.Quit
+	}
+
+	return m, tea.Batch(cmd, confirmCmd)
+}
+
+// fail marks changeID as failed and stops the workflow
+func (m Model) fail(changeID string, err error) (Model, tea.Cmd) {
+	m.stack.SetRevisionError(changeID, err)
+	m.phase = PhaseError
+	m.err = err
+	return m, tea.Quit
+}
+
+// changeAfter returns the change stacked on top of the i-th change of the
+// stack, or nil if there is none. i may be -1 for the bottom change.
+func (m Model) changeAfter(i int) *jj.Change {
+	if i+1 >= len(m.changes) {
+		return nil
+	}
+	return &m.changes[i+1]
+}
+
+// setDetails sets the detail line shown below a revision
+func (m *Model) setDetails(changeID, details string) {
+	for i := range m.stack.Revisions {
+		if m.stack.Revisions[i].Change.ID == changeID {
+			m.stack.Revisions[i].Details = details
+			return
+		}
+	}
+}
+
+// View renders the UI
+func (m Model) View() string {
+	var sb strings.Builder
+
+	width := m.width
+	if width == 0 {
+		width = 80
+	}
+
+	viewOpts := components.ViewOptions{
+		Repo:     m.repo,
+		Width:    width,
+		NoPRText: "(no PR)",
+	}
+
+	switch m.phase {
+	case PhaseLoading:
+		sb.WriteString(m.spinner.View())
+		sb.WriteString(" Checking pull requests...\n")
+
+	case PhaseNothingToLand:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		sb.WriteString("\n")
+		if m.blocker != "" {
+			fmt.Fprintf(&sb, "Nothing to land: %s.\n", m.blocker)
+		} else {
+			sb.WriteString("Nothing to land.\n")
+		}
+
+	case PhaseConfirmation:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		sb.WriteString("\n")
+		fmt.Fprintf(&sb, "%d pull request(s) will be merged into %s with %s.\n\n",
+			len(m.land), m.trunkName, m.cfg.MergeMethod)
+		sb.WriteString(components.AccentStyle.Render(m.keys.Land.Help().Key + " " + m.keys.Land.Help().Desc))
+		sb.WriteString(components.MutedStyle.Render(" • " + m.keys.Quit.Help().Key + " " + m.keys.Quit.Help().Desc))
+		sb.WriteString("\n")
+
+	case PhaseMerging:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		sb.WriteString("Merging pull requests...\n\n")
+
+	case PhaseRebasing:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		sb.WriteString(m.spinner.View())
+		fmt.Fprintf(&sb, " Rebasing the rest of the stack onto %s...\n\n", m.trunkName)
+
+	case PhasePushing:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		sb.WriteString("Updating the rest of the stack:\n\n")
+		sb.WriteString(m.submit.View())
+
+	case PhaseComplete:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		fmt.Fprintf(&sb, "%d pull request(s) landed.\n", m.mergedCount)
+
+	case PhaseError:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		sb.WriteString(components.ErrorStyle.Render("Land failed"))
+		sb.WriteString("\n\n")
+		if m.err != nil {
+			sb.WriteString(components.ErrorStyle.Render(m.err.Error()))
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// Commands for async operations
+
+func (m Model) loadCmd() tea.Cmd {
+	return func() tea.Msg {
+		changes, err := m.jj.GetChanges(jj.StackRevset(m.revset))
+		if err != nil {
+			return LoadedMsg{Err: err}
+		}
+
+		trunkName, err := m.jj.GetTrunkName()
+		if err != nil {
+			return LoadedMsg{Err: fmt.Errorf("get trunk name: %w", err)}
+		}
+
+		// Only a linear stack can be landed from the bottom up
+		var stack []jj.Change
+		for _, change := range changes {
+			if change.Immutable || change.Description == "" {
+				continue
+			}
+			if len(stack) > 0 && change.Parents[0].ChangeID != stack[len(stack)-1].ID {
+				break
+			}
+			stack = append(stack, change)
+		}
+
+		if len(stack) == 0 {
+			return LoadedMsg{Changes: changes, TrunkName: trunkName}
+		}
+
+		prs, err := m.prStore.Find(m.ctx, m.gh, m.repo, m.headOwner(), stack)
+		if err != nil {
+			return LoadedMsg{Err: err}
+		}
+
+		var open []*gogithub.PullRequest
+		for _, change := range stack {
+			if pr, ok := prs[change.ID]; ok {
+				open = append(open, pr)
+			}
+		}
+
+		statuses, err := m.gh.GetPullRequestStatuses(m.ctx, m.repo, open)
+		if err != nil {
+			return LoadedMsg{Err: err}
+		}
+
+		land, blocker := landable(stack, prs, statuses, m.limit)
+
+		return LoadedMsg{
+			Changes:   changes,
+			TrunkName: trunkName,
+			Stack:     stack,
+			PRs:       prs,
+			Land:      land,
+			Blocker:   blocker,
+		}
+	}
+}
+
+func (m Model) mergeCmd(changeID string) tea.Cmd {
+	m.stack.SetRevisionState(changeID, components.StateInProgress, "Merging...")
+	pr := m.prs[changeID]
+
+	return func() tea.Msg {
+		for attempt := 1; ; attempt++ {
+			err := m.gh.MergePullRequest(m.ctx, m.repo, pr.GetNumber(), m.cfg.MergeMethod, pr.GetHead().GetSHA())
+			if err == nil {
+				break
+			}
+			if !isMergeRejected(err) || attempt == mergeAttempts {
+				return MergedMsg{ChangeID: changeID, Err: fmt.Errorf("merge PR #%d: %w", pr.GetNumber(), err)}
+			}
+
+			// GitHub rejects merges until it has caught up with a base change
+			select {
+			case <-m.ctx.Done():
+				return MergedMsg{ChangeID: changeID, Err: m.ctx.Err()}
+			case <-time.After(mergeablePollInterval):
+			}
+			if err := m.waitForMergeable(pr.GetNumber()); err != nil {
+				return MergedMsg{ChangeID: changeID, Err: err}
+			}
+		}
+		return MergedMsg{ChangeID: changeID, Err: m.waitForMerge(pr.GetNumber())}
+	}
+}
+
+// isMergeRejected reports whether GitHub refused a merge with 405, which it
+// also does while it recomputes whether the PR can be merged
+func isMergeRejected(err error) bool {
+	var ghErr *gogithub.ErrorResponse
+	return errors.As(err, &ghErr) && ghErr.Response.StatusCode == http.StatusMethodNotAllowed
+}
+
+// waitForMergeable polls a pull request until GitHub has computed whether it
+// can be merged, which it does in the background after its base changed.
+// Polling stops after mergeablePolls; merging then tells whether it can be.
+func (m Model) waitForMergeable(number int) error {
+	for poll := 0; poll < mergeablePolls; poll++ {
+		if poll > 0 {
+			select {
+			case <-m.ctx.Done():
+				return m.ctx.Err()
+			case <-time.After(mergeablePollInterval):
+			}
+		}
+
+		pr, err := m.gh.GetPullRequest(m.ctx, m.repo, number)
+		if err != nil {
+			return fmt.Errorf("waiting for PR #%d to be mergeable: %w", number, err)
+		}
+		if pr.Mergeable == nil {
+			continue
+		}
+		if !pr.GetMergeable() {
+			return fmt.Errorf("PR #%d can't be merged into %s, it has conflicts", number, m.trunkName)
+		}
+		return nil
+	}
+	return nil
+}
+
+// waitForMerge polls a pull request until GitHub reports it merged
+func (m Model) waitForMerge(number int) error {
+	ctx, cancel := context.WithTimeout(m.ctx, mergeTimeout)
+	defer cancel()
+
+	for {
+		pr, err := m.gh.GetPullRequest(ctx, m.repo, number)
+		if err != nil {
+			return fmt.Errorf("waiting for PR #%d to merge: %w", number, err)
+		}
+		if pr.GetMerged() {
+			return nil
+		}
+		if pr.GetState() == "closed" {
+			return fmt.Errorf("PR #%d was closed without being merged", number)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for PR #%d to merge: %w", number, ctx.Err())
+		case <-time.After(mergePollInterval):
+		}
+	}
+}
+
+func (m Model) retargetCmd(change jj.Change) tea.Cmd {
+	m.stack.SetRevisionState(change.ID, components.StateInProgress, "Retargeting to "+m.trunkName+"...")
+	pr := m.prs[change.ID]
+
+	return func() tea.Msg {
+		if pr.GetBase().GetRef() == m.trunkName {
+			return RetargetedMsg{ChangeID: change.ID}
+		}
+		err := m.gh.SetPullRequestBase(m.ctx, m.repo, pr.GetNumber(), m.trunkName)
+		if err != nil {
+			return RetargetedMsg{ChangeID: change.ID, Err: fmt.Errorf("retarget PR #%d: %w", pr.GetNumber(), err)}
+		}
+
+		// Merging it right away fails until GitHub has caught up
+		if slices.Contains(m.land, change.ID) {
+			err = m.waitForMergeable(pr.GetNumber())
+		}
+		return RetargetedMsg{ChangeID: change.ID, Err: err}
+	}
+}
+
+func (m Model) rebaseCmd() tea.Cmd {
+	bottom := m.changes[0].ID
+
+	return func() tea.Msg {
+		if err := m.jj.GitFetch(m.cfg.FetchRemotes()...); err != nil {
+			return RebasedMsg{Err: fmt.Errorf("git fetch: %w", err)}
+		}
+
+		// Merged changes that are now part of trunk are immutable; squashed or
+		// rebased ones are still mutable and become empty when rebased.
+		roots, err := m.jj.GetChanges(fmt.Sprintf("roots(%s:: & mutable())", bottom))
+		if err != nil {
+			return RebasedMsg{Err: err}
+		}
+
+		hasConflict := false
+		for _, root := range roots {
+			result, err := m.jj.Rebase(root.ID, "trunk()")
+			if err != nil {
+				return RebasedMsg{Err: err}
+			}
+			hasConflict = hasConflict || result.HasConflict
+		}
+		return RebasedMsg{HasConflict: hasConflict}
+	}
+}
@@ -0,0 +1,234 @@
+package land
+
+import (
+	"io"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/prmap"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectLoad scripts the jj invocations made while loading the stack.
+func expectLoad(r *jjtest.Runner, output string) {
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(output, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", jj.StackRevset("@"))
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.Trunk, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", "trunk()")
+}
+
+// expectLog scripts the jj invocations made by jj.Client.GetChanges.
+func expectLog(r *jjtest.Runner, revset, output string) {
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(output, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", revset)
+}
+
+// expectSubmitLoad scripts the jj invocations made by submit while loading
+// the rest of the stack, output, without fetching.
+func expectSubmitLoad(r *jjtest.Runner, output string) {
+	expectLog(r, jj.StackRevset("@"), output)
+	expectLog(r, "trunk()", jjtest.Trunk)
+	r.Expect("", "file", "list", "-r", "trunk()", "--",
+		`root-glob-i:".github/pull_request_template.md"`,
+		`root-glob-i:"pull_request_template.md"`,
+		`root-glob-i:"docs/pull_request_template.md"`,
+		`root-glob-i:".github/pull_request_template/*.md"`,
+		`root-glob-i:"pull_request_template/*.md"`,
+		`root-glob-i:"docs/pull_request_template/*.md"`)
+}
+
+// addReadyPR seeds an approved, mergeable pull request with passing checks.
+func addReadyPR(server *githubtest.Server, branch, base, sha string) *gogithub.PullRequest {
+	pr := server.OpenPullRequest(githubtest.DefaultRepo, branch, base, sha, &gogithub.PullRequest{
+		Mergeable:      gogithub.Ptr(true),
+		MergeableState: gogithub.Ptr("clean"),
+	})
+	server.AddReview(githubtest.DefaultRepo, pr.GetNumber(), "alice", "APPROVED")
+	server.AddCheckRun(githubtest.DefaultRepo, sha, "build", "completed", "success")
+	return pr
+}
+
+var testStack = jjtest.Trunk +
+	jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0") +
+	jjtest.Change("bbbb", "c2", "Add login form\n", "aaaa", "c1") +
+	jjtest.Change("cccc", "c3", "Add logout\n", "bbbb", "c2")
+
+func TestRunHeadlessLandsReadyPRs(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	first := addReadyPR(server, "push-aaaa", "main", "c1")
+	second := addReadyPR(server, "push-bbbb", "push-aaaa", "c2")
+	third := addReadyPR(server, "push-cccc", "push-bbbb", "c3")
+	server.AddCheckRun(githubtest.DefaultRepo, "c3", "lint", "completed", "failure")
+
+	r := jjtest.NewRunner()
+	expectLoad(r, testStack)
+	r.Expect("", "git", "fetch")
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0"),
+		"log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", "roots(aaaa:: & mutable())")
+	r.Expect("Skipped rebase of 2 commits that became empty\n", "rebase", "-s", "aaaa", "-d", "trunk()", "--skip-emptied")
+	expectSubmitLoad(r, jjtest.Trunk+jjtest.Change("cccc", "c3b", "Add logout\n", "zzzz", "c0"))
+	expectLog(r, "::c3 ~ ::(c3b | trunk())", "")
+	r.Expect("", "git", "push", "--remote", "origin", "-c", "change_id(cccc)")
+
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), server.Client(t), Options{
+		Repo:   githubtest.DefaultRepo,
+		Revset: "@",
+		Config: config.Default(),
+	}), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+	assert.Equal(t, 2, m.mergedCount)
+	assert.Empty(t, r.Unused())
+
+	prs := server.PullRequests(githubtest.DefaultRepo)
+	assert.True(t, prs[first.GetNumber()-1].GetMerged())
+	assert.True(t, prs[second.GetNumber()-1].GetMerged())
+	assert.False(t, prs[third.GetNumber()-1].GetMerged())
+	assert.Equal(t, "squash", server.MergeMethod(githubtest.DefaultRepo, first.GetNumber()))
+	assert.Equal(t, "main", prs[second.GetNumber()-1].GetBase().GetRef(), "retargeted before merging")
+	assert.Equal(t, "main", prs[third.GetNumber()-1].GetBase().GetRef())
+
+	comments := server.Comments(githubtest.DefaultRepo, third.GetNumber())
+	require.Len(t, comments, 1, "the rest of the stack is resubmitted")
+	assert.NotContains(t, comments[0].GetBody(), "Add auth", "landed PRs leave the stack")
+}
+
+func TestRunHeadlessRenamedBookmark(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	first := addReadyPR(server, "push-aaaa", "main", "c1")
+	second := addReadyPR(server, "old-bbbb", "push-aaaa", "c2")
+
+	// bbbb's PR was opened before templates.git_push_bookmark changed
+	prs, err := prmap.Load(prmap.Path(t.TempDir()))
+	require.NoError(t, err)
+	prs.Set(githubtest.DefaultRepo, "bbbb", second.GetNumber())
+
+	r := jjtest.NewRunner()
+	expectLoad(r, testStack)
+	r.Expect("", "git", "fetch")
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.Change("bbbb", "c2", "Add login form\n", "aaaa", "c1"),
+		"log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", "roots(aaaa:: & mutable())")
+	r.Expect("Rebased 2 commits\n", "rebase", "-s", "bbbb", "-d", "trunk()", "--skip-emptied")
+	expectSubmitLoad(r, jjtest.Trunk+
+		jjtest.Change("bbbb", "c2b", "Add login form\n", "zzzz", "c0")+
+		jjtest.Change("cccc", "c3b", "Add logout\n", "bbbb", "c2b"))
+	expectLog(r, "::c2 ~ ::(c2b | trunk())", "")
+	r.Expect("", "bookmark", "set", "old-bbbb", "-r", "change_id(bbbb)", "--allow-backwards")
+	r.Expect("", "git", "push", "--remote", "origin", "-b", "old-bbbb")
+
+	cfg := config.Default()
+	cfg.MergeMethod = github.MergeMethodMerge
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), server.Client(t), Options{
+		Repo:   githubtest.DefaultRepo,
+		Revset: "@",
+		Limit:  1,
+		Config: cfg,
+		PRs:    prs,
+	}), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+	assert.Empty(t, r.Unused())
+
+	pulls := server.PullRequests(githubtest.DefaultRepo)
+	assert.True(t, pulls[first.GetNumber()-1].GetMerged())
+	assert.Equal(t, "main", pulls[second.GetNumber()-1].GetBase().GetRef())
+}
+
+func TestRunHeadlessLimit(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	first := addReadyPR(server, "push-aaaa", "main", "c1")
+	second := addReadyPR(server, "push-bbbb", "push-aaaa", "c2")
+	addReadyPR(server, "push-cccc", "push-bbbb", "c3")
+
+	// A merge commit puts the landed change in trunk, leaving bbbb as the root.
+	cfg := config.Default()
+	cfg.MergeMethod = github.MergeMethodMerge
+
+	r := jjtest.NewRunner()
+	expectLoad(r, testStack)
+	r.Expect("", "git", "fetch")
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.Change("bbbb", "c2", "Add login form\n", "aaaa", "c1"),
+		"log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", "roots(aaaa:: & mutable())")
+	r.Expect("Rebased 2 commits\n", "rebase", "-s", "bbbb", "-d", "trunk()", "--skip-emptied")
+	expectSubmitLoad(r, jjtest.Trunk+
+		jjtest.Change("bbbb", "c2b", "Add login form\n", "zzzz", "c0")+
+		jjtest.Change("cccc", "c3b", "Add logout\n", "bbbb", "c2b"))
+	expectLog(r, "::c2 ~ ::(c2b | trunk())", "")
+	expectLog(r, "::c3 ~ ::(c3b | trunk())", "")
+	r.Expect("", "git", "push", "--remote", "origin", "-c", "change_id(bbbb)", "-c", "change_id(cccc)")
+
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), server.Client(t), Options{
+		Repo:   githubtest.DefaultRepo,
+		Revset: "@",
+		Limit:  1,
+		Config: cfg,
+	}), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+	assert.Empty(t, r.Unused())
+
+	prs := server.PullRequests(githubtest.DefaultRepo)
+	assert.True(t, prs[first.GetNumber()-1].GetMerged())
+	assert.Equal(t, "merge", server.MergeMethod(githubtest.DefaultRepo, first.GetNumber()))
+	assert.False(t, prs[second.GetNumber()-1].GetMerged())
+	assert.Equal(t, "main", prs[second.GetNumber()-1].GetBase().GetRef())
+}
+
+func TestRunHeadlessRetriesRejectedMerge(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	pr := addReadyPR(server, "push-aaaa", "main", "c1")
+	server.RejectMerge(githubtest.DefaultRepo, pr.GetNumber())
+
+	cfg := config.Default()
+	cfg.MergeMethod = github.MergeMethodMerge
+
+	r := jjtest.NewRunner()
+	expectLoad(r, jjtest.Trunk+jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0"))
+	r.Expect("", "git", "fetch")
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect("", "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", "roots(aaaa:: & mutable())")
+
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), server.Client(t), Options{
+		Repo:   githubtest.DefaultRepo,
+		Revset: "@",
+		Config: cfg,
+	}), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+	assert.Empty(t, r.Unused())
+	assert.True(t, server.PullRequests(githubtest.DefaultRepo)[pr.GetNumber()-1].GetMerged(), "merged on the second attempt")
+}
+
+func TestRunHeadlessNothingToLand(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	pr := addReadyPR(server, "push-aaaa", "main", "c1")
+	server.AddReview(githubtest.DefaultRepo, pr.GetNumber(), "bob", "CHANGES_REQUESTED")
+
+	r := jjtest.NewRunner()
+	expectLoad(r, jjtest.Trunk+jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0"))
+
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), server.Client(t), Options{
+		Repo:   githubtest.DefaultRepo,
+		Revset: "@",
+		Config: config.Default(),
+	}), io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, PhaseNothingToLand, m.phase)
+	assert.Equal(t, "changes requested", m.blocker)
+	assert.Empty(t, r.Unused())
+	assert.False(t, server.PullRequests(githubtest.DefaultRepo)[0].GetMerged())
+}
@@ -0,0 +1,126 @@
+package land
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// RunHeadless drives the land workflow without a bubbletea program.
+// It skips the confirmation prompt and writes one line of progress per step to w.
+// PRs are merged one at a time; the PRs of the rest of the stack are then
+// updated concurrently, and their messages are handled as they arrive.
+// Returns the final model and the workflow error, if any.
+func RunHeadless(m Model, w io.Writer) (Model, error) {
+	fmt.Fprintln(w, "Checking pull requests...")
+	m.headless = true
+
+	msgs := make(chan tea.Msg)
+	running := 0
+	run := func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+		running++
+		go func() { msgs <- cmd() }()
+	}
+
+	run(m.loadCmd())
+	for running > 0 {
+		msg := <-msgs
+		running--
+
+		switch msg := msg.(type) {
+		case tea.QuitMsg, spinner.TickMsg:
+			// Nothing is drawn, so spinners don't need to tick
+			continue
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				run(cmd)
+			}
+			continue
+		}
+
+		submitting := m.phase == PhasePushing
+		next, cmd := m.Update(msg)
+		m = next.(Model)
+		run(cmd)
+
+		m.logProgress(w, msg)
+		if submitting && m.phase != PhasePushing {
+			m.logSubmitted(w)
+		}
+
+		if m.phase == PhaseConfirmation {
+			m, cmd = m.startLand()
+			run(cmd)
+		}
+	}
+
+	switch m.phase {
+	case PhaseError:
+		return m, m.err
+	case PhaseNothingToLand:
+		if m.blocker != "" {
+			fmt.Fprintf(w, "Nothing to land: %s.\n", m.blocker)
+		} else {
+			fmt.Fprintln(w, "Nothing to land.")
+		}
+	case PhaseComplete:
+		fmt.Fprintf(w, "%d pull request(s) landed.\n", m.mergedCount)
+	}
+
+	return m, nil
+}
+
+// logProgress writes a single line describing the result of msg
+func (m Model) logProgress(w io.Writer, msg tea.Msg) {
+	switch msg := msg.(type) {
+	case LoadedMsg:
+		if msg.Err == nil && len(msg.Land) > 0 {
+			fmt.Fprintf(w, "%d pull request(s) will be merged into %s with %s.\n",
+				len(msg.Land), m.trunkName, m.cfg.MergeMethod)
+		}
+
+	case MergedMsg:
+		if msg.Err != nil {
+			fmt.Fprintf(w, "%s: %v\n", m.shortID(msg.ChangeID), msg.Err)
+			return
+		}
+		fmt.Fprintf(w, "%s: merged PR #%d\n", m.shortID(msg.ChangeID), m.prs[msg.ChangeID].GetNumber())
+
+	case RetargetedMsg:
+		if msg.Err != nil {
+			fmt.Fprintf(w, "%s: %v\n", m.shortID(msg.ChangeID), msg.Err)
+			return
+		}
+		fmt.Fprintf(w, "%s: PR #%d now targets %s\n", m.shortID(msg.ChangeID), m.prs[msg.ChangeID].GetNumber(), m.trunkName)
+
+	case RebasedMsg:
+		if msg.Err == nil && !msg.HasConflict {
+			fmt.Fprintf(w, "Rebased the rest of the stack onto %s.\n", m.trunkName)
+		}
+	}
+}
+
+// logSubmitted writes a line for each PR of the rest of the stack once it was
+// resubmitted
+func (m Model) logSubmitted(w io.Writer) {
+	for _, rev := range m.submit.Report().Revisions {
+		if rev.PRNumber != 0 {
+			fmt.Fprintf(w, "%s: PR #%d: %s\n", m.shortID(rev.ChangeID), rev.PRNumber, rev.Action)
+		}
+	}
+}
+
+// shortID returns the short change ID for display, falling back to the full ID
+func (m Model) shortID(changeID string) string {
+	for _, change := range m.changes {
+		if change.ID == changeID {
+			return change.ShortID
+		}
+	}
+	return changeID
+}
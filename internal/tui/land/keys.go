@@ -0,0 +1,23 @@
+package land
+
+import "github.com/charmbracelet/bubbles/key"
+
+// KeyMap defines the key bindings for the land TUI
+type KeyMap struct {
+	Land key.Binding
+	Quit key.Binding
+}
+
+// DefaultKeyMap returns the default key bindings
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Land: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "land"),
+		),
+		Quit: key.NewBinding(
+			key.WithKeys("q", "ctrl+c"),
+			key.WithHelp("q", "quit"),
+		),
+	}
+}
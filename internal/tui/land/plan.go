@@ -0,0 +1,62 @@
+package land
+
+import (
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// notReadyReason returns why the pull request of change can't be landed yet,
+// or "" if it is ready.
+func notReadyReason(change jj.Change, pr *gogithub.PullRequest, status github.PullRequestStatus) string {
+	switch {
+	case pr == nil:
+		return "no pull request, run submit first"
+	case pr.GetDraft():
+		return "pull request is a draft"
+	case pr.GetHead().GetSHA() != change.CommitID:
+		return "local commit differs from PR, run submit first"
+	case status.Review == github.ReviewChangesRequested:
+		return "changes requested"
+	case status.Checks == github.ChecksFailure:
+		return "checks failing"
+	case status.Checks == github.ChecksPending:
+		return "checks pending"
+	}
+
+	switch status.MergeableState {
+	case "dirty":
+		return "has conflicts with its base"
+	case "blocked":
+		return "blocked by branch protection"
+	case "behind":
+		return "behind its base"
+	}
+
+	return ""
+}
+
+// landable returns the change IDs at the bottom of changes that are ready to
+// land, stopping at the first one that isn't or after limit changes when limit
+// is positive. It also returns why the next change can't be landed, if any.
+// changes must be ordered bottom first.
+func landable(
+	changes []jj.Change,
+	prs map[string]*gogithub.PullRequest,
+	statuses map[int]github.PullRequestStatus,
+	limit int,
+) ([]string, string) {
+	var ready []string
+	for _, change := range changes {
+		if limit > 0 && len(ready) == limit {
+			return ready, ""
+		}
+
+		pr := prs[change.ID]
+		if reason := notReadyReason(change, pr, statuses[pr.GetNumber()]); reason != "" {
+			return ready, reason
+		}
+		ready = append(ready, change.ID)
+	}
+	return ready, ""
+}
@@ -0,0 +1,84 @@
+package land
+
+import (
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotReadyReason(t *testing.T) {
+	change := jj.Change{ID: "abc", CommitID: "local"}
+	ready := func() *gogithub.PullRequest {
+		return &gogithub.PullRequest{
+			Number: gogithub.Ptr(1),
+			Head:   &gogithub.PullRequestBranch{SHA: gogithub.Ptr("local")},
+		}
+	}
+
+	for _, tc := range []struct {
+		Name     string
+		PR       func() *gogithub.PullRequest
+		Status   github.PullRequestStatus
+		Expected string
+	}{
+		{
+			Name:     "ready",
+			PR:       ready,
+			Status:   github.PullRequestStatus{Review: github.ReviewApproved, Checks: github.ChecksSuccess, MergeableState: "clean"},
+			Expected: "",
+		},
+		{
+			Name:     "no checks or reviews required",
+			PR:       ready,
+			Expected: "",
+		},
+		{
+			Name:     "no PR",
+			PR:       func() *gogithub.PullRequest { return nil },
+			Expected: "no pull request, run submit first",
+		},
+		{
+			Name: "draft",
+			PR: func() *gogithub.PullRequest {
+				pr := ready()
+				pr.Draft = gogithub.Ptr(true)
+				return pr
+			},
+			Expected: "pull request is a draft",
+		},
+		{
+			Name: "not pushed",
+			PR: func() *gogithub.PullRequest {
+				pr := ready()
+				pr.Head.SHA = gogithub.Ptr("remote")
+				return pr
+			},
+			Expected: "local commit differs from PR, run submit first",
+		},
+		{
+			Name:     "changes requested",
+			PR:       ready,
+			Status:   github.PullRequestStatus{Review: github.ReviewChangesRequested},
+			Expected: "changes requested",
+		},
+		{
+			Name:     "checks pending",
+			PR:       ready,
+			Status:   github.PullRequestStatus{Checks: github.ChecksPending},
+			Expected: "checks pending",
+		},
+		{
+			Name:     "conflicts",
+			PR:       ready,
+			Status:   github.PullRequestStatus{MergeableState: "dirty"},
+			Expected: "has conflicts with its base",
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, notReadyReason(change, tc.PR(), tc.Status))
+		})
+	}
+}
@@ -0,0 +1,322 @@
+// Package status renders the current stack annotated with the state of each
+// revision's pull request, without pushing or changing anything.
+package status
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/prmap"
+	"github.com/cbrewster/jj-github/internal/tui/components"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// Phase represents the current phase of the status workflow
+type Phase int
+
+const (
+	PhaseLoading Phase = iota
+	PhaseComplete
+	PhaseError
+)
+
+// RevisionStatus is the pull request state of a single revision
+type RevisionStatus struct {
+	Change jj.Change
+	PR     *gogithub.PullRequest    // Open PR, or the latest closed one; nil if none
+	Status github.PullRequestStatus // Only set for open PRs
+}
+
+// LocalDiffers reports whether the local commit differs from the PR head
+func (r RevisionStatus) LocalDiffers() bool {
+	return r.PR != nil && r.PR.GetHead().GetSHA() != r.Change.CommitID
+}
+
+// Summary describes the PR state in a single line
+func (r RevisionStatus) Summary() string {
+	switch {
+	case r.PR == nil:
+		return "no pull request"
+	case r.PR.MergedAt != nil:
+		return "merged"
+	case r.PR.GetState() == "closed":
+		return "closed"
+	}
+
+	parts := []string{"open"}
+	if r.PR.GetDraft() {
+		parts[0] = "draft"
+	}
+
+	switch r.Status.Review {
+	case github.ReviewApproved:
+		parts = append(parts, "approved")
+	case github.ReviewChangesRequested:
+		parts = append(parts, "changes requested")
+	default:
+		parts = append(parts, "no reviews")
+	}
+
+	switch r.Status.Checks {
+	case github.ChecksSuccess:
+		parts = append(parts, "checks passing")
+	case github.ChecksFailure:
+		parts = append(parts, "checks failing")
+	case github.ChecksPending:
+		parts = append(parts, "checks pending")
+	default:
+		parts = append(parts, "no checks")
+	}
+
+	switch r.Status.MergeableState {
+	case "clean", "has_hooks":
+		parts = append(parts, "mergeable")
+	case "unstable":
+		parts = append(parts, "mergeable with failing checks")
+	case "dirty":
+		parts = append(parts, "has conflicts")
+	case "behind":
+		parts = append(parts, "behind base")
+	case "blocked":
+		parts = append(parts, "blocked")
+	}
+
+	if r.LocalDiffers() {
+		parts = append(parts, "local commit differs from PR")
+	}
+
+	return strings.Join(parts, " · ")
+}
+
+// StatusLoadedMsg carries the loaded stack and PR states
+type StatusLoadedMsg struct {
+	Changes   []jj.Change
+	TrunkName string
+	Revisions map[string]RevisionStatus // Keyed by change ID
+	Err       error
+}
+
+// Model is the main bubbletea model for the status TUI
+type Model struct {
+	// State
+	phase     Phase
+	stack     components.Stack
+	spinner   components.Spinner
+	keys      KeyMap
+	err       error
+	width     int
+	revisions map[string]RevisionStatus
+
+	// Dependencies
+	ctx      context.Context
+	jj       *jj.Client
+	gh       *github.Client
+	cfg      config.Config
+	repo     github.Repo
+	headRepo github.Repo
+	revset   string
+	prStore  *prmap.Store
+}
+
+// Options configures which repositories and revisions status reports on
+type Options struct {
+	// Repo is the repository pull requests are opened against.
+	Repo github.Repo
+	// HeadRepo is the repository branches are pushed to. Defaults to Repo.
+	HeadRepo github.Repo
+	// Revset selects the revisions to show.
+	Revset string
+	// Config holds the jj-github settings.
+	Config config.Config
+	// PRs remembers the pull request of each change, so it is found even
+	// after its bookmark is renamed. Optional.
+	PRs *prmap.Store
+}
+
+// NewModel creates a new status TUI model
+func NewModel(ctx context.Context, jjClient *jj.Client, gh *github.Client, opts Options) Model {
+	headRepo := opts.HeadRepo
+	if headRepo == (github.Repo{}) {
+		headRepo = opts.Repo
+	}
+
+	return Model{
+		phase:    PhaseLoading,
+		spinner:  components.NewSpinner(),
+		keys:     DefaultKeyMap(),
+		ctx:      ctx,
+		jj:       jjClient,
+		gh:       gh,
+		cfg:      opts.Config,
+		repo:     opts.Repo,
+		headRepo: headRepo,
+		revset:   opts.Revset,
+		prStore:  opts.PRs,
+	}
+}
+
+// headOwner returns the owner of the fork branches are pushed to, or "" when
+// branches are pushed to the pull request repository itself
+func (m Model) headOwner() string {
+	if m.headRepo.Owner == m.repo.Owner {
+		return ""
+	}
+	return m.headRepo.Owner
+}
+
+// Init initializes the model and starts loading the stack
+func (m Model) Init() tea.Cmd {
+	return tea.Batch(
+		m.spinner.Tick(),
+		m.loadCmd(),
+	)
+}
+
+// Update handles messages and updates the model
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
+
+	case tea.KeyMsg:
+		if key.Matches(msg, m.keys.Quit) {
+			return m, tea.Quit
+		}
+
+	case StatusLoadedMsg:
+		if msg.Err != nil {
+			m.phase = PhaseError
+			m.err = msg.Err
+			return m, tea.Quit
+		}
+
+		m.revisions = msg.Revisions
+		m.stack = components.NewStack(msg.Changes, msg.TrunkName)
+		for i := range m.stack.Revisions {
+			rev := &m.stack.Revisions[i]
+			if rev.IsImmutable {
+				continue
+			}
+			status, ok := m.revisions[rev.Change.ID]
+			if !ok {
+				continue
+			}
+			rev.PRNumber = status.PR.GetNumber()
+			rev.NeedsSync = status.PR == nil || status.LocalDiffers()
+			rev.Details = status.Summary()
+		}
+
+		m.phase = PhaseComplete
+		return m, tea.Quit
+	}
+
+	var cmd tea.Cmd
+	m.spinner, cmd = m.spinner.Update(msg)
+	return m, cmd
+}
+
+// View renders the UI
+func (m Model) View() string {
+	var sb strings.Builder
+
+	width := m.width
+	if width == 0 {
+		width = 80
+	}
+
+	viewOpts := components.ViewOptions{
+		Repo:     m.repo,
+		Width:    width,
+		NoPRText: "(no PR)",
+	}
+
+	switch m.phase {
+	case PhaseLoading:
+		sb.WriteString(m.spinner.View())
+		sb.WriteString(" Fetching pull request state...\n")
+
+	case PhaseComplete:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+
+	case PhaseError:
+		sb.WriteString(components.ErrorStyle.Render("Status failed"))
+		sb.WriteString("\n\n")
+		if m.err != nil {
+			sb.WriteString(components.ErrorStyle.Render(m.err.Error()))
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+func (m Model) loadCmd() tea.Cmd {
+	return func() tea.Msg {
+		changes, err := m.jj.GetChanges(jj.StackRevset(m.revset))
+		if err != nil {
+			return StatusLoadedMsg{Err: err}
+		}
+
+		trunkName, err := m.jj.GetTrunkName()
+		if err != nil {
+			return StatusLoadedMsg{Err: fmt.Errorf("get trunk name: %w", err)}
+		}
+
+		var described []jj.Change
+		for _, change := range changes {
+			if !change.Immutable && change.Description != "" {
+				described = append(described, change)
+			}
+		}
+
+		revisions := make(map[string]RevisionStatus)
+		if len(described) == 0 {
+			return StatusLoadedMsg{Changes: changes, TrunkName: trunkName, Revisions: revisions}
+		}
+
+		openPRs, err := m.prStore.Find(m.ctx, m.gh, m.repo, m.headOwner(), described)
+		if err != nil {
+			return StatusLoadedMsg{Err: err}
+		}
+
+		var withoutOpenPR []string
+		var prs []*gogithub.PullRequest
+		for _, change := range described {
+			if pr, ok := openPRs[change.ID]; ok {
+				prs = append(prs, pr)
+			} else {
+				withoutOpenPR = append(withoutOpenPR, change.GitPushBookmark)
+			}
+		}
+
+		closedPRs, err := m.gh.GetClosedPullRequestsForBranches(m.ctx, m.repo, m.headOwner(), withoutOpenPR)
+		if err != nil {
+			return StatusLoadedMsg{Err: err}
+		}
+
+		statuses, err := m.gh.GetPullRequestStatuses(m.ctx, m.repo, prs)
+		if err != nil {
+			return StatusLoadedMsg{Err: err}
+		}
+
+		for _, change := range described {
+			status := RevisionStatus{Change: change}
+			if pr, ok := openPRs[change.ID]; ok {
+				status.PR = pr
+				status.Status = statuses[pr.GetNumber()]
+			} else {
+				status.PR = closedPRs[change.GitPushBookmark]
+			}
+			revisions[change.ID] = status
+		}
+
+		return StatusLoadedMsg{Changes: changes, TrunkName: trunkName, Revisions: revisions}
+	}
+}
@@ -0,0 +1,114 @@
+package status
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/prmap"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHeadless(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-bbbb", "c2-old")
+	server.SetBranch(githubtest.DefaultRepo, "push-cccc", "c3")
+	server.SetRequiredChecks(githubtest.DefaultRepo, "main", "build")
+
+	merged := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-aaaa")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+	server.MergePullRequest(githubtest.DefaultRepo, merged.GetNumber())
+
+	open := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head:           &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-bbbb")},
+		Base:           &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+		MergeableState: gogithub.Ptr("clean"),
+	})
+	server.AddReview(githubtest.DefaultRepo, open.GetNumber(), "alice", "APPROVED")
+	server.AddCheckRun(githubtest.DefaultRepo, "c2-old", "build", "completed", "success")
+
+	draft := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Draft:          gogithub.Ptr(true),
+		Head:           &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-cccc")},
+		Base:           &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-bbbb")},
+		MergeableState: gogithub.Ptr("dirty"),
+	})
+	server.AddReview(githubtest.DefaultRepo, draft.GetNumber(), "alice", "CHANGES_REQUESTED")
+	server.AddStatus(githubtest.DefaultRepo, "c3", "ci", "pending")
+
+	stack := jjtest.Trunk +
+		jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0") +
+		jjtest.Change("bbbb", "c2", "Add login form\n", "aaaa", "c1") +
+		jjtest.Change("cccc", "c3", "WIP: add logout\n", "bbbb", "c2") +
+		jjtest.Change("dddd", "c4", "Add settings\n", "cccc", "c3")
+
+	r := jjtest.NewRunner()
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(stack, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", jj.StackRevset("@"))
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.Trunk, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", "trunk()")
+
+	var out strings.Builder
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), server.Client(t), Options{
+		Repo:   githubtest.DefaultRepo,
+		Revset: "@",
+		Config: config.Default(),
+	}), &out)
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+	assert.Empty(t, r.Unused())
+	for _, call := range r.Calls() {
+		assert.NotEqual(t, "git", call[0], "status must not fetch or push")
+	}
+
+	assert.Equal(t, strings.Join([]string{
+		"d  -  Add settings  no pull request",
+		"c  #3  WIP: add logout  draft · changes requested · checks pending · has conflicts",
+		"b  #2  Add login form  open · approved · checks passing · mergeable · local commit differs from PR",
+		"a  #1  Add auth  merged",
+		"main",
+		"",
+	}, "\n"), out.String())
+}
+
+func TestRunHeadlessRenamedBookmark(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "old-aaaa", "c1")
+	pr := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Head: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("old-aaaa")},
+		Base: &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+
+	// The PR was opened before templates.git_push_bookmark changed
+	prs, err := prmap.Load(prmap.Path(t.TempDir()))
+	require.NoError(t, err)
+	prs.Set(githubtest.DefaultRepo, "aaaa", pr.GetNumber())
+
+	r := jjtest.NewRunner()
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.Trunk+jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0"),
+		"log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", jj.StackRevset("@"))
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.Trunk, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", "trunk()")
+
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), server.Client(t), Options{
+		Repo:   githubtest.DefaultRepo,
+		Revset: "@",
+		Config: config.Default(),
+		PRs:    prs,
+	}), io.Discard)
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.Equal(t, pr.GetNumber(), m.revisions["aaaa"].PR.GetNumber())
+}
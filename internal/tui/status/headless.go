@@ -0,0 +1,30 @@
+package status
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RunHeadless loads the status without a bubbletea program and writes one
+// plain line per revision to w, current revision first.
+// Returns the final model and the load error, if any.
+func RunHeadless(m Model, w io.Writer) (Model, error) {
+	next, _ := m.Update(m.loadCmd()())
+	m = next.(Model)
+	if m.phase == PhaseError {
+		return m, m.err
+	}
+
+	for _, rev := range m.stack.MutableRevisions() {
+		title, _, _ := strings.Cut(rev.Change.Description, "\n")
+		pr := "-"
+		if rev.PRNumber > 0 {
+			pr = fmt.Sprintf("#%d", rev.PRNumber)
+		}
+		fmt.Fprintf(w, "%s  %s  %s  %s\n", rev.Change.ShortID, pr, title, rev.Details)
+	}
+	fmt.Fprintf(w, "%s\n", m.stack.TrunkName)
+
+	return m, nil
+}
@@ -3,10 +3,16 @@ package submit
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/cbrewster/jj-github/internal/config"
 	"github.com/cbrewster/jj-github/internal/github"
 	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/prbody"
+	"github.com/cbrewster/jj-github/internal/prmap"
+	"github.com/cbrewster/jj-github/internal/report"
+	"github.com/cbrewster/jj-github/internal/stackgraph"
 	"github.com/cbrewster/jj-github/internal/tui/components"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
@@ -21,6 +27,7 @@ const (
 	PhaseLoading Phase = iota
 	PhaseUpToDate
 	PhaseConfirmation
+	PhaseImporting
 	PhaseSyncing
 	PhaseUpdatingComments
 	PhaseComplete
@@ -36,20 +43,29 @@ type (
 		Changes       []jj.Change
 		TrunkName     string
 		ExistingPRs   map[string]*gogithub.PullRequest
+		Adopted       map[string]bool // Change IDs pushed to the branch of a PR found by change ID
 		NeedsSync     bool
 		NeedsSyncByID map[string]bool // Maps change ID to whether it needs sync
-		Err           error
+		Plans         map[string]RevisionPlan
+		Reviewed      map[int][]string // Users that reviewed each PR, if looked up
+		Template      string           // Pull request template from trunk, if any
+		// Merged or closed PRs of revisions left alone, by branch
+		ClosedPRs map[string]*gogithub.PullRequest
+		Err       error
 	}
 
-	RevisionPushedMsg struct {
-		Change jj.Change
-		Err    error
+	RevisionsPushedMsg struct {
+		Changes []jj.Change // Pushed parents first
+		Err     error
 	}
 
 	RevisionSyncedMsg struct {
 		ChangeID string
 		PRNumber int
 		Created  bool
+		Updated  bool                  // Whether the PR metadata was edited
+		Skipped  bool                  // Left alone because of foreign commits
+		PR       *gogithub.PullRequest // The PR opened, when Created
 		Err      error
 	}
 
@@ -68,35 +84,100 @@ type Model struct {
 	err     error
 	width   int // Terminal width
 
-	// Tracking sync progress
-	currentIndex int
-	totalCount   int
+	// Tracking sync progress. PRs are synced concurrently once the PR of
+	// their base is.
+	started    map[string]bool // Change IDs whose PR sync has started
+	synced     map[string]bool // Change IDs whose PR is synced
+	syncing    int             // PR syncs in flight
+	totalCount int
 
 	// Dependencies
-	ctx    context.Context
-	gh     *github.Client
-	repo   github.Repo
-	revset string
+	ctx      context.Context
+	jj       *jj.Client
+	gh       *github.Client
+	cfg      config.Config
+	repo     github.Repo
+	headRepo github.Repo
+	revset   string
+	prs      *prmap.Store
+
+	existingOnly bool
+	noFetch      bool
 
 	// Data from loading phase
 	changes       []jj.Change
 	trunkName     string
 	existingPRs   map[string]*gogithub.PullRequest
+	closedPRs     map[string]*gogithub.PullRequest
+	adopted       map[string]bool
+	plans         map[string]RevisionPlan
+	reviewed      map[int][]string
+	template      string
 	stackComments map[int]*gogithub.IssueComment
+
+	// What to do with foreign commits per change ID, kept across reloads
+	resolutions map[string]config.ForeignCommitPolicy
+
+	// Outcome per change ID, for reporting
+	actions map[string]report.Action
+}
+
+// Options configures which repositories and revisions submit works on
+type Options struct {
+	// Repo is the repository pull requests are opened against.
+	Repo github.Repo
+	// HeadRepo is the repository branches are pushed to. It differs from Repo
+	// when pushing to a personal fork. Defaults to Repo.
+	HeadRepo github.Repo
+	// Revset selects the revisions to submit.
+	Revset string
+	// Config holds the jj-github settings, including the remotes to push
+	// to and fetch from.
+	Config config.Config
+	// PRs remembers the pull request of each change across runs, so it is
+	// found even after its bookmark is renamed. Optional.
+	PRs *prmap.Store
+	// ExistingOnly only updates revisions that already have an open PR and
+	// never creates new ones, e.g. when resubmitting stacks after sync.
+	ExistingOnly bool
+	// NoFetch skips the git fetch, for callers that just fetched.
+	NoFetch bool
 }
 
 // NewModel creates a new TUI model
-func NewModel(ctx context.Context, gh *github.Client, repo github.Repo, revset string) Model {
+func NewModel(ctx context.Context, jjClient *jj.Client, gh *github.Client, opts Options) Model {
+	headRepo := opts.HeadRepo
+	if headRepo == (github.Repo{}) {
+		headRepo = opts.Repo
+	}
+
 	return Model{
-		phase:       PhaseLoading,
-		spinner:     components.NewSpinner(),
-		keys:        DefaultKeyMap(),
-		ctx:         ctx,
-		gh:          gh,
-		repo:        repo,
-		revset:      revset,
-		existingPRs: make(map[string]*gogithub.PullRequest),
+		phase:        PhaseLoading,
+		spinner:      components.NewSpinner(),
+		keys:         DefaultKeyMap(),
+		ctx:          ctx,
+		jj:           jjClient,
+		gh:           gh,
+		cfg:          opts.Config,
+		repo:         opts.Repo,
+		headRepo:     headRepo,
+		revset:       opts.Revset,
+		prs:          opts.PRs,
+		existingOnly: opts.ExistingOnly,
+		noFetch:      opts.NoFetch,
+		existingPRs:  make(map[string]*gogithub.PullRequest),
+		resolutions:  make(map[string]config.ForeignCommitPolicy),
+		actions:      make(map[string]report.Action),
+	}
+}
+
+// headOwner returns the owner of the fork branches are pushed to, or "" when
+// branches are pushed to the pull request repository itself
+func (m Model) headOwner() string {
+	if m.headRepo.Owner == m.repo.Owner {
+		return ""
 	}
+	return m.headRepo.Owner
 }
 
 // Init initializes the model and starts loading revisions
@@ -117,15 +198,42 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		keys := m.keyMap()
 		switch {
-		case key.Matches(msg, m.keys.Quit):
+		case key.Matches(msg, keys.Quit):
+			return m, tea.Quit
+		case key.Matches(msg, keys.Submit):
+			return m.startSync()
+		}
+
+		if plan, ok := m.pendingForeign(); ok {
+			switch {
+			case key.Matches(msg, keys.Import):
+				return m.resolve(plan.Change.ID, config.ForeignImport)
+			case key.Matches(msg, keys.Overwrite):
+				return m.resolve(plan.Change.ID, config.ForeignOverwrite)
+			case key.Matches(msg, keys.Skip):
+				return m.resolve(plan.Change.ID, config.ForeignSkip)
+			}
+		}
+
+	case ForeignImportedMsg:
+		if msg.Err != nil {
+			m.stack.SetRevisionError(msg.ChangeID, msg.Err)
+			m.phase = PhaseError
+			m.err = msg.Err
 			return m, tea.Quit
-		case key.Matches(msg, m.keys.Submit) && m.phase == PhaseConfirmation:
-			m.phase = PhaseSyncing
-			m.currentIndex = 0
-			return m, m.pushNextRevisionCmd()
 		}
 
+		// The imported commits are part of the revision now, so pushing over
+		// them loses nothing.
+		m.resolutions[msg.ChangeID] = config.ForeignOverwrite
+		if id := m.nextImport(); id != "" {
+			return m, m.importForeignCmd(m.plans[id])
+		}
+		m.phase = PhaseLoading
+		return m, m.loadRevisionsAndPRsCmd()
+
 	case RevisionsLoadedMsg:
 		if msg.Err != nil {
 			m.phase = PhaseError
@@ -136,7 +244,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.changes = msg.Changes
 		m.trunkName = msg.TrunkName
 		m.existingPRs = msg.ExistingPRs
-		m.stack = components.NewStack(msg.Changes, msg.TrunkName)
+		m.closedPRs = msg.ClosedPRs
+		m.adopted = msg.Adopted
+		m.plans = msg.Plans
+		m.reviewed = msg.Reviewed
+		m.template = msg.Template
+		m.stack = components.NewStack(m.updatable(msg.Changes), msg.TrunkName)
 		m.totalCount = len(m.stack.MutableRevisions())
 
 		// Set PR numbers and sync status for existing PRs on the stack
@@ -156,6 +269,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					rev.State = components.StateSuccess
 				}
 			}
+			if m.adopted[rev.Change.ID] {
+				rev.Details = "pushed to " + rev.Change.GitPushBookmark + ", the branch of its PR"
+			}
+			if foreign := len(m.plans[rev.Change.ID].Foreign); foreign > 0 {
+				rev.Details = fmt.Sprintf("%d commit(s) on GitHub not pushed from jj", foreign)
+			}
 		}
 
 		if !msg.NeedsSync {
@@ -164,38 +283,89 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		m.phase = PhaseConfirmation
+		if m.cfg.ForeignCommits != config.ForeignAsk {
+			for plan, ok := m.pendingForeign(); ok; plan, ok = m.pendingForeign() {
+				m.resolutions[plan.Change.ID] = m.cfg.ForeignCommits
+			}
+			if id := m.nextImport(); id != "" {
+				m.phase = PhaseImporting
+				return m, m.importForeignCmd(m.plans[id])
+			}
+		}
 		return m, nil
 
-	case RevisionPushedMsg:
+	case RevisionsPushedMsg:
 		if msg.Err != nil {
-			m.stack.SetRevisionError(msg.Change.ID, msg.Err)
+			for _, change := range msg.Changes {
+				m.actions[change.ID] = report.ActionFailed
+				m.stack.SetRevisionError(change.ID, msg.Err)
+			}
 			m.phase = PhaseError
 			m.err = msg.Err
 			return m, nil
 		}
 
-		// Push succeeded, now sync the PR
-		return m, m.syncRevisionPRCmd(msg.Change)
+		// Push succeeded, now sync the PRs
+		for _, change := range msg.Changes {
+			m.stack.SetRevisionState(change.ID, components.StatePending, "Waiting for base PR...")
+		}
+		m, cmd := m.syncReadyRevisions()
+		return m, cmd
 
 	case RevisionSyncedMsg:
+		m.syncing--
+		if msg.Err == nil && msg.PRNumber != 0 {
+			m.prs.Set(m.repo, msg.ChangeID, msg.PRNumber)
+		}
+		if msg.PR != nil {
+			for _, change := range m.changes {
+				if change.ID == msg.ChangeID {
+					m.existingPRs[change.GitPushBookmark] = msg.PR
+				}
+			}
+		}
+
 		if msg.Err != nil {
+			m.actions[msg.ChangeID] = report.ActionFailed
 			m.stack.SetRevisionError(msg.ChangeID, msg.Err)
-			m.phase = PhaseError
-			m.err = msg.Err
+			if m.phase != PhaseError {
+				m.phase = PhaseError
+				m.err = msg.Err
+			}
 			return m, nil
 		}
 
+		pushed := m.needsPush(msg.ChangeID)
+		switch {
+		case msg.Skipped:
+			m.actions[msg.ChangeID] = report.ActionSkippedForeign
+		case msg.Created:
+			m.actions[msg.ChangeID] = report.ActionCreated
+		case msg.Updated || pushed:
+			m.actions[msg.ChangeID] = report.ActionUpdated
+		default:
+			m.actions[msg.ChangeID] = report.ActionUnchanged
+		}
+
 		m.stack.SetRevisionPR(msg.ChangeID, msg.PRNumber)
 		m.stack.SetRevisionState(msg.ChangeID, components.StateSuccess, "")
-		m.currentIndex++
+		m.stack.SetRevisionDetails(msg.ChangeID, syncSummary(pushed, msg))
+		m.synced[msg.ChangeID] = true
 
-		mutableRevs := m.stack.MutableRevisions()
-		if m.currentIndex < len(mutableRevs) {
-			return m, m.pushNextRevisionCmd()
+		// Let the syncs in flight finish after another one failed
+		if m.phase == PhaseError {
+			return m, nil
+		}
+		if len(m.synced) < m.totalCount {
+			m, cmd := m.syncReadyRevisions()
+			return m, cmd
 		}
 
 		// Move to comments phase
 		m.phase = PhaseUpdatingComments
+		if m.cfg.StackLocation == config.StackInBody {
+			return m, m.updateAllStackSectionsCmd()
+		}
 		return m, m.updateAllCommentsCmd()
 
 	case AllCommentsUpdatedMsg:
@@ -217,6 +387,89 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// keyMap returns the key bindings, enabled according to the current state
+func (m Model) keyMap() KeyMap {
+	keys := m.keys
+	_, pending := m.pendingForeign()
+	confirming := m.phase == PhaseConfirmation
+	keys.Submit.SetEnabled(keys.Submit.Enabled() && confirming && !pending)
+	keys.Import.SetEnabled(confirming && pending)
+	keys.Overwrite.SetEnabled(confirming && pending)
+	keys.Skip.SetEnabled(confirming && pending)
+	return keys
+}
+
+// startSync leaves the confirmation phase and begins pushing revisions
+func (m Model) startSync() (Model, tea.Cmd) {
+	m.phase = PhaseSyncing
+	m.started = make(map[string]bool)
+	m.synced = make(map[string]bool)
+	m.syncing = 0
+	return m, m.pushAllRevisionsCmd()
+}
+
+// Phase returns the current phase of the workflow
+func (m Model) Phase() Phase {
+	return m.phase
+}
+
+// Err returns the error the workflow failed with, if any
+func (m Model) Err() error {
+	return m.err
+}
+
+// Confirm starts submitting as if the user confirmed, once the model waits
+// for confirmation. Commits on GitHub that weren't pushed from jj still need a
+// decision: interactively the prompt stays up, otherwise the workflow fails.
+func (m Model) Confirm(interactive bool) (Model, tea.Cmd) {
+	if m.phase != PhaseConfirmation {
+		return m, nil
+	}
+	if _, pending := m.pendingForeign(); pending {
+		if !interactive {
+			m.phase = PhaseError
+			m.err = m.foreignError()
+		}
+		return m, nil
+	}
+	return m.startSync()
+}
+
+// syncReadyRevisions starts syncing the PRs of revisions whose base PR is
+// synced, parents first, keeping at most the configured number in flight
+func (m Model) syncReadyRevisions() (Model, tea.Cmd) {
+	changesByID := make(map[string]*jj.Change)
+	for i := range m.changes {
+		changesByID[m.changes[i].ID] = &m.changes[i]
+	}
+
+	// Revisions are in reverse order (current at top), so parents come last
+	mutableRevs := m.stack.MutableRevisions()
+	var cmds []tea.Cmd
+	for _, rev := range slices.Backward(mutableRevs) {
+		if m.syncing >= max(m.cfg.Concurrency, 1) {
+			break
+		}
+		if m.started[rev.Change.ID] {
+			continue
+		}
+
+		// A PR based on another PR of the stack waits for it, so PRs are
+		// opened in stack order.
+		parent, _ := m.baseParent(rev.Change, changesByID)
+		waiting := parent != nil && m.headOwner() == "" && !m.synced[parent.ID] &&
+			slices.ContainsFunc(mutableRevs, func(r components.Revision) bool { return r.Change.ID == parent.ID })
+		if waiting {
+			continue
+		}
+
+		m.started[rev.Change.ID] = true
+		m.syncing++
+		cmds = append(cmds, m.syncRevisionPRCmd(rev.Change, changesByID))
+	}
+	return m, tea.Batch(cmds...)
+}
+
 // View renders the UI
 func (m Model) View() string {
 	var sb strings.Builder
@@ -228,9 +481,8 @@ func (m Model) View() string {
 	}
 
 	viewOpts := components.ViewOptions{
-		RepoOwner: m.repo.Owner,
-		RepoName:  m.repo.Name,
-		Width:     width,
+		Repo:  m.repo,
+		Width: width,
 	}
 
 	switch m.phase {
@@ -247,6 +499,13 @@ func (m Model) View() string {
 	case PhaseConfirmation:
 		sb.WriteString(m.stack.View(m.spinner, viewOpts))
 		sb.WriteString("\n")
+		if plan, ok := m.pendingForeign(); ok {
+			sb.WriteString(components.ErrorStyle.Render(foreignView(plan)))
+			sb.WriteString("\n")
+			sb.WriteString(renderHelp(m.keyMap()))
+			sb.WriteString("\n")
+			break
+		}
 		syncCount := m.stack.RevisionsNeedingSync()
 		totalCount := len(m.stack.MutableRevisions())
 		if syncCount == totalCount {
@@ -254,9 +513,14 @@ func (m Model) View() string {
 		} else {
 			fmt.Fprintf(&sb, "%d of %d revision(s) will be synced to GitHub.\n\n", syncCount, totalCount)
 		}
-		sb.WriteString(renderHelp(m.keys))
+		sb.WriteString(renderHelp(m.keyMap()))
 		sb.WriteString("\n")
 
+	case PhaseImporting:
+		sb.WriteString(m.stack.View(m.spinner, viewOpts))
+		sb.WriteString(m.spinner.View())
+		sb.WriteString(" Importing commits from GitHub...\n\n")
+
 	case PhaseSyncing:
 		sb.WriteString(m.stack.View(m.spinner, viewOpts))
 		sb.WriteString("Syncing revisions...\n\n")
@@ -264,7 +528,11 @@ func (m Model) View() string {
 	case PhaseUpdatingComments:
 		sb.WriteString(m.stack.View(m.spinner, viewOpts))
 		sb.WriteString(m.spinner.View())
-		sb.WriteString(" Updating stack comments...\n\n")
+		if m.cfg.StackLocation == config.StackInBody {
+			sb.WriteString(" Updating stack in PR descriptions...\n\n")
+		} else {
+			sb.WriteString(" Updating stack comments...\n\n")
+		}
 
 	case PhaseComplete:
 		sb.WriteString(m.stack.View(m.spinner, viewOpts))
@@ -289,35 +557,26 @@ func (m Model) View() string {
 func (m Model) loadRevisionsAndPRsCmd() tea.Cmd {
 	return func() tea.Msg {
 		// Fetch from remote to get latest state (read-only for local repo)
-		if err := jj.GitFetch(); err != nil {
-			return RevisionsLoadedMsg{Err: fmt.Errorf("git fetch: %w", err)}
+		if !m.noFetch {
+			if err := m.jj.GitFetch(m.cfg.FetchRemotes()...); err != nil {
+				return RevisionsLoadedMsg{Err: fmt.Errorf("git fetch: %w", err)}
+			}
 		}
 
 		// Load revisions - include the immutable parent of the first mutable commit
 		// (for determining base branch) plus all commits in the revset.
-		// This works even if the revset is not directly on top of trunk().
-		changes, err := jj.GetChanges(fmt.Sprintf("(roots(::(%s) & mutable())- | ::(%s) & mutable()) & ~empty()", m.revset, m.revset))
+		changes, err := m.jj.GetChanges(jj.StackRevset(m.revset))
 		if err != nil {
 			return RevisionsLoadedMsg{Err: err}
 		}
 
 		// Determine trunk name using jj's trunk() revset
-		trunkName, err := jj.GetTrunkName()
+		trunkName, err := m.jj.GetTrunkName()
 		if err != nil {
 			return RevisionsLoadedMsg{Err: fmt.Errorf("get trunk name: %w", err)}
 		}
 
-		// Collect branches for mutable changes
-		var branches []string
-		var mutableChanges []jj.Change
-		for _, change := range changes {
-			if !change.Immutable && change.Description != "" {
-				branches = append(branches, change.GitPushBookmark)
-				mutableChanges = append(mutableChanges, change)
-			}
-		}
-
-		if len(mutableChanges) == 0 {
+		if !slices.ContainsFunc(changes, isSubmitted) {
 			return RevisionsLoadedMsg{
 				Changes:   changes,
 				TrunkName: trunkName,
@@ -325,11 +584,43 @@ func (m Model) loadRevisionsAndPRsCmd() tea.Cmd {
 			}
 		}
 
-		// Fetch existing PRs
-		existingPRs, err := m.gh.GetPullRequestsForBranches(m.ctx, m.repo, branches)
+		template, err := m.loadTemplate()
+		if err != nil {
+			return RevisionsLoadedMsg{Err: fmt.Errorf("load pull request template: %w", err)}
+		}
+		m.template = template
+
+		// Fetch existing PRs. This may point changes at the branch of their PR,
+		// so collect the mutable changes afterwards.
+		existingPRs, adopted, err := m.findPullRequests(changes)
 		if err != nil {
 			return RevisionsLoadedMsg{Err: err}
 		}
+		if m.existingOnly && len(existingPRs) == 0 {
+			return RevisionsLoadedMsg{
+				Changes:   changes,
+				TrunkName: trunkName,
+				NeedsSync: false,
+			}
+		}
+		m.existingPRs = existingPRs
+
+		// Revisions left alone may have a merged or closed PR, which the stack
+		// comments still list
+		var closedPRs map[string]*gogithub.PullRequest
+		if m.existingOnly {
+			var withoutPR []string
+			for _, change := range changes {
+				if isSubmitted(change) && existingPRs[change.GitPushBookmark] == nil {
+					withoutPR = append(withoutPR, change.GitPushBookmark)
+				}
+			}
+			closedPRs, err = m.gh.GetClosedPullRequestsForBranches(m.ctx, m.repo, m.headOwner(), withoutPR)
+			if err != nil {
+				return RevisionsLoadedMsg{Err: fmt.Errorf("look up closed pull requests: %w", err)}
+			}
+		}
+		mutableChanges := slices.DeleteFunc(slices.Clone(m.updatable(changes)), func(c jj.Change) bool { return !isSubmitted(c) })
 
 		// Check if sync is needed per revision
 		needsSync := false
@@ -339,174 +630,249 @@ func (m Model) loadRevisionsAndPRsCmd() tea.Cmd {
 			changesByID[changes[i].ID] = &changes[i]
 		}
 
+		// Merge revisions need a policy to choose their base
 		for _, change := range mutableChanges {
-			parent := changesByID[change.Parents[0].ChangeID]
-			var base string
-			if parent == nil {
-				// Parent not in our result set - use trunk as base
-				base = trunkName
-			} else if parent.Immutable {
-				if len(parent.Bookmarks) > 0 {
-					base = parent.Bookmarks[0].Name
-				} else {
-					base = trunkName
-				}
-			} else {
-				base = parent.GitPushBookmark
+			if _, err := m.baseParent(change, changesByID); err != nil {
+				return RevisionsLoadedMsg{Err: err}
 			}
+		}
 
-			title, body, _ := strings.Cut(change.Description, "\n")
-			isDraft := strings.Contains(strings.ToLower(title), "wip")
-
-			pr, exists := existingPRs[change.GitPushBookmark]
-			if !exists {
-				needsSync = true
-				needsSyncByID[change.ID] = true
-				continue
+		plans := make(map[string]RevisionPlan)
+		var unreviewed []int
+		for _, change := range mutableChanges {
+			pr := existingPRs[change.GitPushBookmark]
+			opts := m.prOptionsForChange(change, changesByID, trunkName, pr)
+			plans[change.ID] = planRevision(change, m.cfg.PushRemoteOrDefault(), opts, pr, nil)
+			if len(plans[change.ID].AddReviewers) > 0 && pr != nil {
+				unreviewed = append(unreviewed, pr.GetNumber())
 			}
+		}
+
+		// Reviewers that already reviewed are no longer listed as requested;
+		// look them up so they aren't asked again.
+		reviewed, err := m.gh.GetReviewers(m.ctx, m.repo, unreviewed)
+		if err != nil {
+			return RevisionsLoadedMsg{Err: err}
+		}
 
-			// Check if local commit matches remote head (need to push if different)
-			if pr.GetHead().GetSHA() != change.CommitID {
+		for _, change := range mutableChanges {
+			plan := plans[change.ID]
+			if pr := existingPRs[change.GitPushBookmark]; pr != nil && len(plan.AddReviewers) > 0 {
+				plan = planRevision(change, m.cfg.PushRemoteOrDefault(), plan.Options, pr, reviewed[pr.GetNumber()])
+				plans[change.ID] = plan
+			}
+			needsSyncByID[change.ID] = plan.NeedsSync()
+			if plan.NeedsSync() {
 				needsSync = true
-				needsSyncByID[change.ID] = true
-				continue
 			}
+		}
 
-			// Check if PR metadata needs update
-			// Normalize body comparison by trimming trailing whitespace, as GitHub may strip it
-			if pr.GetTitle() != title ||
-				strings.TrimRight(pr.GetBody(), " \t\n\r") != strings.TrimRight(body, " \t\n\r") ||
-				pr.GetBase().GetRef() != base ||
-				pr.GetDraft() != isDraft {
-				needsSync = true
-				needsSyncByID[change.ID] = true
+		// A branch that moved to a commit we didn't push was changed outside
+		// jj, e.g. by committing a suggestion on GitHub.
+		for _, change := range mutableChanges {
+			plan := plans[change.ID]
+			if !plan.Push || plan.RemoteSHA == "" {
 				continue
 			}
-
-			// This revision doesn't need sync
-			needsSyncByID[change.ID] = false
+			plan.Foreign, err = m.foreignCommits(change, plan.RemoteSHA, changesByID)
+			if err != nil {
+				return RevisionsLoadedMsg{Err: err}
+			}
+			plans[change.ID] = plan
 		}
 
 		return RevisionsLoadedMsg{
 			Changes:       changes,
 			TrunkName:     trunkName,
 			ExistingPRs:   existingPRs,
+			ClosedPRs:     closedPRs,
+			Adopted:       adopted,
 			NeedsSync:     needsSync,
 			NeedsSyncByID: needsSyncByID,
+			Plans:         plans,
+			Reviewed:      reviewed,
+			Template:      template,
 		}
 	}
 }
 
-func (m Model) pushNextRevisionCmd() tea.Cmd {
-	mutableRevs := m.stack.MutableRevisions()
-	// Revisions are in reverse order (current at top), so we process from the end
-	idx := len(mutableRevs) - 1 - m.currentIndex
-	if idx < 0 {
-		return nil
+// updatable drops the revisions this submit leaves alone: with ExistingOnly,
+// those without a PR. They stay in m.changes so the PRs above them keep
+// resolving their base through them.
+func (m Model) updatable(changes []jj.Change) []jj.Change {
+	if !m.existingOnly {
+		return changes
+	}
+	return slices.DeleteFunc(slices.Clone(changes), func(c jj.Change) bool {
+		return !c.Immutable && m.existingPRs[c.GitPushBookmark] == nil
+	})
+}
+
+// pushAllRevisionsCmd pushes the branches of all revisions that aren't
+// already on the remote with a single jj git push
+func (m Model) pushAllRevisionsCmd() tea.Cmd {
+	// Revisions are in reverse order (current at top), so push from the end
+	// Revisions that adopted the branch of their PR push that bookmark, which
+	// the template wouldn't name.
+	var changes, moved []jj.Change
+	var changeIDs, bookmarks []string
+	for _, rev := range slices.Backward(m.stack.MutableRevisions()) {
+		if !m.needsPush(rev.Change.ID) {
+			continue
+		}
+		m.stack.SetRevisionState(rev.Change.ID, components.StateInProgress, "Pushing...")
+		changes = append(changes, rev.Change)
+		if m.adopted[rev.Change.ID] {
+			bookmarks = append(bookmarks, rev.Change.GitPushBookmark)
+		} else {
+			changeIDs = append(changeIDs, rev.Change.ID)
+		}
+		if m.adopted[rev.Change.ID] || len(m.plans[rev.Change.ID].Foreign) > 0 {
+			moved = append(moved, rev.Change)
+		}
 	}
-	rev := mutableRevs[idx]
-	m.stack.SetRevisionState(rev.Change.ID, components.StateInProgress, "Pushing...")
 
 	return func() tea.Msg {
-		change := rev.Change
+		if len(changes) == 0 {
+			return RevisionsPushedMsg{}
+		}
 
-		// Push the branch
-		if err := jj.GitPush(change.ID); err != nil {
-			return RevisionPushedMsg{Change: change, Err: fmt.Errorf("push: %w", err)}
+		// Adopted bookmarks still point at the commit last pushed, and
+		// fetching moved the others to the foreign commits; point them at
+		// the revisions so the push replaces them.
+		for _, change := range moved {
+			if err := m.jj.MoveBookmark(change.GitPushBookmark, fmt.Sprintf("change_id(%s)", change.ID)); err != nil {
+				return RevisionsPushedMsg{Changes: changes, Err: err}
+			}
 		}
 
-		return RevisionPushedMsg{Change: change}
+		if err := m.jj.GitPush(m.cfg.PushRemoteOrDefault(), changeIDs, bookmarks); err != nil {
+			return RevisionsPushedMsg{Changes: changes, Err: fmt.Errorf("push: %w", err)}
+		}
+		return RevisionsPushedMsg{Changes: changes}
 	}
 }
 
-func (m Model) syncRevisionPRCmd(change jj.Change) tea.Cmd {
-	// Determine if we're creating or updating
-	_, exists := m.existingPRs[change.GitPushBookmark]
-	if exists {
-		m.stack.SetRevisionState(change.ID, components.StateInProgress, "Updating PR...")
-	} else {
-		m.stack.SetRevisionState(change.ID, components.StateInProgress, "Creating PR...")
+// needsPush reports whether the branch of a revision has to be pushed.
+// Revisions without a plan, such as those lacking a description, are always
+// pushed; those skipped because of foreign commits never are.
+func (m Model) needsPush(changeID string) bool {
+	if m.resolutions[changeID] == config.ForeignSkip {
+		return false
 	}
+	plan, ok := m.plans[changeID]
+	return !ok || plan.Push
+}
 
-	trunkName := m.trunkName
-	return func() tea.Msg {
-		// Build the PR options
-		changesByID := make(map[string]*jj.Change)
-		for i := range m.changes {
-			changesByID[m.changes[i].ID] = &m.changes[i]
-		}
-
-		parent := changesByID[change.Parents[0].ChangeID]
-		var base string
-		if parent == nil {
-			// Parent not in our result set - use trunk as base
-			base = trunkName
-		} else if parent.Immutable {
-			if len(parent.Bookmarks) > 0 {
-				base = parent.Bookmarks[0].Name
-			} else {
-				base = trunkName
-			}
-		} else {
-			base = parent.GitPushBookmark
-		}
-
-		title, body, _ := strings.Cut(change.Description, "\n")
-		isDraft := strings.Contains(strings.ToLower(title), "wip")
-
-		if pr, ok := m.existingPRs[change.GitPushBookmark]; ok {
-			// Check if update needed
-			// Normalize body comparison by trimming trailing whitespace, as GitHub may strip it
-			if pr.GetTitle() == title &&
-				strings.TrimRight(pr.GetBody(), " \t\n\r") == strings.TrimRight(body, " \t\n\r") &&
-				pr.GetHead().GetRef() == change.GitPushBookmark &&
-				pr.GetBase().GetRef() == base &&
-				pr.GetDraft() == isDraft {
-				return RevisionSyncedMsg{
-					ChangeID: change.ID,
-					PRNumber: pr.GetNumber(),
-					Created:  false,
-				}
-			}
+// syncSummary describes what was done for a revision, e.g. "pushed,
+// metadata updated"
+func syncSummary(pushed bool, msg RevisionSyncedMsg) string {
+	if msg.Skipped {
+		return "skipped: commits on GitHub not pushed from jj"
+	}
+
+	var actions []string
+	if pushed {
+		actions = append(actions, "pushed")
+	}
+	if msg.Created {
+		actions = append(actions, "created")
+	} else if msg.Updated {
+		actions = append(actions, "metadata updated")
+	}
+	if len(actions) == 0 {
+		return "untouched"
+	}
+	return strings.Join(actions, ", ")
+}
 
-			err := m.gh.UpdatePullRequest(m.ctx, m.repo, *pr.Number, github.PullRequestOptions{
-				Title:  title,
-				Body:   body,
-				Branch: change.GitPushBookmark,
-				Base:   base,
-				Draft:  isDraft,
-			})
+// syncRevisionPRCmd creates or updates the PR of change. The plan is built
+// before the command runs, as other syncs update the model concurrently.
+func (m Model) syncRevisionPRCmd(change jj.Change, changesByID map[string]*jj.Change) tea.Cmd {
+	if m.resolutions[change.ID] == config.ForeignSkip {
+		number := m.plans[change.ID].PRNumber
+		return func() tea.Msg {
+			return RevisionSyncedMsg{ChangeID: change.ID, PRNumber: number, Skipped: true}
+		}
+	}
+
+	if pr, ok := m.existingPRs[change.GitPushBookmark]; ok {
+		m.stack.SetRevisionState(change.ID, components.StateInProgress, "Updating PR...")
+
+		opts := m.prOptionsForChange(change, changesByID, m.trunkName, pr)
+		plan := planRevision(change, m.cfg.PushRemoteOrDefault(), opts, pr, m.reviewed[pr.GetNumber()])
+		return func() tea.Msg {
+			updated, err := m.applyPlan(pr.GetNumber(), plan)
 			return RevisionSyncedMsg{
 				ChangeID: change.ID,
 				PRNumber: pr.GetNumber(),
-				Created:  false,
+				Updated:  updated,
 				Err:      err,
 			}
 		}
+	}
 
-		// Create new PR
-		pr, err := m.gh.CreatePullRequest(m.ctx, m.repo, github.PullRequestOptions{
-			Title:  title,
-			Body:   body,
-			Branch: change.GitPushBookmark,
-			Base:   base,
-			Draft:  isDraft,
-		})
+	m.stack.SetRevisionState(change.ID, components.StateInProgress, "Creating PR...")
+
+	opts := m.prOptionsForChange(change, changesByID, m.trunkName, nil)
+	return func() tea.Msg {
+		pr, err := m.gh.CreatePullRequest(m.ctx, m.repo, opts)
 		if err != nil {
 			return RevisionSyncedMsg{ChangeID: change.ID, Err: err}
 		}
-		// Store for later use
-		m.existingPRs[change.GitPushBookmark] = pr
+
+		// Reviewers, assignees and labels can only be added once the PR exists
+		plan := planRevision(change, m.cfg.PushRemoteOrDefault(), opts, pr, nil)
+		plan.Changes = nil
+		_, err = m.applyPlan(pr.GetNumber(), plan)
 		return RevisionSyncedMsg{
 			ChangeID: change.ID,
 			PRNumber: pr.GetNumber(),
 			Created:  true,
+			PR:       pr,
+			Err:      err,
 		}
 	}
 }
 
+// applyPlan edits an existing PR to match the plan, reporting whether anything
+// was changed
+func (m Model) applyPlan(number int, plan RevisionPlan) (bool, error) {
+	updated := false
+	if len(plan.Changes) > 0 {
+		if err := m.gh.UpdatePullRequest(m.ctx, m.repo, number, plan.Options); err != nil {
+			return updated, err
+		}
+		updated = true
+	}
+	if len(plan.AddReviewers) > 0 {
+		if err := m.gh.RequestReviewers(m.ctx, m.repo, number, plan.AddReviewers); err != nil {
+			return updated, fmt.Errorf("request reviewers: %w", err)
+		}
+		updated = true
+	}
+	if len(plan.AddAssignees) > 0 {
+		if err := m.gh.AddAssignees(m.ctx, m.repo, number, plan.AddAssignees); err != nil {
+			return updated, fmt.Errorf("add assignees: %w", err)
+		}
+		updated = true
+	}
+	if len(plan.AddLabels) > 0 {
+		if err := m.gh.AddLabels(m.ctx, m.repo, number, plan.AddLabels); err != nil {
+			return updated, fmt.Errorf("add labels: %w", err)
+		}
+		updated = true
+	}
+	return updated, nil
+}
+
 func (m Model) updateAllCommentsCmd() tea.Cmd {
 	return func() tea.Msg {
+		// PRs opened while syncing are recorded for the next run
+		if err := m.prs.Save(); err != nil {
+			return AllCommentsUpdatedMsg{Err: fmt.Errorf("save pull request map: %w", err)}
+		}
+
 		// Fetch existing stack comments
 		var prNumbers []int
 		for _, pr := range m.existingPRs {
@@ -517,7 +883,7 @@ func (m Model) updateAllCommentsCmd() tea.Cmd {
 			m.ctx,
 			m.repo,
 			prNumbers,
-			"<!-- managed-by: jj-github -->",
+			m.cfg.CommentMarker,
 		)
 		if err != nil {
 			return AllCommentsUpdatedMsg{Err: err}
@@ -534,32 +900,7 @@ func (m Model) updateAllCommentsCmd() tea.Cmd {
 				continue
 			}
 
-			// Build the stack comment
-			builder := &strings.Builder{}
-			builder.WriteString("<!-- managed-by: jj-github -->\n")
-			builder.WriteString("**Pull Request Stack**\n\n")
-
-			// Show PRs in display order (current at top)
-			for _, r := range m.stack.Revisions {
-				if r.IsImmutable {
-					continue
-				}
-				prForRev, ok := m.existingPRs[r.Change.GitPushBookmark]
-				if !ok {
-					continue
-				}
-
-				suffix := ""
-				if pr.GetNumber() == prForRev.GetNumber() {
-					suffix = " ←"
-				}
-				fmt.Fprintf(builder, "- #%d%s\n", prForRev.GetNumber(), suffix)
-			}
-
-			builder.WriteString("\n---\n")
-			builder.WriteString("*Stack managed with [jj-github](https://github.com/cbrewster/jj-github)*")
-
-			commentBody := builder.String()
+			commentBody := m.stackCommentBody(pr.GetNumber())
 
 			// Check if comment already exists and matches
 			if existingComment, ok := stackComments[pr.GetNumber()]; ok {
@@ -593,13 +934,204 @@ func (m Model) updateAllCommentsCmd() tea.Cmd {
 	}
 }
 
+// updateAllStackSectionsCmd keeps the stack section of each PR body up to
+// date, leaving the rest of the body untouched
+func (m Model) updateAllStackSectionsCmd() tea.Cmd {
+	return func() tea.Msg {
+		// PRs opened while syncing are recorded for the next run
+		if err := m.prs.Save(); err != nil {
+			return AllCommentsUpdatedMsg{Err: fmt.Errorf("save pull request map: %w", err)}
+		}
+
+		for _, rev := range m.stack.Revisions {
+			if rev.IsImmutable {
+				continue
+			}
+
+			pr, ok := m.existingPRs[rev.Change.GitPushBookmark]
+			if !ok {
+				continue
+			}
+
+			// The body may have been edited while syncing, so read it again.
+			current, err := m.gh.GetPullRequest(m.ctx, m.repo, pr.GetNumber())
+			if err != nil {
+				return AllCommentsUpdatedMsg{Err: err}
+			}
+
+			body := prbody.Set(current.GetBody(), prbody.Stack, m.stackList(pr.GetNumber()))
+			if body == current.GetBody() {
+				continue
+			}
+
+			if err := m.gh.SetPullRequestBody(m.ctx, m.repo, pr.GetNumber(), body); err != nil {
+				return AllCommentsUpdatedMsg{Err: err}
+			}
+		}
+
+		return AllCommentsUpdatedMsg{}
+	}
+}
+
+// stackCommentBody builds the stack comment for the given PR
+func (m Model) stackCommentBody(prNumber int) string {
+	builder := &strings.Builder{}
+	builder.WriteString(m.cfg.CommentMarker + "\n")
+	builder.WriteString(m.stackList(prNumber))
+
+	if m.cfg.CommentFooter != "" {
+		builder.WriteString("\n---\n")
+		builder.WriteString(m.cfg.CommentFooter)
+	}
+
+	return builder.String()
+}
+
+// stackList lists the PRs of the stack, marking the given PR
+func (m Model) stackList(prNumber int) string {
+	builder := &strings.Builder{}
+	builder.WriteString("**Pull Request Stack**\n\n")
+	builder.WriteString(stackgraph.Markdown(m.stackPullRequests(prNumber), prNumber, m.trunkName))
+
+	if m.headOwner() != "" {
+		fmt.Fprintf(builder, "\nBranches live in a fork, so every PR targets `%s` and includes the commits of the PRs below it. Review only the top commit of each PR.\n", m.trunkName)
+	}
+
+	return builder.String()
+}
+
+// stackPullRequests lists the PRs related to the given PR for the stack graph,
+// children first: its ancestors and descendants, but not sibling branches.
+// Revisions without a PR are skipped, linking their children to their parents
+// instead. Revisions left alone are still listed with their merged or closed
+// PR.
+func (m Model) stackPullRequests(prNumber int) []stackgraph.PullRequest {
+	changesByID := make(map[string]*jj.Change)
+	for i := range m.changes {
+		changesByID[m.changes[i].ID] = &m.changes[i]
+	}
+
+	var parentsWithPRs func(change *jj.Change) []string
+	parentsWithPRs = func(change *jj.Change) []string {
+		var parents []string
+		for _, p := range change.Parents {
+			parent, ok := changesByID[p.ChangeID]
+			if !ok || parent.Immutable {
+				continue
+			}
+			if _, ok := m.stackPR(parent); ok {
+				parents = append(parents, parent.ID)
+			} else {
+				parents = append(parents, parentsWithPRs(parent)...)
+			}
+		}
+		return parents
+	}
+
+	var prs []stackgraph.PullRequest
+	var nodes []stackgraph.Node
+	current := ""
+	for _, rev := range components.NewStack(m.changes, m.trunkName).Revisions {
+		change := changesByID[rev.Change.ID]
+		if rev.IsImmutable || change == nil {
+			continue
+		}
+		pr, ok := m.stackPR(change)
+		if !ok {
+			continue
+		}
+		if pr.GetNumber() == prNumber {
+			current = change.ID
+		}
+
+		// The plan holds the title and draft state the PR was just synced to.
+		title, draft := pr.GetTitle(), pr.GetDraft()
+		if plan, ok := m.plans[change.ID]; ok {
+			title, draft = plan.Options.Title, plan.Options.Draft
+		}
+		state := stackgraph.StateOpen
+		switch {
+		case pr.MergedAt != nil:
+			state = stackgraph.StateMerged
+		case pr.GetState() == "closed":
+			state = stackgraph.StateClosed
+		case draft:
+			state = stackgraph.StateDraft
+		}
+
+		// The PR of a merge revision is based on one parent; name the others.
+		var alsoDependsOn []int
+		if parents := mutableParents(*change, changesByID); len(parents) > 1 {
+			base, _ := m.baseParent(*change, changesByID)
+			for _, parent := range parents {
+				if parent == base {
+					continue
+				}
+				ids := []string{parent.ID}
+				if _, ok := m.stackPR(parent); !ok {
+					ids = parentsWithPRs(parent)
+				}
+				for _, id := range ids {
+					pr, _ := m.stackPR(changesByID[id])
+					number := pr.GetNumber()
+					if !slices.Contains(alsoDependsOn, number) {
+						alsoDependsOn = append(alsoDependsOn, number)
+					}
+				}
+			}
+		}
+
+		node := stackgraph.Node{ID: change.ID, Parents: parentsWithPRs(change)}
+		nodes = append(nodes, node)
+		prs = append(prs, stackgraph.PullRequest{
+			Node:          node,
+			Number:        pr.GetNumber(),
+			Title:         title,
+			State:         state,
+			AlsoDependsOn: alsoDependsOn,
+		})
+	}
+
+	if current == "" {
+		return prs
+	}
+
+	lineage := stackgraph.Lineage(nodes, current)
+	prs = slices.DeleteFunc(prs, func(pr stackgraph.PullRequest) bool {
+		return !lineage[pr.ID]
+	})
+	for i := range prs {
+		// A merge of an unrelated branch doesn't draw that branch.
+		prs[i].Parents = slices.DeleteFunc(slices.Clone(prs[i].Parents), func(id string) bool {
+			return !lineage[id]
+		})
+	}
+	return prs
+}
+
+// stackPR returns the PR listed for a revision in stack comments: its open PR,
+// or the merged or closed PR of a revision left alone
+func (m Model) stackPR(change *jj.Change) (*gogithub.PullRequest, bool) {
+	if pr, ok := m.existingPRs[change.GitPushBookmark]; ok {
+		return pr, true
+	}
+	pr, ok := m.closedPRs[change.GitPushBookmark]
+	return pr, ok
+}
+
 // renderHelp renders the help view with custom styling for submit (magenta) and quit (muted)
 func renderHelp(keys KeyMap) string {
 	var b strings.Builder
 
-	// Render submit key in magenta
-	if keys.Submit.Enabled() {
-		renderKey(&b, keys.Submit, components.AccentStyle)
+	// Render submit key, or the choices for foreign commits, in magenta
+	for _, k := range []key.Binding{keys.Submit, keys.Import, keys.Overwrite, keys.Skip} {
+		if !k.Enabled() {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(components.MutedStyle.Render(helpSeparator))
+		}
+		renderKey(&b, k, components.AccentStyle)
 	}
 
 	// Render separator and quit key in muted
@@ -0,0 +1,805 @@
+package submit
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/prmap"
+	"github.com/cbrewster/jj-github/internal/report"
+	tea "github.com/charmbracelet/bubbletea"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectLoad scripts the jj invocations made while loading the stack for revset.
+func expectLoad(r *jjtest.Runner, revset, output string) {
+	expectLoadWithTemplates(r, revset, output, nil)
+}
+
+// expectPush scripts a single push of the given changes.
+func expectPush(r *jjtest.Runner, changeIDs ...string) {
+	args := []string{"git", "push", "--remote", "origin"}
+	for _, id := range changeIDs {
+		args = append(args, "-c", "change_id("+id+")")
+	}
+	r.Expect("", args...)
+}
+
+// expectForeignLookup scripts the lookup of the commits on a PR branch at
+// remoteSHA that aren't part of the local commit.
+func expectForeignLookup(r *jjtest.Runner, remoteSHA, commitID, output string) {
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(output, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark),
+		"-r", fmt.Sprintf("::%s ~ ::(%s | trunk())", remoteSHA, commitID))
+}
+
+// expectLoadWithTemplates is expectLoad for a trunk holding the given pull
+// request template files, keyed by path.
+func expectLoadWithTemplates(r *jjtest.Runner, revset, output string, templates map[string]string) {
+	r.Expect("", "git", "fetch")
+	expectLoadNoFetch(r, revset, output, templates)
+}
+
+// expectLoadNoFetch is expectLoadWithTemplates for a load that doesn't fetch
+// first.
+func expectLoadNoFetch(r *jjtest.Runner, revset, output string, templates map[string]string) {
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(output, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark),
+		"-r", fmt.Sprintf("(roots(::(%s) & mutable())- | ::(%s) & mutable()) & ~empty()", revset, revset))
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.Trunk, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", "trunk()")
+
+	var list strings.Builder
+	for _, path := range slices.Sorted(maps.Keys(templates)) {
+		list.WriteString(path + "\n")
+		r.Expect(templates[path], "file", "show", "-r", "trunk()", "--", jj.FilePattern(path))
+	}
+	r.Expect(list.String(), append([]string{"file", "list", "-r", "trunk()", "--"}, templateFilesets...)...)
+}
+
+// runSubmit runs submit headlessly with jj scripted by runner, or the jj
+// binary if it is nil, against server. The repo, revset and config default to
+// githubtest.DefaultRepo, "@" and config.Default(). Returns the progress output.
+func runSubmit(t *testing.T, runner jj.Runner, server *githubtest.Server, opts Options) (Model, string, error) {
+	t.Helper()
+
+	client := jj.NewClient()
+	if runner != nil {
+		client = jj.NewClientWithRunner(runner)
+	}
+	if opts.Repo == (github.Repo{}) {
+		opts.Repo = githubtest.DefaultRepo
+	}
+	if opts.Revset == "" {
+		opts.Revset = "@"
+	}
+	if reflect.ValueOf(opts.Config).IsZero() {
+		opts.Config = config.Default()
+	}
+
+	var out strings.Builder
+	m, err := RunHeadless(NewModel(t.Context(), client, server.Client(t), opts), &out)
+	return m, out.String(), err
+}
+
+func TestRunHeadlessCreatesStack(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-bbbb", "c2")
+
+	stack := jjtest.Trunk +
+		jjtest.Change("aaaa", "c1", "Add auth\n\nAuth body\n", "zzzz", "c0") +
+		jjtest.Change("bbbb", "c2", "WIP: add login form\n", "aaaa", "c1")
+
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+	expectPush(r, "aaaa", "bbbb")
+
+	m, _, err := runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+	assert.Empty(t, r.Unused())
+
+	prs := server.PullRequests(githubtest.DefaultRepo)
+	require.Len(t, prs, 2)
+
+	assert.Equal(t, "Add auth", prs[0].GetTitle())
+	assert.Equal(t, "\nAuth body\n\n<!-- jj-github:change-id aaaa -->", prs[0].GetBody())
+	assert.Equal(t, "push-aaaa", prs[0].GetHead().GetRef())
+	assert.Equal(t, "main", prs[0].GetBase().GetRef())
+	assert.False(t, prs[0].GetDraft())
+
+	assert.Equal(t, "WIP: add login form", prs[1].GetTitle())
+	assert.Equal(t, "push-aaaa", prs[1].GetBase().GetRef())
+	assert.True(t, prs[1].GetDraft())
+
+	for _, pr := range prs {
+		comments := server.Comments(githubtest.DefaultRepo, pr.GetNumber())
+		require.Len(t, comments, 1)
+		assert.Contains(t, comments[0].GetBody(), config.Default().CommentMarker)
+		assert.Contains(t, comments[0].GetBody(), fmt.Sprintf("**#%d %s** ←", pr.GetNumber(), pr.GetTitle()))
+	}
+
+	// A second run finds everything in sync and makes no changes.
+	r = jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+
+	m, _, err = runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, PhaseUpToDate, m.phase)
+	assert.Empty(t, r.Unused())
+}
+
+func TestRunHeadlessUpdatesExistingPR(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+
+	stack := jjtest.Trunk + jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0")
+
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+	expectPush(r, "aaaa")
+
+	_, _, err := runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+
+	// Amend the description locally and resubmit. The branch already points
+	// at the commit, so it isn't pushed again.
+	stack = jjtest.Trunk + jjtest.Change("aaaa", "c1", "Add authentication\n", "zzzz", "c0")
+	r = jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+
+	m, out, err := runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+	assert.Empty(t, r.Unused())
+	assert.Contains(t, out, "a: PR #1: metadata updated\n")
+
+	prs := server.PullRequests(githubtest.DefaultRepo)
+	require.Len(t, prs, 1)
+	assert.Equal(t, "Add authentication", prs[0].GetTitle())
+	assert.Len(t, server.Comments(githubtest.DefaultRepo, prs[0].GetNumber()), 1, "stack comment is edited, not duplicated")
+
+	// A new commit with the same description is only pushed. The old commit
+	// on the branch is an earlier version of the change, so it isn't foreign.
+	stack = jjtest.Trunk + jjtest.Change("aaaa", "c5", "Add authentication\n", "zzzz", "c0")
+	r = jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+	expectForeignLookup(r, "c1", "c5", jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0"))
+	expectPush(r, "aaaa")
+
+	_, out, err = runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.Contains(t, out, "a: PR #1: pushed\n")
+}
+
+func TestRunHeadlessSkipsPushedBranch(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+
+	// The branch was pushed before, but opening the PR failed.
+	stack := jjtest.Trunk + strings.Replace(jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0"),
+		`"bookmarks": []`, `"bookmarks": [], "remote_bookmarks": [{"name": "push-aaaa", "remote": "origin"}]`, 1)
+
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+
+	_, out, err := runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.Contains(t, out, "a: PR #1: created\n")
+	assert.Len(t, server.PullRequests(githubtest.DefaultRepo), 1)
+}
+
+func TestRunHeadlessRenamedBookmark(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+
+	prs, err := prmap.Load(prmap.Path(t.TempDir()))
+	require.NoError(t, err)
+	opts := Options{Repo: githubtest.DefaultRepo, Revset: "@", Config: config.Default(), PRs: prs}
+
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", jjtest.Trunk+jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0"))
+	expectPush(r, "aaaa")
+
+	_, _, err = runSubmit(t, r, server, opts)
+	require.NoError(t, err)
+	number, ok := prs.Get(githubtest.DefaultRepo, "aaaa")
+	require.True(t, ok)
+	assert.Equal(t, 1, number)
+
+	// templates.git_push_bookmark changed and the change was amended. The PR
+	// is found by change ID and its branch is pushed instead of a new one.
+	stack := jjtest.Trunk + strings.Replace(jjtest.Change("aaaa", "c2", "Add auth\n", "zzzz", "c0"),
+		`"git_push_bookmark": "push-aaaa"`, `"git_push_bookmark": "jj/aaaa"`, 1)
+	r = jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+	expectForeignLookup(r, "c1", "c2", jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0"))
+	r.Expect("", "bookmark", "set", "push-aaaa", "-r", "change_id(aaaa)", "--allow-backwards")
+	r.Expect("", "git", "push", "--remote", "origin", "-b", "push-aaaa")
+
+	_, out, err := runSubmit(t, r, server, opts)
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.Contains(t, out, "a: pushed push-aaaa\n")
+	assert.Contains(t, out, "a: PR #1: pushed\n")
+	assert.Len(t, server.PullRequests(githubtest.DefaultRepo), 1, "no duplicate PR is opened")
+
+	// A PR that is no longer open is forgotten.
+	server.MergePullRequest(githubtest.DefaultRepo, 1)
+	server.SetBranch(githubtest.DefaultRepo, "jj/aaaa", "c2")
+	r = jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+	expectPush(r, "aaaa")
+
+	_, _, err = runSubmit(t, r, server, opts)
+	require.NoError(t, err)
+	number, _ = prs.Get(githubtest.DefaultRepo, "aaaa")
+	assert.Equal(t, 2, number)
+}
+
+func TestRunDryRunLeavesPRMap(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+	server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Title: gogithub.Ptr("Add auth"),
+		Head:  &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-aaaa")},
+		Base:  &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+
+	path := prmap.Path(t.TempDir())
+	prs, err := prmap.Load(path)
+	require.NoError(t, err)
+
+	// Nothing is fetched either
+	r := jjtest.NewRunner()
+	expectLoadNoFetch(r, "@", jjtest.Trunk+jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0"), nil)
+
+	err = RunDryRun(NewModel(t.Context(), jj.NewClientWithRunner(r), server.Client(t), Options{Repo: githubtest.DefaultRepo, Revset: "@", Config: config.Default(), PRs: prs}), io.Discard)
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.NoFileExists(t, path)
+	_, ok := prs.Get(githubtest.DefaultRepo, "aaaa")
+	assert.False(t, ok, "the PR found is not recorded")
+}
+
+func TestRunHeadlessFork(t *testing.T) {
+	fork := github.Repo{Owner: "me", Name: "repo"}
+	forkConfig := config.Default()
+	forkConfig.PRRemote = "upstream"
+
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(fork, "push-aaaa", "c1")
+	server.SetBranch(fork, "push-bbbb", "c2")
+
+	stack := jjtest.Trunk +
+		jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0") +
+		jjtest.Change("bbbb", "c2", "Add login form\n", "aaaa", "c1")
+
+	r := jjtest.NewRunner()
+	r.Expect("", "git", "fetch", "--remote", "origin", "--remote", "upstream")
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(stack, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark),
+		"-r", "(roots(::(@) & mutable())- | ::(@) & mutable()) & ~empty()")
+	r.Expect(jjtest.PushBookmark+"\n", "config", "get", "templates.git_push_bookmark")
+	r.Expect(jjtest.Trunk, "log", "--no-graph", "--reversed", "-T", jj.LogTemplate(jjtest.PushBookmark), "-r", "trunk()")
+	r.Expect("", append([]string{"file", "list", "-r", "trunk()", "--"}, templateFilesets...)...)
+	expectPush(r, "aaaa", "bbbb")
+
+	m, _, err := runSubmit(t, r, server, Options{HeadRepo: fork, Config: forkConfig})
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+	assert.Empty(t, r.Unused())
+
+	prs := server.PullRequests(githubtest.DefaultRepo)
+	require.Len(t, prs, 2)
+	for _, pr := range prs {
+		assert.Equal(t, "main", pr.GetBase().GetRef(), "intermediate bases only exist in the fork")
+		assert.Equal(t, "me", pr.GetHead().GetRepo().GetOwner().GetLogin())
+
+		comments := server.Comments(githubtest.DefaultRepo, pr.GetNumber())
+		require.Len(t, comments, 1)
+		assert.Contains(t, comments[0].GetBody(), "Branches live in a fork")
+	}
+}
+
+func TestRunHeadlessReviewersAndAssignees(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+
+	cfg := config.Default()
+	cfg.Reviewers = []string{"@carol", githubtest.DefaultUser} // the author is skipped
+	cfg.Assignees = []string{"@bob"}                           // the same user as the trailer
+
+	description := "Add auth\n\nDetails\n\nReviewers: @alice, @owner/core\nAssignees: @bob\n"
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", jjtest.Trunk+jjtest.Change("aaaa", "c1", description, "zzzz", "c0"))
+	expectPush(r, "aaaa")
+
+	_, _, err := runSubmit(t, r, server, Options{Config: cfg})
+	require.NoError(t, err)
+
+	pr := server.PullRequests(githubtest.DefaultRepo)[0]
+	assert.Equal(t, "\nDetails\n\n<!-- jj-github:change-id aaaa -->", pr.GetBody(), "recognized trailers are removed from the body")
+	assert.Equal(t, []string{"carol", "alice"}, logins(pr.RequestedReviewers))
+	require.Len(t, pr.RequestedTeams, 1)
+	assert.Equal(t, "core", pr.RequestedTeams[0].GetSlug())
+	assert.Equal(t, []string{"bob"}, logins(pr.Assignees))
+
+	// Alice reviews, which removes her request. Adding dave must not ask
+	// alice or the team again.
+	server.AddReview(githubtest.DefaultRepo, pr.GetNumber(), "alice", "APPROVED")
+
+	description = "Add auth\n\nDetails\n\nReviewers: @alice, @owner/core, @dave\nAssignees: @bob\n"
+	r = jjtest.NewRunner()
+	expectLoad(r, "@", jjtest.Trunk+jjtest.Change("aaaa", "c1", description, "zzzz", "c0"))
+
+	m, _, err := runSubmit(t, r, server, Options{Config: cfg})
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+
+	pr = server.PullRequests(githubtest.DefaultRepo)[0]
+	assert.Equal(t, []string{"carol", "dave"}, logins(pr.RequestedReviewers))
+	assert.Len(t, pr.RequestedTeams, 1)
+	assert.Equal(t, []string{"bob"}, logins(pr.Assignees))
+}
+
+func TestRunHeadlessLabels(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+
+	cfg := config.Default()
+	cfg.Labels = []string{"stacked"}
+
+	description := "Add auth\n\nLabels: backend, db\n"
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", jjtest.Trunk+jjtest.Change("aaaa", "c1", description, "zzzz", "c0"))
+	expectPush(r, "aaaa")
+
+	_, _, err := runSubmit(t, r, server, Options{Config: cfg})
+	require.NoError(t, err)
+
+	pr := server.PullRequests(githubtest.DefaultRepo)[0]
+	assert.Equal(t, "<!-- jj-github:change-id aaaa -->", pr.GetBody(), "label trailers are removed from the body")
+	assert.Equal(t, []string{"stacked", "backend", "db"}, labelNames(pr.Labels))
+
+	// A label added on GitHub is kept; a new trailer label is added.
+	server.AddLabel(githubtest.DefaultRepo, pr.GetNumber(), "needs-qa")
+
+	description = "Add auth\n\nLabels: backend, db, api\n"
+	r = jjtest.NewRunner()
+	expectLoad(r, "@", jjtest.Trunk+jjtest.Change("aaaa", "c1", description, "zzzz", "c0"))
+
+	_, _, err = runSubmit(t, r, server, Options{Config: cfg})
+	require.NoError(t, err)
+
+	pr = server.PullRequests(githubtest.DefaultRepo)[0]
+	assert.Equal(t, []string{"stacked", "backend", "db", "needs-qa", "api"}, labelNames(pr.Labels))
+}
+
+func TestRunHeadlessTemplate(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+
+	templates := map[string]string{
+		".github/pull_request_template.md": "## Checklist\n\n- [ ] Tests added\n",
+	}
+	stack := jjtest.Trunk + jjtest.Change("aaaa", "c1", "Add auth\n\nAuth body\n", "zzzz", "c0")
+
+	r := jjtest.NewRunner()
+	expectLoadWithTemplates(r, "@", stack, templates)
+	expectPush(r, "aaaa")
+
+	_, _, err := runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+
+	pr := server.PullRequests(githubtest.DefaultRepo)[0]
+	assert.Equal(t, "<!-- jj-github:description -->\nAuth body\n<!-- /jj-github:description -->\n\n## Checklist\n\n- [ ] Tests added\n\n<!-- jj-github:change-id aaaa -->", pr.GetBody())
+
+	// Filling in the template on GitHub doesn't make the PR out of sync.
+	filled := strings.Replace(pr.GetBody(), "- [ ]", "- [x]", 1)
+	server.SetPullRequestBody(githubtest.DefaultRepo, pr.GetNumber(), filled)
+
+	r = jjtest.NewRunner()
+	expectLoadWithTemplates(r, "@", stack, templates)
+
+	m, _, err := runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, PhaseUpToDate, m.phase)
+
+	// A new description only replaces the description section.
+	stack = jjtest.Trunk + jjtest.Change("aaaa", "c1", "Add auth\n\nNew auth body\n", "zzzz", "c0")
+	r = jjtest.NewRunner()
+	expectLoadWithTemplates(r, "@", stack, templates)
+
+	_, _, err = runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+
+	pr = server.PullRequests(githubtest.DefaultRepo)[0]
+	assert.Equal(t, "<!-- jj-github:description -->\nNew auth body\n<!-- /jj-github:description -->\n\n## Checklist\n\n- [x] Tests added\n\n<!-- jj-github:change-id aaaa -->", pr.GetBody())
+
+	// PRs opened without the template don't get it later.
+	server.SetBranch(githubtest.DefaultRepo, "push-bbbb", "c2")
+	old := server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Title: gogithub.Ptr("Fix login"),
+		Body:  gogithub.Ptr("Old body"),
+		Head:  &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-bbbb")},
+		Base:  &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+	r = jjtest.NewRunner()
+	expectLoadWithTemplates(r, "@", jjtest.Trunk+jjtest.Change("bbbb", "c2", "Fix login\n\nLogin body\n", "zzzz", "c0"), templates)
+
+	_, _, err = runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+
+	pr = server.PullRequests(githubtest.DefaultRepo)[old.GetNumber()-1]
+	assert.Equal(t, "\nLogin body\n\n<!-- jj-github:change-id bbbb -->", pr.GetBody())
+}
+
+func TestRunHeadlessStackInBody(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-bbbb", "c2")
+
+	cfg := config.Default()
+	cfg.StackLocation = config.StackInBody
+
+	stack := jjtest.Trunk +
+		jjtest.Change("aaaa", "c1", "Add auth\n\nAuth body\n", "zzzz", "c0") +
+		jjtest.Change("bbbb", "c2", "Add login form\n", "aaaa", "c1")
+
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+	expectPush(r, "aaaa", "bbbb")
+
+	_, _, err := runSubmit(t, r, server, Options{Config: cfg})
+	require.NoError(t, err)
+
+	prs := server.PullRequests(githubtest.DefaultRepo)
+	require.Len(t, prs, 2)
+	assert.Equal(t, "<!-- jj-github:description -->\nAuth body\n<!-- /jj-github:description -->\n\n"+
+		"<!-- jj-github:change-id aaaa -->\n\n"+
+		"<!-- jj-github:stack -->\n**Pull Request Stack**\n\n`○` 🟢 #2 Add login form\\\n`●` 🟢 **#1 Add auth** ←\\\n`◆` `main`\n<!-- /jj-github:stack -->", prs[0].GetBody())
+	assert.Equal(t, "<!-- jj-github:description -->\n\n<!-- /jj-github:description -->\n\n"+
+		"<!-- jj-github:change-id bbbb -->\n\n"+
+		"<!-- jj-github:stack -->\n**Pull Request Stack**\n\n`●` 🟢 **#2 Add login form** ←\\\n`○` 🟢 #1 Add auth\\\n`◆` `main`\n<!-- /jj-github:stack -->", prs[1].GetBody())
+	for _, pr := range prs {
+		assert.Empty(t, server.Comments(githubtest.DefaultRepo, pr.GetNumber()))
+	}
+
+	// The stack section doesn't count as a body change, and edits outside it
+	// survive later stack updates.
+	server.SetPullRequestBody(githubtest.DefaultRepo, prs[1].GetNumber(), "Reviewer notes\n\n"+prs[1].GetBody())
+
+	r = jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+
+	m, _, err := runSubmit(t, r, server, Options{Config: cfg})
+	require.NoError(t, err)
+	assert.Equal(t, PhaseUpToDate, m.phase)
+
+	server.SetBranch(githubtest.DefaultRepo, "push-cccc", "c3")
+	stack += jjtest.Change("cccc", "c3", "Add logout\n", "bbbb", "c2")
+	r = jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+	expectPush(r, "cccc")
+
+	_, _, err = runSubmit(t, r, server, Options{Config: cfg})
+	require.NoError(t, err)
+
+	prs = server.PullRequests(githubtest.DefaultRepo)
+	require.Len(t, prs, 3)
+	assert.Equal(t, "Reviewer notes\n\n<!-- jj-github:description -->\n\n<!-- /jj-github:description -->\n\n"+
+		"<!-- jj-github:change-id bbbb -->\n\n"+
+		"<!-- jj-github:stack -->\n**Pull Request Stack**\n\n`○` 🟢 #3 Add logout\\\n`●` 🟢 **#2 Add login form** ←\\\n`○` 🟢 #1 Add auth\\\n`◆` `main`\n<!-- /jj-github:stack -->", prs[1].GetBody())
+}
+
+func TestRunHeadlessBranches(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-bbbb", "c2")
+	server.SetBranch(githubtest.DefaultRepo, "push-cccc", "c3")
+	server.SetBranch(githubtest.DefaultRepo, "push-dddd", "c4")
+
+	// aaaa has two branches: bbbb-dddd and cccc.
+	stack := jjtest.Trunk +
+		jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0") +
+		jjtest.Change("bbbb", "c2", "Add login\n", "aaaa", "c1") +
+		jjtest.Change("cccc", "c3", "Add signup\n", "aaaa", "c1") +
+		jjtest.Change("dddd", "c4", "Add logout\n", "bbbb", "c2")
+
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+	expectPush(r, "aaaa", "bbbb", "dddd", "cccc")
+
+	_, _, err := runSubmit(t, r, server, Options{})
+	require.NoError(t, err)
+
+	assert.Empty(t, r.Unused(), "all branches are pushed in one go, each one after its parents")
+
+	prs := make(map[string]*gogithub.PullRequest)
+	for _, pr := range server.PullRequests(githubtest.DefaultRepo) {
+		prs[pr.GetHead().GetRef()] = pr
+	}
+	assert.Equal(t, "push-aaaa", prs["push-cccc"].GetBase().GetRef())
+	assert.Equal(t, "push-aaaa", prs["push-bbbb"].GetBase().GetRef())
+
+	// PRs are opened concurrently, but never before the PR they are based on.
+	assert.Less(t, prs["push-aaaa"].GetNumber(), prs["push-bbbb"].GetNumber())
+	assert.Less(t, prs["push-aaaa"].GetNumber(), prs["push-cccc"].GetNumber())
+	assert.Less(t, prs["push-bbbb"].GetNumber(), prs["push-dddd"].GetNumber())
+
+	comment := func(branch string) string {
+		comments := server.Comments(githubtest.DefaultRepo, prs[branch].GetNumber())
+		require.Len(t, comments, 1)
+		return comments[0].GetBody()
+	}
+
+	// Sibling branches are left out of each other's stack comments.
+	assert.Contains(t, comment("push-bbbb"), fmt.Sprintf("#%d Add logout", prs["push-dddd"].GetNumber()))
+	assert.NotContains(t, comment("push-bbbb"), "Add signup")
+	assert.NotContains(t, comment("push-cccc"), "Add login")
+	assert.Contains(t, comment("push-cccc"), "Add auth")
+
+	// The common ancestor shows the whole tree.
+	assert.Contains(t, comment("push-aaaa"), "`├─╯`")
+	for _, title := range []string{"Add login", "Add signup", "Add logout"} {
+		assert.Contains(t, comment("push-aaaa"), title)
+	}
+}
+
+// mergeStack has cccc merging the independent revisions aaaa and bbbb.
+var mergeStack = jjtest.Trunk +
+	jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0") +
+	jjtest.Change("bbbb", "c2", "Add login\n", "zzzz", "c0") +
+	strings.Replace(jjtest.Change("cccc", "c3", "Use both\n", "aaaa", "c1"),
+		`]}`, `, {"change_id": "bbbb", "commit_id": "c2"}]}`, 1)
+
+func TestRunHeadlessMergeRefused(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", mergeStack)
+
+	_, _, err := runSubmit(t, r, server, Options{})
+	require.ErrorContains(t, err, "revision c merges a and b")
+	assert.ErrorContains(t, err, "jj-github.merge-parent")
+	assert.Empty(t, server.PullRequests(githubtest.DefaultRepo))
+}
+
+func TestRunHeadlessMergeOpenPR(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c1")
+	server.SetBranch(githubtest.DefaultRepo, "push-bbbb", "c2")
+	server.SetBranch(githubtest.DefaultRepo, "push-cccc", "c3")
+	server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Title: gogithub.Ptr("Add login"),
+		Head:  &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-bbbb")},
+		Base:  &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", mergeStack)
+	expectPush(r, "aaaa", "cccc")
+
+	cfg := config.Default()
+	cfg.MergeParent = config.MergeParentOpenPR
+	_, _, err := runSubmit(t, r, server, Options{Config: cfg})
+	require.NoError(t, err)
+
+	prs := make(map[string]*gogithub.PullRequest)
+	for _, pr := range server.PullRequests(githubtest.DefaultRepo) {
+		prs[pr.GetHead().GetRef()] = pr
+	}
+	require.Len(t, prs, 3)
+	assert.Equal(t, "push-bbbb", prs["push-cccc"].GetBase().GetRef(),
+		"the parent with an open PR is the base, even though it isn't first")
+
+	comments := server.Comments(githubtest.DefaultRepo, prs["push-cccc"].GetNumber())
+	require.Len(t, comments, 1)
+	assert.Contains(t, comments[0].GetBody(),
+		fmt.Sprintf("**#%d Use both** ← (also depends on #%d)", prs["push-cccc"].GetNumber(), prs["push-aaaa"].GetNumber()))
+}
+
+func TestRunHeadlessExistingOnly(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-bbbb", "c2")
+	bottom := server.OpenPullRequest(githubtest.DefaultRepo, "push-aaaa", "main", "c1", &gogithub.PullRequest{Title: gogithub.Ptr("Add auth")})
+	top := server.OpenPullRequest(githubtest.DefaultRepo, "push-cccc", "push-bbbb", "c3", &gogithub.PullRequest{Title: gogithub.Ptr("Add login")})
+
+	// The middle revision has no PR, so it's neither pushed nor opened.
+	stack := jjtest.Trunk +
+		jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0") +
+		jjtest.Change("bbbb", "c2", "Add session\n", "aaaa", "c1") +
+		jjtest.Change("cccc", "c3", "Add login form\n", "bbbb", "c2")
+
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+
+	_, out, err := runSubmit(t, r, server, Options{ExistingOnly: true})
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.NotContains(t, out, "b: ")
+
+	prs := server.PullRequests(githubtest.DefaultRepo)
+	require.Len(t, prs, 2)
+	assert.Equal(t, "Add login form", prs[1].GetTitle())
+	assert.Equal(t, "push-bbbb", prs[1].GetBase().GetRef(), "the base of the top PR is left alone")
+
+	comments := server.Comments(githubtest.DefaultRepo, top.GetNumber())
+	require.Len(t, comments, 1)
+	assert.Contains(t, comments[0].GetBody(), fmt.Sprintf("#%d Add auth", bottom.GetNumber()))
+}
+
+func TestRunHeadlessExistingOnlyListsMergedPR(t *testing.T) {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	merged := server.OpenPullRequest(githubtest.DefaultRepo, "push-aaaa", "main", "c1", &gogithub.PullRequest{Title: gogithub.Ptr("Add auth")})
+	server.MergePullRequest(githubtest.DefaultRepo, merged.GetNumber())
+	top := server.OpenPullRequest(githubtest.DefaultRepo, "push-bbbb", "push-aaaa", "c2", &gogithub.PullRequest{Title: gogithub.Ptr("Add login")})
+
+	// The revision of the merged PR hasn't been abandoned yet
+	stack := jjtest.Trunk +
+		jjtest.Change("aaaa", "c1", "Add auth\n", "zzzz", "c0") +
+		jjtest.Change("bbbb", "c2", "Add login form\n", "aaaa", "c1")
+
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", stack)
+
+	_, _, err := runSubmit(t, r, server, Options{ExistingOnly: true})
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.Len(t, server.PullRequests(githubtest.DefaultRepo), 2, "no PR is opened for the merged revision")
+
+	comments := server.Comments(githubtest.DefaultRepo, top.GetNumber())
+	require.Len(t, comments, 1)
+	assert.Contains(t, comments[0].GetBody(), fmt.Sprintf("**#%d Add login form** ←\\\n`○` 🟣 #%d Add auth\\\n", top.GetNumber(), merged.GetNumber()))
+	assert.Empty(t, server.Comments(githubtest.DefaultRepo, merged.GetNumber()), "merged PRs aren't commented on")
+}
+
+// foreignCommits is the jj log output for a suggestion committed on GitHub on
+// top of the pushed c1, while the revision was amended locally.
+const foreignCommits = `{"id": "ffff", "short_id": "f", "commit_id": "c9", "immutable": false, "description": "Apply suggestion\n", "bookmarks": [], "git_push_bookmark": "push-ffff", "parents": [{"change_id": "aaaa", "commit_id": "c1"}]}` +
+	`{"id": "aaaa", "short_id": "a", "commit_id": "c1", "immutable": false, "description": "Add auth\n", "bookmarks": [], "git_push_bookmark": "push-aaaa", "parents": [{"change_id": "zzzz", "commit_id": "c0"}]}`
+
+// foreignServer returns a server where the PR of aaaa has a foreign commit
+func foreignServer(t *testing.T) *githubtest.Server {
+	server := githubtest.NewServer(t)
+	server.SetBranch(githubtest.DefaultRepo, "main", "c0")
+	server.SetBranch(githubtest.DefaultRepo, "push-aaaa", "c9")
+	server.AddPullRequest(githubtest.DefaultRepo, &gogithub.PullRequest{
+		Title: gogithub.Ptr("Add auth"),
+		Body:  gogithub.Ptr(""),
+		Head:  &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-aaaa")},
+		Base:  &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+	})
+	return server
+}
+
+func TestRunHeadlessForeignCommits(t *testing.T) {
+	stack := jjtest.Trunk + jjtest.Change("aaaa", "c2", "Add auth\n", "zzzz", "c0")
+
+	run := func(t *testing.T, server *githubtest.Server, r *jjtest.Runner, policy config.ForeignCommitPolicy) (Model, string, error) {
+		cfg := config.Default()
+		cfg.ForeignCommits = policy
+		return runSubmit(t, r, server, Options{Config: cfg})
+	}
+
+	t.Run("ask", func(t *testing.T) {
+		r := jjtest.NewRunner()
+		expectLoad(r, "@", stack)
+		expectForeignLookup(r, "c9", "c2", foreignCommits)
+
+		_, _, err := run(t, foreignServer(t), r, config.ForeignAsk)
+		require.ErrorContains(t, err, "push-aaaa has 1 commit(s) on GitHub that weren't pushed from jj")
+		assert.Empty(t, r.Unused())
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		r := jjtest.NewRunner()
+		expectLoad(r, "@", stack)
+		expectForeignLookup(r, "c9", "c2", foreignCommits)
+
+		m, out, err := run(t, foreignServer(t), r, config.ForeignSkip)
+		require.NoError(t, err)
+		assert.Empty(t, r.Unused(), "nothing is pushed")
+		assert.Contains(t, out, "a: PR #1: skipped")
+		require.Len(t, m.Report().Revisions, 1)
+		assert.Equal(t, report.ActionSkippedForeign, m.Report().Revisions[0].Action)
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		r := jjtest.NewRunner()
+		expectLoad(r, "@", stack)
+		expectForeignLookup(r, "c9", "c2", foreignCommits)
+		r.Expect("", "bookmark", "set", "push-aaaa", "-r", "change_id(aaaa)", "--allow-backwards")
+		expectPush(r, "aaaa")
+
+		_, out, err := run(t, foreignServer(t), r, config.ForeignOverwrite)
+		require.NoError(t, err)
+		assert.Empty(t, r.Unused())
+		assert.Contains(t, out, "a: PR #1: pushed")
+	})
+
+	t.Run("import", func(t *testing.T) {
+		r := jjtest.NewRunner()
+		expectLoad(r, "@", stack)
+		expectForeignLookup(r, "c9", "c2", foreignCommits)
+		r.Expect("", "squash", "--from", "c9", "--into", "change_id(aaaa)", "--use-destination-message")
+
+		// The stack is loaded again with the suggestion squashed in.
+		imported := jjtest.Trunk + jjtest.Change("aaaa", "c3", "Add auth\n", "zzzz", "c0")
+		expectLoad(r, "@", imported)
+		expectForeignLookup(r, "c9", "c3", foreignCommits)
+		r.Expect("", "bookmark", "set", "push-aaaa", "-r", "change_id(aaaa)", "--allow-backwards")
+		expectPush(r, "aaaa")
+
+		_, _, err := run(t, foreignServer(t), r, config.ForeignImport)
+		require.NoError(t, err)
+		assert.Empty(t, r.Unused())
+	})
+}
+
+func TestForeignCommitKeys(t *testing.T) {
+	r := jjtest.NewRunner()
+	expectLoad(r, "@", jjtest.Trunk+jjtest.Change("aaaa", "c2", "Add auth\n", "zzzz", "c0"))
+	expectForeignLookup(r, "c9", "c2", foreignCommits)
+
+	m := NewModel(t.Context(), jj.NewClientWithRunner(r), foreignServer(t).Client(t), Options{Repo: githubtest.DefaultRepo, Revset: "@", Config: config.Default()})
+	next, _ := m.Update(m.loadRevisionsAndPRsCmd()())
+	m = next.(Model)
+	assert.Contains(t, m.View(), "c9 Apply suggestion")
+	assert.Contains(t, m.View(), "o overwrite")
+
+	// Submitting waits for a decision.
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Nil(t, cmd)
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	m = next.(Model)
+	assert.Equal(t, config.ForeignSkip, m.resolutions["aaaa"])
+	assert.Contains(t, m.View(), "enter submit")
+}
+
+func labelNames(labels []*gogithub.Label) []string {
+	var result []string
+	for _, l := range labels {
+		result = append(result, l.GetName())
+	}
+	return result
+}
+
+func logins(users []*gogithub.User) []string {
+	var result []string
+	for _, u := range users {
+		result = append(result, u.GetLogin())
+	}
+	return result
+}
@@ -0,0 +1,50 @@
+package submit
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/cbrewster/jj-github/internal/jj"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// findPullRequests looks up the open PRs of the mutable changes, keyed by
+// branch. PRs recorded for a change are found by number first, so renaming
+// its bookmark, e.g. by changing templates.git_push_bookmark, doesn't orphan
+// them. GitHub can't move a PR to another branch, so such a change takes over
+// the branch of its PR instead: its GitPushBookmark is replaced, and it is
+// listed in the returned set of adopted change IDs. Every PR found is recorded
+// for the next run.
+func (m Model) findPullRequests(changes []jj.Change) (map[string]*gogithub.PullRequest, map[string]bool, error) {
+	submitted := slices.DeleteFunc(slices.Clone(changes), func(c jj.Change) bool { return !isSubmitted(c) })
+	found, err := m.prs.Find(m.ctx, m.gh, m.repo, m.headOwner(), submitted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existingPRs := make(map[string]*gogithub.PullRequest)
+	adopted := make(map[string]bool)
+	for i := range changes {
+		change := &changes[i]
+		pr, ok := found[change.ID]
+		if !ok {
+			continue
+		}
+		if ref := pr.GetHead().GetRef(); ref != change.GitPushBookmark {
+			change.GitPushBookmark = ref
+			adopted[change.ID] = true
+		}
+		existingPRs[change.GitPushBookmark] = pr
+	}
+
+	if err := m.prs.Save(); err != nil {
+		return nil, nil, fmt.Errorf("save pull request map: %w", err)
+	}
+
+	return existingPRs, adopted, nil
+}
+
+// isSubmitted reports whether submit opens a PR for change
+func isSubmitted(change jj.Change) bool {
+	return !change.Immutable && change.Description != ""
+}
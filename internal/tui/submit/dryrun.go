@@ -0,0 +1,214 @@
+package submit
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/prbody"
+)
+
+// RunDryRun loads the stack and writes the mutations submit would perform to w.
+// It never fetches, pushes, creates or edits pull requests, or touches comments
+// or the pull request map.
+func RunDryRun(m Model, w io.Writer) error {
+	m.prs = m.prs.ReadOnly()
+	m.noFetch = true
+	next, _ := m.Update(m.loadRevisionsAndPRsCmd()())
+	m = next.(Model)
+	if m.phase == PhaseError {
+		return m.err
+	}
+
+	mutableRevs := m.stack.MutableRevisions()
+	if len(mutableRevs) == 0 || m.phase == PhaseUpToDate {
+		fmt.Fprintln(w, "All PRs are up to date.")
+		return nil
+	}
+
+	// Revisions are in reverse order (current at top), so list them bottom-up
+	// in the order submit would process them.
+	for i := len(mutableRevs) - 1; i >= 0; i-- {
+		plan, ok := m.plans[mutableRevs[i].Change.ID]
+		if !ok {
+			continue
+		}
+		writePlan(w, plan)
+	}
+
+	if m.cfg.StackLocation == config.StackInBody {
+		fmt.Fprintln(w, "\nStack sections:")
+		for _, line := range m.planStackSections() {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+		return nil
+	}
+
+	commentPlans, err := m.planStackComments()
+	if err != nil {
+		return fmt.Errorf("get stack comments: %w", err)
+	}
+
+	fmt.Fprintln(w, "\nStack comments:")
+	for _, line := range commentPlans {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+
+	return nil
+}
+
+// writePlan writes the planned actions for a single revision
+func writePlan(w io.Writer, plan RevisionPlan) {
+	label := plan.Change.ShortID + " " + plan.Options.Branch
+
+	switch {
+	case plan.Create:
+		fmt.Fprintf(w, "%s: create PR (base %s", label, plan.Options.Base)
+		if plan.Options.Draft {
+			fmt.Fprint(w, ", draft")
+		}
+		fmt.Fprintln(w, ")")
+		if plan.Push {
+			fmt.Fprintf(w, "  push: %s\n", shortCommit(plan.Change.CommitID))
+		}
+		fmt.Fprintf(w, "  title: %q\n", plan.Options.Title)
+		writeAdditions(w, "reviewers", plan.Options.Reviewers)
+		writeAdditions(w, "assignees", plan.Options.Assignees)
+		writeAdditions(w, "labels", plan.Options.Labels)
+		return
+	case !plan.NeedsSync():
+		fmt.Fprintf(w, "%s: PR #%d unchanged\n", label, plan.PRNumber)
+		return
+	}
+
+	fmt.Fprintf(w, "%s: update PR #%d\n", label, plan.PRNumber)
+	if plan.Push {
+		fmt.Fprintf(w, "  push: %s -> %s\n", shortCommit(plan.RemoteSHA), shortCommit(plan.Change.CommitID))
+	}
+	if len(plan.Foreign) > 0 {
+		fmt.Fprintln(w, "  commits on GitHub not pushed from jj:")
+		for _, commit := range plan.Foreign {
+			fmt.Fprintf(w, "    %s %s\n", shortCommit(commit.CommitID), firstLine(commit.Description))
+		}
+	}
+	for _, change := range plan.Changes {
+		if change.Field == "body" {
+			fmt.Fprintln(w, "  body:")
+			writeBodyDiff(w, change.Old, change.New)
+			continue
+		}
+		fmt.Fprintf(w, "  %s: %q -> %q\n", change.Field, change.Old, change.New)
+	}
+	writeAdditions(w, "reviewers", plan.AddReviewers)
+	writeAdditions(w, "assignees", plan.AddAssignees)
+	writeAdditions(w, "labels", plan.AddLabels)
+}
+
+// writeAdditions writes the users or teams that would be added to a PR field
+func writeAdditions(w io.Writer, field string, added []string) {
+	if len(added) > 0 {
+		fmt.Fprintf(w, "  %s: +%s\n", field, strings.Join(added, ", +"))
+	}
+}
+
+// writeBodyDiff writes the old body lines prefixed with "-" and the new ones with "+"
+func writeBodyDiff(w io.Writer, old, new string) {
+	for line := range strings.Lines(normalizeBody(old)) {
+		fmt.Fprintf(w, "    -%s\n", strings.TrimRight(line, "\r\n"))
+	}
+	for line := range strings.Lines(normalizeBody(new)) {
+		fmt.Fprintf(w, "    +%s\n", strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// planStackComments describes which stack comments would be created or edited.
+// Comments can only be computed for PRs that already exist; new PRs are listed
+// as receiving a new comment.
+func (m Model) planStackComments() ([]string, error) {
+	var prNumbers []int
+	for _, pr := range m.existingPRs {
+		prNumbers = append(prNumbers, pr.GetNumber())
+	}
+
+	stackComments, err := m.gh.GetPRCommentsContaining(m.ctx, m.repo, prNumbers, m.cfg.CommentMarker)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	mutableRevs := m.stack.MutableRevisions()
+	for i := len(mutableRevs) - 1; i >= 0; i-- {
+		change := mutableRevs[i].Change
+		if _, planned := m.plans[change.ID]; !planned {
+			continue
+		}
+		pr, ok := m.existingPRs[change.GitPushBookmark]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("%s: create comment on new PR", change.ShortID))
+			continue
+		}
+
+		comment, ok := stackComments[pr.GetNumber()]
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("#%d: create comment", pr.GetNumber()))
+		case comment.GetBody() != m.stackCommentBody(pr.GetNumber()) || m.hasNewPRs():
+			lines = append(lines, fmt.Sprintf("#%d: edit comment %d", pr.GetNumber(), comment.GetID()))
+		default:
+			lines = append(lines, fmt.Sprintf("#%d: comment unchanged", pr.GetNumber()))
+		}
+	}
+
+	return lines, nil
+}
+
+// planStackSections describes which PR bodies would get their stack section
+// added or edited. New PRs are listed as receiving a new section.
+func (m Model) planStackSections() []string {
+	var lines []string
+	mutableRevs := m.stack.MutableRevisions()
+	for i := len(mutableRevs) - 1; i >= 0; i-- {
+		change := mutableRevs[i].Change
+		if _, planned := m.plans[change.ID]; !planned {
+			continue
+		}
+		pr, ok := m.existingPRs[change.GitPushBookmark]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("%s: add stack section to new PR", change.ShortID))
+			continue
+		}
+
+		section, ok := prbody.Get(pr.GetBody(), prbody.Stack)
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("#%d: add stack section", pr.GetNumber()))
+		case section != strings.Trim(m.stackList(pr.GetNumber()), "\n") || m.hasNewPRs():
+			lines = append(lines, fmt.Sprintf("#%d: edit stack section", pr.GetNumber()))
+		default:
+			lines = append(lines, fmt.Sprintf("#%d: stack section unchanged", pr.GetNumber()))
+		}
+	}
+	return lines
+}
+
+// hasNewPRs reports whether any mutable revision would get a new PR
+func (m Model) hasNewPRs() bool {
+	for _, plan := range m.plans {
+		if plan.Create {
+			return true
+		}
+	}
+	return false
+}
+
+// shortCommit abbreviates a commit ID for display
+func shortCommit(commitID string) string {
+	if commitID == "" {
+		return "(none)"
+	}
+	if len(commitID) > 12 {
+		return commitID[:12]
+	}
+	return commitID
+}
@@ -0,0 +1,121 @@
+package submit
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEndToEnd runs the full submit flow against a temporary jj repository
+// that pushes to a local bare git remote, with GitHub replaced by the fake server.
+func TestEndToEnd(t *testing.T) {
+	for _, bin := range []string{"jj", "git"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not found in PATH", bin)
+		}
+	}
+
+	tmp := t.TempDir()
+	remote := filepath.Join(tmp, "remote.git")
+	seed := filepath.Join(tmp, "seed")
+	work := filepath.Join(tmp, "work")
+
+	// Isolate jj and git from the user's configuration.
+	t.Setenv("JJ_CONFIG", filepath.Join(tmp, "jj-config.toml"))
+	t.Setenv("JJ_USER", "Test User")
+	t.Setenv("JJ_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(tmp, "gitconfig"))
+	t.Setenv("GIT_AUTHOR_NAME", "Test User")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test User")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "jj-config.toml"),
+		[]byte("[templates]\ngit_push_bookmark = '\"push-\" ++ change_id.short()'\n"), 0o644))
+
+	// Create the remote with an initial commit on main.
+	run(t, tmp, "git", "init", "--bare", "-b", "main", remote)
+	run(t, tmp, "git", "init", "-b", "main", seed)
+	require.NoError(t, os.WriteFile(filepath.Join(seed, "README.md"), []byte("# test\n"), 0o644))
+	run(t, seed, "git", "add", "README.md")
+	run(t, seed, "git", "commit", "-m", "Initial commit")
+	run(t, seed, "git", "push", remote, "main")
+
+	// Clone with jj and build a two revision stack.
+	run(t, tmp, "jj", "git", "clone", remote, work)
+	run(t, work, "jj", "new", "main", "-m", "Add auth\n\nAuth body")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "auth.go"), []byte("package auth\n"), 0o644))
+	run(t, work, "jj", "new", "-m", "Add login form")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "login.go"), []byte("package login\n"), 0o644))
+
+	server := githubtest.NewServer(t)
+	server.ResolveBranch = func(_ github.Repo, branch string) (string, bool) {
+		out, err := exec.Command("git", "--git-dir", remote, "rev-parse", "--verify", "refs/heads/"+branch).Output()
+		if err != nil {
+			return "", false
+		}
+		return strings.TrimSpace(string(out)), true
+	}
+
+	t.Chdir(work)
+
+	// The branches on the remote point at the commits of the stack.
+	assertPushed := func(prs []*gogithub.PullRequest) {
+		t.Helper()
+		for i, rev := range []string{"@-", "@"} {
+			commit := run(t, work, "jj", "log", "--no-graph", "-r", rev, "-T", "commit_id")
+			ref := run(t, tmp, "git", "--git-dir", remote, "rev-parse", "refs/heads/"+prs[i].GetHead().GetRef())
+			assert.Equal(t, commit, ref, "branch of %s", rev)
+		}
+	}
+
+	m, _, err := runSubmit(t, nil, server, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+
+	prs := server.PullRequests(githubtest.DefaultRepo)
+	require.Len(t, prs, 2)
+	assert.Equal(t, "Add auth", prs[0].GetTitle())
+	assert.Equal(t, "main", prs[0].GetBase().GetRef())
+	assert.Equal(t, "Add login form", prs[1].GetTitle())
+	assert.Equal(t, prs[0].GetHead().GetRef(), prs[1].GetBase().GetRef())
+	for _, pr := range prs {
+		assert.Len(t, server.Comments(githubtest.DefaultRepo, pr.GetNumber()), 1)
+	}
+	assertPushed(prs)
+
+	// Nothing changed locally, so a second run is a no-op.
+	m, _, err = runSubmit(t, nil, server, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, PhaseUpToDate, m.phase)
+
+	// Rewording the bottom revision updates its PR in place.
+	run(t, work, "jj", "describe", "-r", "@-", "-m", "Add authentication")
+	m, _, err = runSubmit(t, nil, server, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, PhaseComplete, m.phase)
+
+	prs = server.PullRequests(githubtest.DefaultRepo)
+	require.Len(t, prs, 2)
+	assert.Equal(t, "Add authentication", prs[0].GetTitle())
+	assertPushed(prs)
+}
+
+// run executes a command in dir and fails the test if it does not succeed.
+// Returns its trimmed output.
+func run(t *testing.T, dir string, name string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "%s %s: %s", name, strings.Join(args, " "), out)
+	return strings.TrimSpace(string(out))
+}
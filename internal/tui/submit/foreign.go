@@ -0,0 +1,115 @@
+package submit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/jj"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ForeignImportedMsg reports that the foreign commits of a revision were
+// squashed into it.
+type ForeignImportedMsg struct {
+	ChangeID string
+	Err      error
+}
+
+// foreignCommits returns the commits on the PR branch of change, whose head
+// is remoteSHA, that weren't pushed from jj. Earlier versions of the stack's
+// own changes were pushed by a previous submit, so they don't count.
+func (m Model) foreignCommits(change jj.Change, remoteSHA string, changesByID map[string]*jj.Change) ([]jj.Change, error) {
+	commits, err := m.jj.GetChanges(fmt.Sprintf("::%s ~ ::(%s | trunk())", remoteSHA, change.CommitID))
+	if err != nil {
+		return nil, fmt.Errorf("look up commits on %s: %w", change.GitPushBookmark, err)
+	}
+	return slices.DeleteFunc(commits, func(c jj.Change) bool {
+		_, ok := changesByID[c.ID]
+		return ok
+	}), nil
+}
+
+// pendingForeign returns the plan of the first revision, bottom-up, whose
+// foreign commits still need a decision.
+func (m Model) pendingForeign() (RevisionPlan, bool) {
+	mutableRevs := m.stack.MutableRevisions()
+	for _, rev := range slices.Backward(mutableRevs) {
+		plan, ok := m.plans[rev.Change.ID]
+		if !ok || len(plan.Foreign) == 0 {
+			continue
+		}
+		if _, decided := m.resolutions[rev.Change.ID]; !decided {
+			return plan, true
+		}
+	}
+	return RevisionPlan{}, false
+}
+
+// nextImport returns the ID of the first revision, bottom-up, whose foreign
+// commits are to be imported, or "" if there is none.
+func (m Model) nextImport() string {
+	for _, rev := range slices.Backward(m.stack.MutableRevisions()) {
+		if m.resolutions[rev.Change.ID] == config.ForeignImport {
+			return rev.Change.ID
+		}
+	}
+	return ""
+}
+
+// resolve records what to do with the foreign commits of a revision. Imports
+// run right away and reload the stack.
+func (m Model) resolve(changeID string, resolution config.ForeignCommitPolicy) (Model, tea.Cmd) {
+	m.resolutions[changeID] = resolution
+	if resolution != config.ForeignImport {
+		return m, nil
+	}
+
+	m.phase = PhaseImporting
+	return m, m.importForeignCmd(m.plans[changeID])
+}
+
+// importForeignCmd squashes the foreign commits of a revision into it
+func (m Model) importForeignCmd(plan RevisionPlan) tea.Cmd {
+	ids := make([]string, len(plan.Foreign))
+	for i, commit := range plan.Foreign {
+		ids[i] = commit.CommitID
+	}
+	from := strings.Join(ids, " | ")
+	into := fmt.Sprintf("change_id(%s)", plan.Change.ID)
+
+	return func() tea.Msg {
+		return ForeignImportedMsg{ChangeID: plan.Change.ID, Err: m.jj.Squash(from, into)}
+	}
+}
+
+// foreignError explains why submit can't go on without a decision about
+// foreign commits, or returns nil if every revision is resolved.
+func (m Model) foreignError() error {
+	plan, ok := m.pendingForeign()
+	if !ok {
+		return nil
+	}
+	return fmt.Errorf("%s has %d commit(s) on GitHub that weren't pushed from jj; "+
+		"set jj-github.foreign-commits to \"import\", \"overwrite\" or \"skip\"",
+		plan.Options.Branch, len(plan.Foreign))
+}
+
+// foreignView describes the foreign commits of plan and the choices
+func foreignView(plan RevisionPlan) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s has %d commit(s) on GitHub that weren't pushed from jj:\n",
+		plan.Options.Branch, len(plan.Foreign))
+	for _, commit := range plan.Foreign {
+		fmt.Fprintf(&sb, "  %s %s\n", shortCommit(commit.CommitID), firstLine(commit.Description))
+	}
+	sb.WriteString("Import them into the revision, overwrite them, or skip this revision.\n")
+	return sb.String()
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,113 @@
+package submit
+
+import (
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// RunHeadless drives the submit workflow without a bubbletea program.
+// It runs the same commands and messages as the TUI, skips the confirmation
+// prompt and writes one line of progress per step to w. Batched commands run
+// concurrently, and their messages are handled as they arrive.
+// Returns the final model and the workflow error, if any, once the model stops
+// producing commands.
+func RunHeadless(m Model, w io.Writer) (Model, error) {
+	fmt.Fprintln(w, "Fetching remote state...")
+
+	msgs := make(chan tea.Msg)
+	running := 0
+	run := func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+		running++
+		go func() { msgs <- cmd() }()
+	}
+
+	run(m.loadRevisionsAndPRsCmd())
+	for running > 0 {
+		msg := <-msgs
+		running--
+
+		switch msg := msg.(type) {
+		case tea.QuitMsg:
+			continue
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				run(cmd)
+			}
+			continue
+		}
+
+		next, cmd := m.Update(msg)
+		m = next.(Model)
+		run(cmd)
+
+		m.logProgress(w, msg)
+
+		// There is no one to ask about foreign commits.
+		m, cmd = m.Confirm(false)
+		run(cmd)
+	}
+
+	switch m.phase {
+	case PhaseError:
+		return m, m.err
+	case PhaseUpToDate:
+		fmt.Fprintln(w, "All PRs are up to date.")
+	case PhaseComplete:
+		fmt.Fprintf(w, "%d pull request(s) synced successfully.\n", len(m.stack.MutableRevisions()))
+	}
+
+	return m, nil
+}
+
+// logProgress writes a single line describing the result of msg
+func (m Model) logProgress(w io.Writer, msg tea.Msg) {
+	switch msg := msg.(type) {
+	case RevisionsLoadedMsg:
+		if msg.Err != nil {
+			return
+		}
+		if m.phase == PhaseConfirmation {
+			fmt.Fprintf(w, "%d of %d revision(s) will be synced to GitHub.\n",
+				m.stack.RevisionsNeedingSync(), len(m.stack.MutableRevisions()))
+		}
+
+	case RevisionsPushedMsg:
+		if msg.Err != nil {
+			fmt.Fprintf(w, "push: %v\n", msg.Err)
+			return
+		}
+		for _, change := range msg.Changes {
+			fmt.Fprintf(w, "%s: pushed %s\n", change.ShortID, change.GitPushBookmark)
+		}
+
+	case RevisionSyncedMsg:
+		shortID := m.shortID(msg.ChangeID)
+		if msg.Err != nil {
+			fmt.Fprintf(w, "%s: %v\n", shortID, msg.Err)
+			return
+		}
+		fmt.Fprintf(w, "%s: PR #%d: %s\n", shortID, msg.PRNumber, syncSummary(m.needsPush(msg.ChangeID), msg))
+
+	case AllCommentsUpdatedMsg:
+		if msg.Err != nil {
+			fmt.Fprintf(w, "updating stack comments: %v\n", msg.Err)
+			return
+		}
+		fmt.Fprintln(w, "Stack comments updated.")
+	}
+}
+
+// shortID returns the short change ID for display, falling back to the full ID
+func (m Model) shortID(changeID string) string {
+	for _, rev := range m.stack.Revisions {
+		if rev.Change.ID == changeID {
+			return rev.Change.ShortID
+		}
+	}
+	return changeID
+}
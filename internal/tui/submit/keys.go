@@ -7,17 +7,23 @@ import "github.com/charmbracelet/bubbles/key"
 type KeyMap struct {
 	Submit key.Binding
 	Quit   key.Binding
+
+	// Choices for commits pushed to a PR branch from outside jj, only
+	// enabled while one needs a decision
+	Import    key.Binding
+	Overwrite key.Binding
+	Skip      key.Binding
 }
 
 // ShortHelp returns key bindings for the short help view
 func (k KeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Submit, k.Quit}
+	return []key.Binding{k.Submit, k.Import, k.Overwrite, k.Skip, k.Quit}
 }
 
 // FullHelp returns key bindings for the full help view
 func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Submit, k.Quit},
+		{k.Submit, k.Import, k.Overwrite, k.Skip, k.Quit},
 	}
 }
 
@@ -32,6 +38,21 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("q", "ctrl+c"),
 			key.WithHelp("q", "quit"),
 		),
+		Import: key.NewBinding(
+			key.WithKeys("i"),
+			key.WithHelp("i", "import"),
+			key.WithDisabled(),
+		),
+		Overwrite: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "overwrite"),
+			key.WithDisabled(),
+		),
+		Skip: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "skip"),
+			key.WithDisabled(),
+		),
 	}
 }
 
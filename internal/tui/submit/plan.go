@@ -0,0 +1,287 @@
+package submit
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/prbody"
+	"github.com/cbrewster/jj-github/internal/trailer"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// FieldChange describes a pull request field that differs from the desired value
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// RevisionPlan describes what submit would do for a single revision
+type RevisionPlan struct {
+	Change    jj.Change
+	Options   github.PullRequestOptions
+	PRNumber  int    // Existing PR number, 0 if a PR would be created
+	RemoteSHA string // Commit the PR head currently points at
+	Push      bool
+	Create    bool
+	Changes   []FieldChange
+
+	// Foreign lists the commits on the PR branch that weren't pushed from
+	// jj, which pushing would discard.
+	Foreign []jj.Change
+
+	// Reviewers, assignees and labels to add to an existing PR. Existing
+	// ones are never removed.
+	AddReviewers []string
+	AddAssignees []string
+	AddLabels    []string
+}
+
+// NeedsSync reports whether the plan requires any remote mutation
+func (p RevisionPlan) NeedsSync() bool {
+	return p.Push || p.Create || len(p.Changes) > 0 ||
+		len(p.AddReviewers) > 0 || len(p.AddAssignees) > 0 || len(p.AddLabels) > 0
+}
+
+// prOptionsForChange computes the desired pull request fields for a change,
+// given its existing PR if any.
+// When branches are pushed to a fork, bases that only exist in the fork can't
+// be targeted, so every PR is based on trunk.
+func (m Model) prOptionsForChange(
+	change jj.Change,
+	changesByID map[string]*jj.Change,
+	trunkName string,
+	pr *gogithub.PullRequest,
+) github.PullRequestOptions {
+	headOwner := m.headOwner()
+
+	// Merge revisions were checked while loading, so errors can't happen here.
+	parent, _ := m.baseParent(change, changesByID)
+	var base string
+	if parent == nil {
+		// Parent not in our result set - use trunk as base
+		base = trunkName
+	} else if parent.Immutable {
+		if len(parent.Bookmarks) > 0 {
+			base = parent.Bookmarks[0].Name
+		} else {
+			base = trunkName
+		}
+	} else if headOwner != "" {
+		base = trunkName
+	} else if _, ok := m.existingPRs[parent.GitPushBookmark]; m.existingOnly && !ok && pr != nil {
+		// The parent gets no PR of its own, so its branch may not exist
+		base = pr.GetBase().GetRef()
+	} else {
+		base = parent.GitPushBookmark
+	}
+
+	title, body, _ := strings.Cut(change.Description, "\n")
+	body, trailers := trailer.Extract(body, "reviewer", "reviewers", "assignee", "assignees", "label", "labels")
+	isDraft := m.cfg.DraftKeyword != "" &&
+		strings.Contains(strings.ToLower(title), strings.ToLower(m.cfg.DraftKeyword))
+
+	return github.PullRequestOptions{
+		Title:  title,
+		Body:   m.prBody(change.ID, body, pr),
+		Branch: change.GitPushBookmark,
+		Base:   base,
+		Draft:  isDraft,
+
+		HeadOwner: headOwner,
+
+		Reviewers: merge(trailer.Users(m.cfg.Reviewers...), trailer.Users(trailers["reviewer"]...), trailer.Users(trailers["reviewers"]...)),
+		Assignees: merge(trailer.Users(m.cfg.Assignees...), trailer.Users(trailers["assignee"]...), trailer.Users(trailers["assignees"]...)),
+		Labels:    merge(m.cfg.Labels, trailer.List(trailers["label"]...), trailer.List(trailers["labels"]...)),
+	}
+}
+
+// mutableParents returns the parents of change that are part of the stack
+func mutableParents(change jj.Change, changesByID map[string]*jj.Change) []*jj.Change {
+	var parents []*jj.Change
+	for _, p := range change.Parents {
+		if parent := changesByID[p.ChangeID]; parent != nil && !parent.Immutable {
+			parents = append(parents, parent)
+		}
+	}
+	return parents
+}
+
+// baseParent returns the parent whose branch the PR of change is based on, or
+// nil if its parent isn't part of the loaded revisions. Revisions merging
+// several revisions of the stack follow the merge-parent policy; when it
+// refuses them, an error explains why along with the first parent.
+func (m Model) baseParent(change jj.Change, changesByID map[string]*jj.Change) (*jj.Change, error) {
+	parents := mutableParents(change, changesByID)
+	switch len(parents) {
+	case 0:
+		return changesByID[change.Parents[0].ChangeID], nil
+	case 1:
+		return parents[0], nil
+	}
+
+	switch m.cfg.MergeParent {
+	case config.MergeParentFirst:
+		return parents[0], nil
+	case config.MergeParentOpenPR:
+		for _, parent := range parents {
+			// PRs created by this submit don't count, so the choice made
+			// while planning holds while syncing.
+			if _, ok := m.existingPRs[parent.GitPushBookmark]; ok && !m.plans[parent.ID].Create {
+				return parent, nil
+			}
+		}
+		return parents[0], nil
+	default:
+		var ids []string
+		for _, parent := range parents {
+			ids = append(ids, parent.ShortID)
+		}
+		return parents[0], fmt.Errorf(
+			"revision %s merges %s, but a pull request can only have one base; "+
+				"set jj-github.merge-parent to \"first\" or \"open-pr\" to choose one",
+			change.ShortID, strings.Join(ids, " and "))
+	}
+}
+
+// prBody returns the desired body of the pull request of change changeID for
+// description. The template only applies to new PRs. Once a PR has a
+// description section, only that section is kept in sync so edits to the rest
+// of the body, like a filled-in template, are preserved; other existing PRs
+// keep a plain body. The stack section is always carried over, and a hidden
+// marker records the change so the PR is found again if its bookmark is
+// renamed.
+func (m Model) prBody(changeID, description string, pr *gogithub.PullRequest) string {
+	if pr == nil {
+		body := description
+		if m.template != "" || m.cfg.StackLocation == config.StackInBody {
+			body = prbody.ApplyTemplate(m.template, prbody.Description, description, m.cfg.TemplatePlacement)
+		}
+		return prbody.SetChangeID(body, changeID)
+	}
+
+	if body, ok := prbody.Replace(pr.GetBody(), prbody.Description, description); ok {
+		return prbody.SetChangeID(body, changeID)
+	}
+	body := description
+	if stack, ok := prbody.Get(pr.GetBody(), prbody.Stack); ok {
+		body = prbody.Set(body, prbody.Stack, stack)
+	}
+	return prbody.SetChangeID(body, changeID)
+}
+
+// merge concatenates lists, dropping duplicates
+func merge(lists ...[]string) []string {
+	var result []string
+	for _, list := range lists {
+		for _, item := range list {
+			if !slices.Contains(result, item) {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// planRevision compares the desired pull request against the existing one, if any.
+// The branch is only pushed if it doesn't already point at the commit on
+// pushRemote, or at the PR head when there is a PR.
+// reviewed lists the users that already reviewed pr; they aren't asked again.
+func planRevision(change jj.Change, pushRemote string, opts github.PullRequestOptions, pr *gogithub.PullRequest, reviewed []string) RevisionPlan {
+	plan := RevisionPlan{
+		Change:  change,
+		Options: opts,
+	}
+
+	if pr == nil {
+		plan.Push = !change.PushedTo(pushRemote)
+		plan.Create = true
+		return plan
+	}
+
+	plan.PRNumber = pr.GetNumber()
+	plan.RemoteSHA = pr.GetHead().GetSHA()
+	plan.Push = plan.RemoteSHA != change.CommitID
+
+	if pr.GetTitle() != opts.Title {
+		plan.Changes = append(plan.Changes, FieldChange{Field: "title", Old: pr.GetTitle(), New: opts.Title})
+	}
+	// Normalize body comparison by trimming trailing whitespace, as GitHub may strip it.
+	// The stack section is kept up to date separately, after every PR exists.
+	if normalizeBody(prbody.Remove(pr.GetBody(), prbody.Stack)) != normalizeBody(prbody.Remove(opts.Body, prbody.Stack)) {
+		plan.Changes = append(plan.Changes, FieldChange{Field: "body", Old: pr.GetBody(), New: opts.Body})
+	}
+	if pr.GetBase().GetRef() != opts.Base {
+		plan.Changes = append(plan.Changes, FieldChange{Field: "base", Old: pr.GetBase().GetRef(), New: opts.Base})
+	}
+	if pr.GetDraft() != opts.Draft {
+		plan.Changes = append(plan.Changes, FieldChange{
+			Field: "draft",
+			Old:   strconv.FormatBool(pr.GetDraft()),
+			New:   strconv.FormatBool(opts.Draft),
+		})
+	}
+
+	plan.AddReviewers = missingReviewers(opts.Reviewers, pr, reviewed)
+	plan.AddAssignees = missingAssignees(opts.Assignees, pr)
+	plan.AddLabels = missingLabels(opts.Labels, pr)
+
+	return plan
+}
+
+// missingReviewers returns the reviewers that have neither been requested nor
+// reviewed pr. Teams are satisfied by any review, since GitHub drops the team
+// request once a member reviews. The author can't be requested.
+func missingReviewers(reviewers []string, pr *gogithub.PullRequest, reviewed []string) []string {
+	var missing []string
+	for _, reviewer := range reviewers {
+		if _, team, ok := strings.Cut(reviewer, "/"); ok {
+			requested := slices.ContainsFunc(pr.RequestedTeams, func(t *gogithub.Team) bool {
+				return t.GetSlug() == team
+			})
+			if !requested && len(reviewed) == 0 {
+				missing = append(missing, reviewer)
+			}
+			continue
+		}
+
+		requested := slices.ContainsFunc(pr.RequestedReviewers, func(u *gogithub.User) bool {
+			return u.GetLogin() == reviewer
+		})
+		if !requested && reviewer != pr.GetUser().GetLogin() && !slices.Contains(reviewed, reviewer) {
+			missing = append(missing, reviewer)
+		}
+	}
+	return missing
+}
+
+// missingLabels returns the labels not yet on pr
+func missingLabels(labels []string, pr *gogithub.PullRequest) []string {
+	var missing []string
+	for _, label := range labels {
+		if !slices.ContainsFunc(pr.Labels, func(l *gogithub.Label) bool { return l.GetName() == label }) {
+			missing = append(missing, label)
+		}
+	}
+	return missing
+}
+
+// missingAssignees returns the assignees not yet assigned to pr
+func missingAssignees(assignees []string, pr *gogithub.PullRequest) []string {
+	var missing []string
+	for _, assignee := range assignees {
+		if !slices.ContainsFunc(pr.Assignees, func(u *gogithub.User) bool { return u.GetLogin() == assignee }) {
+			missing = append(missing, assignee)
+		}
+	}
+	return missing
+}
+
+func normalizeBody(body string) string {
+	return strings.TrimRight(body, " \t\n\r")
+}
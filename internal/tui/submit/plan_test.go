@@ -0,0 +1,68 @@
+package submit
+
+import (
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanRevision(t *testing.T) {
+	change := jj.Change{ID: "abc", CommitID: "local"}
+	opts := github.PullRequestOptions{
+		Title:  "Add feature",
+		Body:   "Details\n",
+		Branch: "push-abc",
+		Base:   "main",
+	}
+
+	for _, tc := range []struct {
+		Name     string
+		PR       *gogithub.PullRequest
+		Push     bool
+		Create   bool
+		Expected []FieldChange
+	}{
+		{
+			Name:   "new PR",
+			Push:   true,
+			Create: true,
+		},
+		{
+			Name: "up to date",
+			PR: &gogithub.PullRequest{
+				Number: gogithub.Ptr(1),
+				Title:  gogithub.Ptr("Add feature"),
+				Body:   gogithub.Ptr("Details"),
+				Head:   &gogithub.PullRequestBranch{SHA: gogithub.Ptr("local")},
+				Base:   &gogithub.PullRequestBranch{Ref: gogithub.Ptr("main")},
+			},
+		},
+		{
+			Name: "push and metadata",
+			PR: &gogithub.PullRequest{
+				Number: gogithub.Ptr(1),
+				Title:  gogithub.Ptr("Old title"),
+				Body:   gogithub.Ptr("Details"),
+				Head:   &gogithub.PullRequestBranch{SHA: gogithub.Ptr("remote")},
+				Base:   &gogithub.PullRequestBranch{Ref: gogithub.Ptr("push-parent")},
+				Draft:  gogithub.Ptr(true),
+			},
+			Push: true,
+			Expected: []FieldChange{
+				{Field: "title", Old: "Old title", New: "Add feature"},
+				{Field: "base", Old: "push-parent", New: "main"},
+				{Field: "draft", Old: "true", New: "false"},
+			},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			plan := planRevision(change, "origin", opts, tc.PR, nil)
+			assert.Equal(t, tc.Push, plan.Push)
+			assert.Equal(t, tc.Create, plan.Create)
+			assert.Equal(t, tc.Expected, plan.Changes)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package submit
+
+import "github.com/cbrewster/jj-github/internal/report"
+
+// Report summarizes the outcome of the workflow for machine-readable output.
+// Revisions are listed bottom-up, in the order they are submitted.
+func (m Model) Report() report.SubmitReport {
+	r := report.NewSubmitReport()
+	if m.err != nil {
+		r.Error = m.err.Error()
+	}
+
+	mutableRevs := m.stack.MutableRevisions()
+	for i := len(mutableRevs) - 1; i >= 0; i-- {
+		rev := mutableRevs[i]
+		plan, ok := m.plans[rev.Change.ID]
+		if !ok {
+			continue
+		}
+
+		entry := report.SubmitRevision{
+			ChangeID: rev.Change.ID,
+			CommitID: rev.Change.CommitID,
+			Branch:   plan.Options.Branch,
+			Base:     plan.Options.Base,
+			PRNumber: rev.PRNumber,
+			Action:   m.actions[rev.Change.ID],
+		}
+		if pr, ok := m.existingPRs[rev.Change.GitPushBookmark]; ok {
+			entry.PRNumber = pr.GetNumber()
+			entry.PRURL = pr.GetHTMLURL()
+		}
+		if rev.Error != nil {
+			entry.Error = rev.Error.Error()
+		}
+
+		if entry.Action == "" {
+			if plan.NeedsSync() && m.phase != PhaseUpToDate {
+				entry.Action = report.ActionSkipped
+			} else {
+				entry.Action = report.ActionUnchanged
+			}
+		}
+
+		r.Revisions = append(r.Revisions, entry)
+	}
+
+	return r
+}
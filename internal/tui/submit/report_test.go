@@ -0,0 +1,46 @@
+package submit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/report"
+	"github.com/cbrewster/jj-github/internal/report/reporttest"
+	"github.com/cbrewster/jj-github/internal/tui/components"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+func TestReportGolden(t *testing.T) {
+	changes := []jj.Change{
+		{ID: "aaaaaaaa", ShortID: "a", CommitID: "c1", GitPushBookmark: "push-aaaaaaaa", Description: "First"},
+		{ID: "bbbbbbbb", ShortID: "b", CommitID: "c2", GitPushBookmark: "push-bbbbbbbb", Description: "Second"},
+		{ID: "cccccccc", ShortID: "c", CommitID: "c3", GitPushBookmark: "push-cccccccc", Description: "Third"},
+		{ID: "dddddddd", ShortID: "d", CommitID: "c4", GitPushBookmark: "push-dddddddd", Description: "Fourth"},
+	}
+
+	m := NewModel(t.Context(), nil, nil, Options{Repo: github.Repo{Owner: "owner", Name: "repo"}, Revset: "@", Config: config.Default()})
+	m.phase = PhaseError
+	m.err = errors.New("push: exit status 1")
+	m.stack = components.NewStack(changes, "main")
+	m.plans = map[string]RevisionPlan{
+		"aaaaaaaa": {Options: github.PullRequestOptions{Branch: "push-aaaaaaaa", Base: "main"}},
+		"bbbbbbbb": {Options: github.PullRequestOptions{Branch: "push-bbbbbbbb", Base: "push-aaaaaaaa"}, Create: true, Push: true},
+		"cccccccc": {Options: github.PullRequestOptions{Branch: "push-cccccccc", Base: "push-bbbbbbbb"}, Create: true, Push: true},
+		"dddddddd": {Options: github.PullRequestOptions{Branch: "push-dddddddd", Base: "push-cccccccc"}, Create: true, Push: true},
+	}
+	m.existingPRs = map[string]*gogithub.PullRequest{
+		"push-aaaaaaaa": {Number: gogithub.Ptr(1), HTMLURL: gogithub.Ptr("https://github.com/owner/repo/pull/1")},
+		"push-bbbbbbbb": {Number: gogithub.Ptr(2), HTMLURL: gogithub.Ptr("https://github.com/owner/repo/pull/2")},
+	}
+	m.actions = map[string]report.Action{
+		"aaaaaaaa": report.ActionUnchanged,
+		"bbbbbbbb": report.ActionCreated,
+		"cccccccc": report.ActionFailed,
+	}
+	m.stack.SetRevisionError("cccccccc", m.err)
+
+	reporttest.AssertGolden(t, "report.golden", m.Report())
+}
@@ -0,0 +1,88 @@
+package submit
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// templateFilesets match the pull request templates GitHub recognizes: a
+// pull_request_template.md file, or Markdown files in a pull_request_template
+// directory, in the repository root, .github or docs.
+var templateFilesets = []string{
+	`root-glob-i:".github/pull_request_template.md"`,
+	`root-glob-i:"pull_request_template.md"`,
+	`root-glob-i:"docs/pull_request_template.md"`,
+	`root-glob-i:".github/pull_request_template/*.md"`,
+	`root-glob-i:"pull_request_template/*.md"`,
+	`root-glob-i:"docs/pull_request_template/*.md"`,
+}
+
+// templateDirs lists the directories holding a single-file template, most
+// preferred first.
+var templateDirs = []string{".github", ".", "docs"}
+
+// loadTemplate reads the pull request template from trunk, or returns "" if
+// the repository has none.
+func (m Model) loadTemplate() (string, error) {
+	paths, err := m.jj.ListFiles("trunk()", templateFilesets...)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := pickTemplate(paths, m.cfg.Template)
+	if err != nil || file == "" {
+		return "", err
+	}
+
+	return m.jj.ShowFile("trunk()", file)
+}
+
+// pickTemplate chooses the template to use among paths. A named template is
+// looked up in the template directories. Otherwise the single-file template
+// is used, or the only directory template if there is exactly one.
+func pickTemplate(paths []string, name string) (string, error) {
+	single := ""
+	singleRank := len(templateDirs)
+	named := make(map[string]string)
+	for _, p := range paths {
+		// jj prints paths relative to the current directory.
+		clean := strings.ToLower(path.Clean(strings.ReplaceAll(p, `\`, "/")))
+		for strings.HasPrefix(clean, "../") {
+			clean = strings.TrimPrefix(clean, "../")
+		}
+
+		dir := path.Dir(clean)
+		if path.Base(dir) == "pull_request_template" {
+			named[strings.TrimSuffix(path.Base(clean), ".md")] = p
+		} else if rank := slices.Index(templateDirs, dir); rank >= 0 && rank < singleRank {
+			single, singleRank = p, rank
+		}
+	}
+
+	if name != "" {
+		if p, ok := named[strings.TrimSuffix(strings.ToLower(name), ".md")]; ok {
+			return p, nil
+		}
+		available := make([]string, 0, len(named))
+		for n := range named {
+			available = append(available, n)
+		}
+		slices.Sort(available)
+		if len(available) == 0 {
+			return "", fmt.Errorf("pull request template %q not found", name)
+		}
+		return "", fmt.Errorf("pull request template %q not found, available: %s", name, strings.Join(available, ", "))
+	}
+
+	if single != "" {
+		return single, nil
+	}
+	if len(named) == 1 {
+		for _, p := range named {
+			return p, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,68 @@
+package submit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPickTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Paths    []string
+		Template string
+		Expected string
+		Err      bool
+	}{
+		{
+			Name: "no templates",
+		},
+		{
+			Name:     ".github is preferred",
+			Paths:    []string{".github/PULL_REQUEST_TEMPLATE.md", "docs/pull_request_template.md", "pull_request_template.md"},
+			Expected: ".github/PULL_REQUEST_TEMPLATE.md",
+		},
+		{
+			Name:     "paths relative to a subdirectory",
+			Paths:    []string{"../../docs/pull_request_template.md"},
+			Expected: "../../docs/pull_request_template.md",
+		},
+		{
+			Name:     "single template is the default",
+			Paths:    []string{".github/PULL_REQUEST_TEMPLATE/bugfix.md", ".github/PULL_REQUEST_TEMPLATE/feature.md", ".github/pull_request_template.md"},
+			Expected: ".github/pull_request_template.md",
+		},
+		{
+			Name:     "only directory template",
+			Paths:    []string{".github/PULL_REQUEST_TEMPLATE/feature.md"},
+			Expected: ".github/PULL_REQUEST_TEMPLATE/feature.md",
+		},
+		{
+			Name:  "several directory templates and none selected",
+			Paths: []string{".github/PULL_REQUEST_TEMPLATE/bugfix.md", ".github/PULL_REQUEST_TEMPLATE/feature.md"},
+		},
+		{
+			Name:     "selected directory template",
+			Paths:    []string{".github/PULL_REQUEST_TEMPLATE/bugfix.md", ".github/PULL_REQUEST_TEMPLATE/Feature.md", ".github/pull_request_template.md"},
+			Template: "feature",
+			Expected: ".github/PULL_REQUEST_TEMPLATE/Feature.md",
+		},
+		{
+			Name:     "selected template not found",
+			Paths:    []string{".github/PULL_REQUEST_TEMPLATE/bugfix.md"},
+			Template: "feature.md",
+			Err:      true,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			path, err := pickTemplate(tc.Paths, tc.Template)
+			if tc.Err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, path)
+		})
+	}
+}
@@ -5,10 +5,15 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github"
 	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/report"
 	"github.com/cbrewster/jj-github/internal/tui/components"
+	"github.com/cbrewster/jj-github/internal/tui/submit"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	gogithub "github.com/google/go-github/v80/github"
 )
 
 // Phase represents the current phase of the sync workflow
@@ -18,6 +23,10 @@ const (
 	PhaseFetching Phase = iota
 	PhaseUpToDate
 	PhaseRebasing
+	PhaseFindingBranches
+	PhaseConfirmDelete
+	PhaseDeletingBranches
+	PhaseSubmitting
 	PhaseComplete
 	PhaseError
 )
@@ -29,7 +38,7 @@ const (
 	StatePending BookmarkState = iota
 	StateInProgress
 	StateSuccess
-	StateSkipped // Commit became empty and was abandoned (e.g., after squash-merge)
+	StateSkipped // Commits became empty or were merged, and were abandoned
 	StateConflict
 	StateError
 )
@@ -38,7 +47,12 @@ const (
 type BookmarkItem struct {
 	Bookmark jj.Bookmark
 	State    BookmarkState
-	Error    error
+	Merged   []int    // PRs of the stack merged into trunk, whose revisions were abandoned
+	Diverged []int    // PRs merged into trunk whose revisions changed since, so were rebased
+	Roots    []string // Change IDs of the stack's roots after rebasing
+	// PullRequests are the PRs of the stack after resubmitting it
+	PullRequests []report.SubmitRevision
+	Error        error
 }
 
 // Messages for async operations
@@ -53,6 +67,9 @@ type (
 		ChangeID     string
 		HasConflict  bool
 		SkippedEmpty bool
+		Merged       []int
+		Diverged     []int
+		Roots        []string
 		Err          error
 	}
 )
@@ -75,19 +92,57 @@ type Model struct {
 	conflictCount int
 
 	// Dependencies
-	ctx context.Context
+	ctx      context.Context
+	cfg      config.Config
+	jj       *jj.Client
+	gh       *github.Client // Optional; looks up merged PRs
+	repo     github.Repo
+	headRepo github.Repo
+
+	// Branches of merged or closed PRs
+	staleBranches   []StaleBranch
+	branchesDeleted bool
+
+	// Resubmitting rebased stacks, one at a time
+	submitOpts  *submit.Options // Nil unless resubmitting
+	submit      submit.Model
+	submitIndex int
+	headless    bool
 }
 
 // NewModel creates a new sync TUI model
-func NewModel(ctx context.Context) Model {
+func NewModel(ctx context.Context, jjClient *jj.Client, cfg config.Config) Model {
 	return Model{
 		phase:   PhaseFetching,
 		spinner: components.NewSpinner(),
 		keys:    DefaultKeyMap(),
 		ctx:     ctx,
+		cfg:     cfg,
+		jj:      jjClient,
 	}
 }
 
+// WithGitHub returns a copy of the model that asks GitHub which PRs of each
+// stack were merged into trunk. Their revisions are abandoned before the rest
+// of the stack is rebased, even when they wouldn't become empty, e.g. because
+// the PR was edited on GitHub before merging. headRepo is where branches are
+// pushed, and may be a fork of repo.
+func (m Model) WithGitHub(gh *github.Client, repo, headRepo github.Repo) Model {
+	m.gh = gh
+	m.repo = repo
+	m.headRepo = headRepo
+	return m
+}
+
+// headOwner returns the owner of the fork branches are pushed to, or "" when
+// branches are pushed to the pull request repository itself
+func (m Model) headOwner() string {
+	if m.headRepo.Owner == m.repo.Owner {
+		return ""
+	}
+	return m.headRepo.Owner
+}
+
 // Init initializes the model and starts the fetch operation
 func (m Model) Init() tea.Cmd {
 	return tea.Batch(
@@ -98,6 +153,10 @@ func (m Model) Init() tea.Cmd {
 
 // Update handles messages and updates the model
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.phase == PhaseSubmitting {
+		return m.updateSubmit(msg)
+	}
+
 	var cmds []tea.Cmd
 
 	switch msg := msg.(type) {
@@ -109,8 +168,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch {
 		case key.Matches(msg, m.keys.Quit):
 			return m, tea.Quit
+		case key.Matches(msg, m.keys.Delete) && m.phase == PhaseConfirmDelete:
+			return m.deleteBranches()
+		case key.Matches(msg, m.keys.Keep) && m.phase == PhaseConfirmDelete:
+			return m.keepBranches()
 		}
 
+	case StaleBranchesMsg, BranchesDeletedMsg:
+		return m.updateBranches(msg)
+
 	case FetchCompleteMsg:
 		if msg.Err != nil {
 			m.phase = PhaseError
@@ -121,8 +187,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.trunkName = msg.TrunkName
 
 		if len(msg.Bookmarks) == 0 {
-			m.phase = PhaseUpToDate
-			return m, tea.Quit
+			return m.findBranches()
 		}
 
 		// Initialize bookmark items
@@ -143,6 +208,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Find the bookmark and update its state
 		for i := range m.bookmarks {
 			if m.bookmarks[i].Bookmark.ChangeID == msg.ChangeID {
+				m.bookmarks[i].Merged = msg.Merged
+				m.bookmarks[i].Diverged = msg.Diverged
+				m.bookmarks[i].Roots = msg.Roots
 				if msg.Err != nil {
 					m.bookmarks[i].State = StateError
 					m.bookmarks[i].Error = msg.Err
@@ -166,9 +234,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, m.rebaseNextCmd()
 		}
 
-		// All done
-		m.phase = PhaseComplete
-		return m, tea.Quit
+		// All rebased; clean up the branches of merged PRs, then resubmit
+		// what is left of each stack, if asked to
+		return m.findBranches()
 	}
 
 	// Update spinner
@@ -189,22 +257,62 @@ func (m Model) View() string {
 		sb.WriteString(" Fetching from remote...\n")
 
 	case PhaseUpToDate:
-		sb.WriteString(components.SuccessStyle.Render(components.GraphSuccess))
-		sb.WriteString(" Already up to date - no bookmarks to rebase.\n")
+		sb.WriteString(m.renderRebased())
+		if m.branchesDeleted {
+			sb.WriteString(components.MutedStyle.Render(fmt.Sprintf("%d branch(es) of merged or closed PRs deleted.", len(m.staleBranches))))
+			sb.WriteString("\n")
+		}
 
 	case PhaseRebasing:
 		sb.WriteString(fmt.Sprintf("Rebasing onto %s:\n\n", components.AccentStyle.Render(m.trunkName)))
 		sb.WriteString(m.renderBookmarks())
 		sb.WriteString("\n")
 
+	case PhaseFindingBranches:
+		sb.WriteString(m.renderRebased())
+		sb.WriteString(m.spinner.View())
+		sb.WriteString(" Looking for branches of merged pull requests...\n")
+
+	case PhaseConfirmDelete:
+		sb.WriteString(m.renderRebased())
+		sb.WriteString(m.renderStaleBranches())
+		fmt.Fprintf(&sb, "\n%d branch(es) and their bookmarks will be deleted.\n\n", len(m.staleBranches))
+		sb.WriteString(components.AccentStyle.Render(m.keys.Delete.Help().Key + " " + m.keys.Delete.Help().Desc))
+		sb.WriteString(components.MutedStyle.Render(" • " + m.keys.Keep.Help().Key + " " + m.keys.Keep.Help().Desc))
+		sb.WriteString(components.MutedStyle.Render(" • " + m.keys.Quit.Help().Key + " " + m.keys.Quit.Help().Desc))
+		sb.WriteString("\n")
+
+	case PhaseDeletingBranches:
+		sb.WriteString(m.renderRebased())
+		sb.WriteString(m.renderStaleBranches())
+		sb.WriteString("\n")
+		sb.WriteString(m.spinner.View())
+		sb.WriteString(" Deleting branches...\n")
+
+	case PhaseSubmitting:
+		sb.WriteString(fmt.Sprintf("Rebased onto %s:\n\n", components.AccentStyle.Render(m.trunkName)))
+		sb.WriteString(m.renderBookmarks())
+		sb.WriteString("\n")
+		item := m.bookmarks[m.submitIndex]
+		sb.WriteString(fmt.Sprintf("Submitting %s:\n\n", components.ChangeIDShortStyle.Render(item.Bookmark.ShortID)))
+		sb.WriteString(m.submit.View())
+
 	case PhaseComplete:
 		sb.WriteString(fmt.Sprintf("Rebased onto %s:\n\n", components.AccentStyle.Render(m.trunkName)))
 		sb.WriteString(m.renderBookmarks())
 		sb.WriteString("\n")
 		sb.WriteString(m.renderSummary())
 		sb.WriteString("\n")
+		if m.branchesDeleted {
+			sb.WriteString(components.MutedStyle.Render(fmt.Sprintf("%d branch(es) of merged or closed PRs deleted.", len(m.staleBranches))))
+			sb.WriteString("\n")
+		}
 
 	case PhaseError:
+		if m.submitOpts != nil && len(m.bookmarks) > 0 {
+			sb.WriteString(m.renderBookmarks())
+			sb.WriteString("\n")
+		}
 		sb.WriteString(components.ErrorStyle.Render(components.GraphError + " Sync failed"))
 		sb.WriteString("\n\n")
 		if m.err != nil {
@@ -302,7 +410,22 @@ func (m Model) renderBookmarkItem(item BookmarkItem, idWidth int) string {
 	case StateInProgress:
 		sb.WriteString(components.MutedStyle.Render("  Rebasing..."))
 	case StateSkipped:
-		sb.WriteString(components.MutedStyle.Render("  skipped (already in trunk)"))
+		if len(item.Merged) > 0 {
+			sb.WriteString(components.MutedStyle.Render("  " + mergedSummary(item.Merged)))
+		} else {
+			sb.WriteString(components.MutedStyle.Render("  skipped (already in trunk)"))
+		}
+	case StateSuccess:
+		var parts []string
+		if len(item.Merged) > 0 {
+			parts = append(parts, mergedSummary(item.Merged)+", rest rebased")
+		}
+		if summary := submittedSummary(item.PullRequests); summary != "" {
+			parts = append(parts, summary)
+		}
+		if len(parts) > 0 {
+			sb.WriteString(components.MutedStyle.Render("  " + strings.Join(parts, ", ")))
+		}
 	case StateConflict:
 		sb.WriteString(components.YellowStyle.Render("  conflict"))
 	case StateError:
@@ -310,10 +433,40 @@ func (m Model) renderBookmarkItem(item BookmarkItem, idWidth int) string {
 			sb.WriteString(components.ErrorStyle.Render("  " + item.Error.Error()))
 		}
 	}
+	if len(item.Diverged) > 0 && item.State != StateError {
+		sb.WriteString(components.YellowStyle.Render("  " + divergedSummary(item.Diverged)))
+	}
 
 	return sb.String()
 }
 
+// mergedSummary names the merged PRs of a stack, e.g. "merged as #3, #4"
+func mergedSummary(numbers []int) string {
+	refs := make([]string, len(numbers))
+	for i, n := range numbers {
+		refs[i] = fmt.Sprintf("#%d", n)
+	}
+	return "merged as " + strings.Join(refs, ", ")
+}
+
+// divergedSummary names the merged PRs of a stack whose revisions differ
+// from what was merged, e.g. "differs from merged #3, #4"
+func divergedSummary(numbers []int) string {
+	refs := make([]string, len(numbers))
+	for i, n := range numbers {
+		refs[i] = fmt.Sprintf("#%d", n)
+	}
+	return "differs from merged " + strings.Join(refs, ", ")
+}
+
+// renderRebased renders the rebased stacks, or notes that there were none
+func (m Model) renderRebased() string {
+	if len(m.bookmarks) == 0 {
+		return components.SuccessStyle.Render(components.GraphSuccess) + " Already up to date - no bookmarks to rebase.\n"
+	}
+	return fmt.Sprintf("Rebased onto %s:\n\n", components.AccentStyle.Render(m.trunkName)) + m.renderBookmarks() + "\n"
+}
+
 // renderSummary renders the completion summary
 func (m Model) renderSummary() string {
 	if m.conflictCount == 0 && m.skippedCount == 0 {
@@ -343,18 +496,18 @@ func (m Model) renderSummary() string {
 func (m Model) fetchCmd() tea.Cmd {
 	return func() tea.Msg {
 		// Fetch from remote
-		if err := jj.GitFetch(); err != nil {
+		if err := m.jj.GitFetch(m.cfg.FetchRemotes()...); err != nil {
 			return FetchCompleteMsg{Err: fmt.Errorf("git fetch: %w", err)}
 		}
 
 		// Get trunk name
-		trunkName, err := jj.GetTrunkName()
+		trunkName, err := m.jj.GetTrunkName()
 		if err != nil {
 			return FetchCompleteMsg{Err: fmt.Errorf("get trunk name: %w", err)}
 		}
 
 		// Get stack roots that need rebasing onto current trunk
-		bookmarks, err := jj.GetStackRootsToRebase()
+		bookmarks, err := m.jj.GetStackRootsToRebase()
 		if err != nil {
 			return FetchCompleteMsg{Err: err}
 		}
@@ -376,12 +529,114 @@ func (m Model) rebaseNextCmd() tea.Cmd {
 	changeID := item.Bookmark.ChangeID
 
 	return func() tea.Msg {
-		result, err := jj.Rebase(changeID, "trunk()")
-		return RebaseCompleteMsg{
+		if m.gh == nil {
+			result, err := m.jj.Rebase(changeID, "trunk()")
+			return RebaseCompleteMsg{
+				ChangeID:     changeID,
+				HasConflict:  result.HasConflict,
+				SkippedEmpty: result.SkippedEmpty,
+				Roots:        []string{changeID},
+				Err:          err,
+			}
+		}
+
+		merged, diverged, roots, err := m.abandonMerged(changeID)
+		if err != nil {
+			return RebaseCompleteMsg{ChangeID: changeID, Err: err}
+		}
+
+		// Nothing is left to rebase once the whole stack was merged
+		msg := RebaseCompleteMsg{
 			ChangeID:     changeID,
-			HasConflict:  result.HasConflict,
-			SkippedEmpty: result.SkippedEmpty,
-			Err:          err,
+			Merged:       merged,
+			Diverged:     diverged,
+			Roots:        roots,
+			SkippedEmpty: len(roots) == 0,
 		}
+		for _, root := range roots {
+			result, err := m.jj.Rebase(root, "trunk()")
+			if err != nil {
+				msg.Err = err
+				return msg
+			}
+			msg.HasConflict = msg.HasConflict || result.HasConflict
+		}
+		return msg
+	}
+}
+
+// abandonMerged abandons the revisions of the stack rooted at rootID whose PR
+// was merged into trunk. It returns the numbers of those PRs, those of merged
+// PRs whose revisions changed since and are kept, and the roots of what is
+// left of the stack.
+func (m Model) abandonMerged(rootID string) ([]int, []int, []string, error) {
+	changes, err := m.jj.GetChanges(fmt.Sprintf("%s:: & mutable()", rootID))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	branches := make([]string, len(changes))
+	for i, change := range changes {
+		branches[i] = change.GitPushBookmark
+	}
+	prs, err := m.gh.GetClosedPullRequestsForBranches(m.ctx, m.repo, m.headOwner(), branches)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// A PR merged into the branch of another PR isn't in trunk yet. One merged
+	// into trunk is done with if the revision is what was merged; a revision
+	// changed since is rebased instead, dropping it only if it became empty.
+	var merged, diverged []int
+	var abandon, remaining []string
+	for _, change := range changes {
+		pr := prs[change.GitPushBookmark]
+		if pr != nil && pr.MergedAt != nil && pr.GetBase().GetRef() == m.trunkName {
+			contained, err := m.mergedHeadContains(pr, change.CommitID)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if contained {
+				merged = append(merged, pr.GetNumber())
+				abandon = append(abandon, change.ID)
+				continue
+			}
+			diverged = append(diverged, pr.GetNumber())
+		}
+		remaining = append(remaining, fmt.Sprintf("change_id(%s)", change.ID))
+	}
+
+	if len(abandon) == 0 {
+		return nil, diverged, []string{rootID}, nil
+	}
+	if err := m.jj.Abandon(abandon...); err != nil {
+		return nil, nil, nil, err
+	}
+	if len(remaining) == 0 {
+		return merged, diverged, nil, nil
+	}
+
+	roots, err := m.jj.GetChanges(fmt.Sprintf("roots(%s)", strings.Join(remaining, " | ")))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ids := make([]string, len(roots))
+	for i, root := range roots {
+		ids[i] = root.ID
+	}
+	return merged, diverged, ids, nil
+}
+
+// mergedHeadContains reports whether commit is the head of the merged PR or
+// one of its ancestors. The head is only known locally if it was fetched.
+func (m Model) mergedHeadContains(pr *gogithub.PullRequest, commit string) (bool, error) {
+	head := pr.GetHead().GetSHA()
+	if head == "" || commit == head {
+		return commit == head, nil
+	}
+	changes, err := m.jj.GetChanges(fmt.Sprintf("%s & ::present(%s)", commit, head))
+	if err != nil {
+		return false, err
 	}
+	return len(changes) > 0, nil
 }
@@ -0,0 +1,352 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/github/githubtest"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/jj/jjtest"
+	"github.com/cbrewster/jj-github/internal/report"
+	"github.com/cbrewster/jj-github/internal/tui/submit"
+	tea "github.com/charmbracelet/bubbletea"
+	gogithub "github.com/google/go-github/v80/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTrunk = `{"id": "trunk", "short_id": "t", "commit_id": "t2", "immutable": true, "description": "", "bookmarks": [{"name": "main"}], "git_push_bookmark": "", "parents": []}`
+
+// expectLog scripts the jj invocations made by jj.Client.GetChanges.
+func expectLog(r *jjtest.Runner, revset, output string) {
+	r.Expect("\"push-\" ++ change_id.short()\n", "config", "get", "templates.git_push_bookmark")
+	r.ExpectResponse(jjtest.Response{
+		Args:   append(logArgs(), "-r", revset),
+		Output: output,
+	})
+}
+
+func logArgs() []string {
+	return []string{"log", "--no-graph", "--reversed", "-T", jj.LogTemplate(`"push-" ++ change_id.short()`)}
+}
+
+func root(id, parentCommit string) string {
+	return jjtest.Change(id, "c-"+id, "Change "+id, "old", parentCommit)
+}
+
+func TestRunHeadless(t *testing.T) {
+	r := jjtest.NewRunner()
+	r.Expect("", "git", "fetch")
+	expectLog(r, "trunk()", testTrunk)
+	expectLog(r, "trunk()", testTrunk)
+	expectLog(r, "roots(mutable())", root("aaaa", "t1")+root("bbbb", "t1")+root("cccc", "t2"))
+	r.Expect("Rebased 2 commits\n", "rebase", "-s", "aaaa", "-d", "trunk()", "--skip-emptied")
+	r.Expect("Skipped rebase of 1 commits that became empty\n", "rebase", "-s", "bbbb", "-d", "trunk()", "--skip-emptied")
+
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), config.Default()), io.Discard)
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+
+	assert.Equal(t, PhaseComplete, m.phase)
+	require.Len(t, m.bookmarks, 2, "roots already on trunk are not rebased")
+	assert.Equal(t, StateSuccess, m.bookmarks[0].State)
+	assert.Equal(t, StateSkipped, m.bookmarks[1].State)
+}
+
+func TestRunHeadlessMergedPullRequests(t *testing.T) {
+	repo := githubtest.DefaultRepo
+	server := githubtest.NewServer(t)
+	server.SetBranch(repo, "main", "t2")
+	mergePR := func(branch, sha string) int {
+		pr := server.OpenPullRequest(repo, branch, "main", sha, nil)
+		server.MergePullRequest(repo, pr.GetNumber())
+		return pr.GetNumber()
+	}
+	merged := mergePR("push-aaaa", "c-aaaa")
+	edited := mergePR("push-dddd", "c-edited") // A suggestion was committed on GitHub
+	server.OpenPullRequest(repo, "push-eeee", "push-bbbb", "c-eeee", nil)
+	server.MergePullRequest(repo, 3)          // Into its parent's branch, not trunk
+	amended := mergePR("push-ffff", "c-ffff") // Amended locally since
+
+	child := `{"id": "bbbb", "short_id": "b", "commit_id": "c-bbbb", "immutable": false, "description": "Change bbbb", "bookmarks": [], "git_push_bookmark": "push-bbbb", "parents": [{"change_id": "aaaa", "commit_id": "c-aaaa"}]}`
+	grandchild := `{"id": "eeee", "short_id": "e", "commit_id": "c-eeee", "immutable": false, "description": "Change eeee", "bookmarks": [], "git_push_bookmark": "push-eeee", "parents": [{"change_id": "bbbb", "commit_id": "c-bbbb"}]}`
+
+	r := jjtest.NewRunner()
+	r.Expect("", "git", "fetch")
+	expectLog(r, "trunk()", testTrunk)
+	expectLog(r, "trunk()", testTrunk)
+	expectLog(r, "roots(mutable())", root("aaaa", "t1")+root("dddd", "t1")+root("ffff", "t1"))
+	expectLog(r, "aaaa:: & mutable()", root("aaaa", "t1")+child+grandchild)
+	r.Expect("", "abandon", "aaaa")
+	expectLog(r, "roots(change_id(bbbb) | change_id(eeee))", child)
+	r.Expect("Rebased 2 commits\n", "rebase", "-s", "bbbb", "-d", "trunk()", "--skip-emptied")
+	expectLog(r, "dddd:: & mutable()", root("dddd", "t1"))
+	expectLog(r, "c-dddd & ::present(c-edited)", root("dddd", "t1"))
+	r.Expect("", "abandon", "dddd")
+	amendedRoot := strings.Replace(root("ffff", "t1"), "c-ffff", "c-ffff2", 1)
+	expectLog(r, "ffff:: & mutable()", amendedRoot)
+	expectLog(r, "c-ffff2 & ::present(c-ffff)", "")
+	r.Expect("Rebased 1 commits\n", "rebase", "-s", "ffff", "-d", "trunk()", "--skip-emptied")
+
+	cfg := config.Default()
+	cfg.DeleteBranches = config.DeleteBranchesNever
+	model := NewModel(t.Context(), jj.NewClientWithRunner(r), cfg).WithGitHub(server.Client(t), repo, repo)
+	var out strings.Builder
+	m, err := RunHeadless(model, &out)
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+
+	require.Len(t, m.bookmarks, 3)
+	assert.Equal(t, StateSuccess, m.bookmarks[0].State)
+	assert.Equal(t, []int{merged}, m.bookmarks[0].Merged)
+	assert.Equal(t, StateSkipped, m.bookmarks[1].State)
+	assert.Equal(t, []int{edited}, m.bookmarks[1].Merged)
+	assert.Contains(t, out.String(), fmt.Sprintf("a: merged as #%d, rest rebased onto main\n", merged))
+	assert.Contains(t, out.String(), fmt.Sprintf("d: merged as #%d\n", edited))
+	assert.Equal(t, StateSuccess, m.bookmarks[2].State)
+	assert.Empty(t, m.bookmarks[2].Merged)
+	assert.Equal(t, []int{amended}, m.bookmarks[2].Diverged)
+	assert.Contains(t, out.String(), fmt.Sprintf("f: differs from merged #%d, rebased instead of abandoned\n", amended))
+}
+
+func TestRunHeadlessSubmit(t *testing.T) {
+	repo := githubtest.DefaultRepo
+	server := githubtest.NewServer(t)
+	server.SetBranch(repo, "main", "t2")
+	addPR := func(branch, base string) int {
+		sha := "c-" + strings.TrimPrefix(branch, "push-")
+		return server.OpenPullRequest(repo, branch, base, sha, nil).GetNumber()
+	}
+	merged := addPR("push-aaaa", "main")
+	server.MergePullRequest(repo, merged)
+	child := addPR("push-bbbb", "push-aaaa")
+	conflicted := addPR("push-dddd", "main")
+
+	oldChild := `{"id": "bbbb", "short_id": "b", "commit_id": "c-bbbb", "immutable": false, "description": "Change bbbb", "bookmarks": [], "git_push_bookmark": "push-bbbb", "parents": [{"change_id": "aaaa", "commit_id": "c-aaaa"}]}`
+	rebasedChild := `{"id": "bbbb", "short_id": "b", "commit_id": "c-bbbb2", "immutable": false, "description": "Change bbbb", "bookmarks": [], "git_push_bookmark": "push-bbbb", "parents": [{"change_id": "trunk", "commit_id": "t2"}]}`
+	oldTop := `{"id": "eeee", "short_id": "e", "commit_id": "c-eeee", "immutable": false, "description": "Change eeee", "bookmarks": [], "git_push_bookmark": "push-eeee", "parents": [{"change_id": "bbbb", "commit_id": "c-bbbb"}]}`
+	rebasedTop := `{"id": "eeee", "short_id": "e", "commit_id": "c-eeee2", "immutable": false, "description": "Change eeee", "bookmarks": [], "git_push_bookmark": "push-eeee", "parents": [{"change_id": "bbbb", "commit_id": "c-bbbb2"}]}`
+	rebasedLone := `{"id": "cccc", "short_id": "c", "commit_id": "c-cccc2", "immutable": false, "description": "Change cccc", "bookmarks": [], "git_push_bookmark": "push-cccc", "parents": [{"change_id": "trunk", "commit_id": "t2"}]}`
+
+	r := jjtest.NewRunner()
+	r.Expect("", "git", "fetch")
+	expectLog(r, "trunk()", testTrunk)
+	expectLog(r, "trunk()", testTrunk)
+	expectLog(r, "roots(mutable())", root("aaaa", "t1")+root("cccc", "t1")+root("dddd", "t1"))
+
+	expectLog(r, "aaaa:: & mutable()", root("aaaa", "t1")+oldChild+oldTop)
+	r.Expect("", "abandon", "aaaa")
+	expectLog(r, "roots(change_id(bbbb) | change_id(eeee))", oldChild)
+	r.Expect("Rebased 1 commits\n", "rebase", "-s", "bbbb", "-d", "trunk()", "--skip-emptied")
+	expectLog(r, "cccc:: & mutable()", root("cccc", "t1"))
+	r.Expect("Rebased 1 commits\n", "rebase", "-s", "cccc", "-d", "trunk()", "--skip-emptied")
+	expectLog(r, "dddd:: & mutable()", root("dddd", "t1"))
+	r.Expect("New conflicts appeared in 1 commits\n", "rebase", "-s", "dddd", "-d", "trunk()", "--skip-emptied")
+
+	// The stack left of aaaa is resubmitted onto main; eeee has no PR, so
+	// none is opened for it
+	expectSubmitLoad(r, "(change_id(bbbb))::", testTrunk+rebasedChild+rebasedTop)
+	expectLog(r, "::c-bbbb ~ ::(c-bbbb2 | trunk())", "")
+	r.Expect("", "git", "push", "--remote", "origin", "-c", "change_id(bbbb)")
+
+	// cccc has no PRs, so it is left alone
+	expectSubmitLoad(r, "(change_id(cccc))::", testTrunk+rebasedLone)
+
+	cfg := config.Default()
+	cfg.DeleteBranches = config.DeleteBranchesNever
+	model := NewModel(t.Context(), jj.NewClientWithRunner(r), cfg).
+		WithGitHub(server.Client(t), repo, repo).
+		WithSubmit(submit.Options{Repo: repo, Config: cfg})
+	var out strings.Builder
+	m, err := RunHeadless(model, &out)
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.Equal(t, PhaseComplete, m.phase)
+
+	require.Len(t, m.bookmarks, 3)
+	require.Len(t, m.bookmarks[0].PullRequests, 1)
+	assert.Equal(t, child, m.bookmarks[0].PullRequests[0].PRNumber)
+	assert.Equal(t, report.ActionUpdated, m.bookmarks[0].PullRequests[0].Action)
+	assert.Empty(t, m.bookmarks[1].PullRequests)
+	assert.Equal(t, StateConflict, m.bookmarks[2].State)
+	assert.Contains(t, out.String(), fmt.Sprintf("a: PR #%d: updated\n", child))
+
+	prs := server.PullRequests(repo)
+	assert.Len(t, prs, 3, "no PRs are opened")
+	assert.Equal(t, "main", prs[child-1].GetBase().GetRef(), "its parent was merged")
+	assert.Len(t, server.Comments(repo, child), 1)
+	assert.Empty(t, server.Comments(repo, conflicted), "conflicted stacks aren't submitted")
+}
+
+func TestSubmitWaitsForConfirmation(t *testing.T) {
+	repo := githubtest.DefaultRepo
+	server := githubtest.NewServer(t)
+	server.SetBranch(repo, "main", "t2")
+	server.OpenPullRequest(repo, "push-bbbb", "main", "c-bbbb", nil)
+
+	r := jjtest.NewRunner()
+	expectSubmitLoad(r, "(change_id(bbbb))::", testTrunk+jjtest.Change("bbbb", "c-bbbb2", "Change bbbb", "trunk", "t2"))
+	expectLog(r, "::c-bbbb ~ ::(c-bbbb2 | trunk())", "")
+
+	model := NewModel(t.Context(), jj.NewClientWithRunner(r), config.Default()).
+		WithGitHub(server.Client(t), repo, repo).
+		WithSubmit(submit.Options{Repo: repo, Config: config.Default()})
+	model.bookmarks = []BookmarkItem{{Bookmark: jj.Bookmark{ChangeID: "bbbbbbbb", ShortID: "b"}, State: StateSuccess, Roots: []string{"bbbb"}}}
+	m, cmd := model.nextSubmit()
+
+	// Load the plan, leaving the spinner alone
+	for _, cmd := range cmd().(tea.BatchMsg) {
+		if msg, ok := cmd().(submit.RevisionsLoadedMsg); ok {
+			next, _ := m.Update(msg)
+			m = next.(Model)
+		}
+	}
+	require.NoError(t, m.submit.Err())
+	assert.Empty(t, r.Unused())
+	assert.Equal(t, PhaseSubmitting, m.phase)
+	assert.Equal(t, submit.PhaseConfirmation, m.submit.Phase(), "nothing is pushed before the user submits")
+	assert.Contains(t, m.View(), "1 revision(s) will be synced to GitHub")
+
+	r.Expect("", "git", "push", "--remote", "origin", "-c", "change_id(bbbb)")
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(Model)
+	assert.Equal(t, submit.PhaseSyncing, m.submit.Phase())
+	require.NotNil(t, cmd)
+	cmd()
+	assert.Empty(t, r.Unused(), "submitting pushes")
+}
+
+func TestRunHeadlessDeletesBranches(t *testing.T) {
+	repo := githubtest.DefaultRepo
+	server := githubtest.NewServer(t)
+	server.SetBranch(repo, "main", "t2")
+	addPR := func(branch, user string) int {
+		sha := "c-" + strings.TrimPrefix(branch, "push-")
+		return server.OpenPullRequest(repo, branch, "main", sha, &gogithub.PullRequest{
+			User: &gogithub.User{Login: gogithub.Ptr(user)},
+		}).GetNumber()
+	}
+	client := server.Client(t)
+	me := githubtest.DefaultUser
+	merged := addPR("push-aaaa", me)
+	server.MergePullRequest(repo, merged)
+	closed := addPR("push-cccc", me)
+	require.NoError(t, client.ClosePullRequest(t.Context(), repo, closed))
+	server.MergePullRequest(repo, addPR("push-eeee", "alice"))
+	require.NoError(t, client.ClosePullRequest(t.Context(), repo, addPR("push-ffff", me)))
+	addPR("push-ffff", me) // Reopened as a new PR
+	trunkMerged := addPR("push-gggg", me)
+	server.MergePullRequest(repo, trunkMerged) // With a merge commit, so its bookmark is in trunk
+	server.MergePullRequest(repo, addPR("push-hhhh", me))
+	server.MergePullRequest(repo, addPR("push-iiii", me))
+
+	// aaaa is only kept by its remote bookmark once abandoned
+	bookmarked := func(id string, local bool) string {
+		bookmarks := "[]"
+		if local {
+			bookmarks = fmt.Sprintf(`[{"name": "push-%s"}]`, id)
+		}
+		return fmt.Sprintf(`{"id": %q, "short_id": %q, "commit_id": "c-%s", "immutable": false, "description": "Change %s", "bookmarks": %s, "remote_bookmarks": [{"name": "push-%s", "remote": "origin"}], "git_push_bookmark": "push-%s", "parents": [{"change_id": "trunk", "commit_id": "t2"}]}`,
+			id, id[:1], id, id, bookmarks, id, id)
+	}
+	inTrunk := strings.Replace(bookmarked("gggg", true), `"immutable": false`, `"immutable": true`, 1)
+	moved := strings.Replace(bookmarked("hhhh", true), `"commit_id": "c-hhhh"`, `"commit_id": "c-hhhh2"`, 1)
+	pushedTo := strings.Replace(bookmarked("iiii", false), `"commit_id": "c-iiii"`, `"commit_id": "c-iiii2"`, 1)
+	expectSync := func(r *jjtest.Runner) {
+		r.Expect("", "git", "fetch")
+		expectLog(r, "trunk()", testTrunk)
+		expectLog(r, "trunk()", testTrunk)
+		expectLog(r, "roots(mutable())", root("aaaa", "t1"))
+		expectLog(r, "aaaa:: & mutable()", root("aaaa", "t1"))
+		r.Expect("", "abandon", "aaaa")
+		expectLog(r, `bookmarks() | tracked_remote_bookmarks(remote=exact:"origin")`,
+			bookmarked("aaaa", false)+bookmarked("cccc", true)+bookmarked("eeee", true)+bookmarked("ffff", true)+inTrunk+moved+pushedTo)
+	}
+
+	// Without anyone to ask, the branches stay
+	r := jjtest.NewRunner()
+	expectSync(r)
+	var out strings.Builder
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), config.Default()).WithGitHub(client, repo, repo), &out)
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.Equal(t, PhaseComplete, m.phase)
+	assert.Contains(t, out.String(), "3 branch(es) of merged or closed PRs kept")
+	assert.True(t, server.HasBranch(repo, "push-aaaa"))
+
+	r = jjtest.NewRunner()
+	expectSync(r)
+	r.Expect("", "bookmark", "delete", "exact:push-cccc", "exact:push-gggg")
+	r.Expect("", "git", "fetch")
+	cfg := config.Default()
+	cfg.DeleteBranches = config.DeleteBranchesAlways
+	out.Reset()
+	m, err = RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), cfg).WithGitHub(client, repo, repo), &out)
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.Equal(t, []string{"push-aaaa", "push-cccc", "push-gggg"}, m.Report().DeletedBranches)
+	assert.Contains(t, out.String(), fmt.Sprintf("push-aaaa: deleted (PR #%d merged)\n", merged))
+	assert.Contains(t, out.String(), fmt.Sprintf("push-cccc: deleted (PR #%d closed)\n", closed))
+
+	assert.False(t, server.HasBranch(repo, "push-aaaa"))
+	assert.False(t, server.HasBranch(repo, "push-cccc"))
+	assert.True(t, server.HasBranch(repo, "push-eeee"), "PRs of other users are left alone")
+	assert.True(t, server.HasBranch(repo, "push-ffff"), "the branch has an open PR again")
+	assert.False(t, server.HasBranch(repo, "push-gggg"))
+	assert.True(t, server.HasBranch(repo, "push-hhhh"), "the bookmark moved since the PR was merged")
+	assert.True(t, server.HasBranch(repo, "push-iiii"), "the branch was pushed to since the PR was merged")
+}
+
+func TestRunHeadlessUpToDateDeletesBranches(t *testing.T) {
+	repo := githubtest.DefaultRepo
+	server := githubtest.NewServer(t)
+	server.SetBranch(repo, "main", "t2")
+	pr := server.OpenPullRequest(repo, "push-aaaa", "main", "c-aaaa", nil)
+	server.MergePullRequest(repo, pr.GetNumber())
+
+	r := jjtest.NewRunner()
+	r.Expect("", "git", "fetch")
+	expectLog(r, "trunk()", testTrunk)
+	expectLog(r, "trunk()", testTrunk)
+	expectLog(r, "roots(mutable())", "")
+	expectLog(r, `bookmarks() | tracked_remote_bookmarks(remote=exact:"origin")`,
+		`{"id": "aaaa", "short_id": "a", "commit_id": "c-aaaa", "immutable": true, "description": "Change aaaa", "bookmarks": [{"name": "push-aaaa"}], "remote_bookmarks": [{"name": "push-aaaa", "remote": "origin"}], "git_push_bookmark": "push-aaaa", "parents": [{"change_id": "trunk", "commit_id": "t1"}]}`)
+	r.Expect("", "bookmark", "delete", "exact:push-aaaa")
+	r.Expect("", "git", "fetch")
+
+	cfg := config.Default()
+	cfg.DeleteBranches = config.DeleteBranchesAlways
+	var out strings.Builder
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), cfg).WithGitHub(server.Client(t), repo, repo), &out)
+	require.NoError(t, err)
+	assert.Empty(t, r.Unused())
+	assert.Equal(t, PhaseUpToDate, m.phase)
+	assert.Contains(t, out.String(), fmt.Sprintf("push-aaaa: deleted (PR #%d merged)\n", pr.GetNumber()))
+	assert.False(t, server.HasBranch(repo, "push-aaaa"))
+}
+
+// expectSubmitLoad scripts the jj invocations made by submit while loading
+// the stack for revset, without fetching.
+func expectSubmitLoad(r *jjtest.Runner, revset, output string) {
+	expectLog(r, jj.StackRevset(revset), output)
+	expectLog(r, "trunk()", testTrunk)
+	r.Expect("", "file", "list", "-r", "trunk()", "--",
+		`root-glob-i:".github/pull_request_template.md"`,
+		`root-glob-i:"pull_request_template.md"`,
+		`root-glob-i:"docs/pull_request_template.md"`,
+		`root-glob-i:".github/pull_request_template/*.md"`,
+		`root-glob-i:"pull_request_template/*.md"`,
+		`root-glob-i:"docs/pull_request_template/*.md"`)
+}
+
+func TestRunHeadlessFetchError(t *testing.T) {
+	r := jjtest.NewRunner()
+	r.ExpectError("", jjtest.ExitError("no remotes"), "git", "fetch")
+
+	m, err := RunHeadless(NewModel(t.Context(), jj.NewClientWithRunner(r), config.Default()), io.Discard)
+	require.Error(t, err)
+	assert.Equal(t, PhaseError, m.phase)
+}
@@ -0,0 +1,226 @@
+package sync
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/tui/components"
+	tea "github.com/charmbracelet/bubbletea"
+	gogithub "github.com/google/go-github/v80/github"
+)
+
+// StaleBranch is a branch whose pull request was merged or closed
+type StaleBranch struct {
+	Name   string
+	PR     *gogithub.PullRequest
+	Local  bool // Whether there is a local bookmark to delete
+	Remote bool // Whether the branch is still on the push remote
+}
+
+// Messages for cleaning up branches
+type (
+	StaleBranchesMsg struct {
+		Branches []StaleBranch
+		Err      error
+	}
+
+	BranchesDeletedMsg struct {
+		Err error
+	}
+)
+
+// findBranches looks for the branches of merged or closed PRs once all stacks
+// are rebased, or none needed rebasing, unless configured not to
+func (m Model) findBranches() (Model, tea.Cmd) {
+	m.submitIndex = 0
+	if m.gh == nil || m.cfg.DeleteBranches == config.DeleteBranchesNever {
+		return m.nextSubmit()
+	}
+	m.phase = PhaseFindingBranches
+	return m, m.findBranchesCmd()
+}
+
+// updateBranches handles the messages of the branch cleanup phases
+func (m Model) updateBranches(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case StaleBranchesMsg:
+		if msg.Err != nil {
+			m.phase = PhaseError
+			m.err = msg.Err
+			return m, tea.Quit
+		}
+
+		m.staleBranches = msg.Branches
+		if len(m.staleBranches) == 0 {
+			return m.nextSubmit()
+		}
+		if m.cfg.DeleteBranches == config.DeleteBranchesAsk {
+			m.phase = PhaseConfirmDelete
+			return m, nil
+		}
+		return m.deleteBranches()
+
+	case BranchesDeletedMsg:
+		if msg.Err != nil {
+			m.phase = PhaseError
+			m.err = msg.Err
+			return m, tea.Quit
+		}
+		m.branchesDeleted = true
+		return m.nextSubmit()
+	}
+	return m, nil
+}
+
+// deleteBranches leaves the confirmation phase and deletes the stale branches
+func (m Model) deleteBranches() (Model, tea.Cmd) {
+	m.phase = PhaseDeletingBranches
+	return m, m.deleteBranchesCmd()
+}
+
+// keepBranches leaves the confirmation phase without deleting anything
+func (m Model) keepBranches() (Model, tea.Cmd) {
+	return m.nextSubmit()
+}
+
+// renderStaleBranches lists the stale branches with their PRs
+func (m Model) renderStaleBranches() string {
+	width := 0
+	for _, branch := range m.staleBranches {
+		width = max(width, len(branch.Name))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Branches of merged or closed pull requests:\n\n")
+	for _, branch := range m.staleBranches {
+		fmt.Fprintf(&sb, "  %-*s %s\n", width, branch.Name,
+			components.MutedStyle.Render(fmt.Sprintf("#%d %s", branch.PR.GetNumber(), prOutcome(branch.PR))))
+	}
+	return sb.String()
+}
+
+// prOutcome tells whether a closed PR was merged
+func prOutcome(pr *gogithub.PullRequest) string {
+	if pr.MergedAt != nil {
+		return "merged"
+	}
+	return "closed"
+}
+
+func (m Model) findBranchesCmd() tea.Cmd {
+	return func() tea.Msg {
+		// Bookmarks pushed by jj track their remote branch. Merged revisions
+		// were abandoned, which deleted their local bookmark, but the remote
+		// branch is still tracked. PRs merged with a merge commit or rebased
+		// leave their bookmarks in trunk, so immutable ones count too.
+		remote := m.cfg.PushRemoteOrDefault()
+		changes, err := m.jj.GetChanges(fmt.Sprintf("bookmarks() | tracked_remote_bookmarks(remote=exact:%q)", remote))
+		if err != nil {
+			return StaleBranchesMsg{Err: err}
+		}
+
+		local := make(map[string]string)   // Commit of each local bookmark
+		remotes := make(map[string]string) // Commit of each branch on the remote
+		for _, change := range changes {
+			for _, b := range change.Bookmarks {
+				if !strings.Contains(b.Name, "@") {
+					local[b.Name] = change.CommitID
+				}
+			}
+			for _, b := range change.RemoteBookmarks {
+				if b.Remote == remote {
+					remotes[b.Name] = change.CommitID
+				}
+			}
+		}
+
+		var names []string
+		for name := range local {
+			names = append(names, name)
+		}
+		for name := range remotes {
+			if _, ok := local[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		names = slices.DeleteFunc(names, func(name string) bool { return name == m.trunkName })
+		if len(names) == 0 {
+			return StaleBranchesMsg{}
+		}
+		slices.Sort(names)
+
+		login, err := m.gh.CurrentUser(m.ctx)
+		if err != nil {
+			return StaleBranchesMsg{Err: fmt.Errorf("get current user: %w", err)}
+		}
+		closed, err := m.gh.GetClosedPullRequestsForBranches(m.ctx, m.repo, m.headOwner(), names)
+		if err != nil {
+			return StaleBranchesMsg{Err: err}
+		}
+
+		// A branch may have been reused for a new PR
+		var reused []string
+		for _, name := range names {
+			if closed[name] != nil {
+				reused = append(reused, name)
+			}
+		}
+		open, err := m.gh.GetPullRequestsForBranches(m.ctx, m.repo, m.headOwner(), reused)
+		if err != nil {
+			return StaleBranchesMsg{Err: err}
+		}
+
+		var stale []StaleBranch
+		for _, name := range names {
+			pr := closed[name]
+			if pr == nil || open[name] != nil || pr.GetUser().GetLogin() != login {
+				continue
+			}
+
+			// A bookmark or branch moved on since its PR was merged or closed
+			// holds new work
+			commit, isLocal := local[name]
+			if isLocal && commit != pr.GetHead().GetSHA() {
+				continue
+			}
+			remoteCommit, isRemote := remotes[name]
+			if isRemote && remoteCommit != pr.GetHead().GetSHA() {
+				continue
+			}
+			stale = append(stale, StaleBranch{Name: name, PR: pr, Local: isLocal, Remote: isRemote})
+		}
+
+		return StaleBranchesMsg{Branches: stale}
+	}
+}
+
+// deleteBranchesCmd deletes the stale branches on GitHub and their local
+// bookmarks, then fetches so jj forgets the remote bookmarks too
+func (m Model) deleteBranchesCmd() tea.Cmd {
+	return func() tea.Msg {
+		var local []string
+		for _, branch := range m.staleBranches {
+			if branch.Local {
+				local = append(local, branch.Name)
+			}
+			if !branch.Remote {
+				continue
+			}
+			if err := m.gh.DeleteBranch(m.ctx, m.headRepo, branch.Name); err != nil {
+				return BranchesDeletedMsg{Err: fmt.Errorf("delete branch %s: %w", branch.Name, err)}
+			}
+		}
+
+		if len(local) > 0 {
+			if err := m.jj.DeleteBookmarks(local...); err != nil {
+				return BranchesDeletedMsg{Err: err}
+			}
+		}
+		if err := m.jj.GitFetch(m.cfg.FetchRemotes()...); err != nil {
+			return BranchesDeletedMsg{Err: fmt.Errorf("git fetch: %w", err)}
+		}
+		return BranchesDeletedMsg{}
+	}
+}
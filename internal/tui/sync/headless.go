@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// RunHeadless drives the sync workflow without a bubbletea program,
+// writing one line of progress per rebased stack to w. Stacks are rebased one
+// at a time; the PRs of a resubmitted stack are synced concurrently, and their
+// messages are handled as they arrive.
+// Returns the final model and the workflow error, if any.
+func RunHeadless(m Model, w io.Writer) (Model, error) {
+	fmt.Fprintln(w, "Fetching from remote...")
+	m.headless = true
+
+	msgs := make(chan tea.Msg)
+	running := 0
+	run := func(cmd tea.Cmd) {
+		if cmd == nil {
+			return
+		}
+		running++
+		go func() { msgs <- cmd() }()
+	}
+
+	run(m.fetchCmd())
+	for running > 0 {
+		msg := <-msgs
+		running--
+
+		switch msg := msg.(type) {
+		case tea.QuitMsg, spinner.TickMsg:
+			// Nothing is drawn, so spinners don't need to tick
+			continue
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				run(cmd)
+			}
+			continue
+		}
+
+		submitted := m.submitIndex
+		next, cmd := m.Update(msg)
+		m = next.(Model)
+		run(cmd)
+
+		m.logProgress(w, msg)
+		for i := submitted; i < m.submitIndex; i++ {
+			m.logSubmitted(w, m.bookmarks[i])
+		}
+
+		// There is no one to ask, so the branches stay
+		if m.phase == PhaseConfirmDelete {
+			fmt.Fprintf(w, "%d branch(es) of merged or closed PRs kept; set jj-github.delete-branches to \"always\" to delete them.\n",
+				len(m.staleBranches))
+			m, cmd = m.keepBranches()
+			run(cmd)
+		}
+	}
+
+	switch m.phase {
+	case PhaseError:
+		return m, m.err
+	case PhaseUpToDate:
+		fmt.Fprintln(w, "Already up to date - no bookmarks to rebase.")
+	}
+
+	return m, nil
+}
+
+// logProgress writes a single line describing the result of msg
+func (m Model) logProgress(w io.Writer, msg tea.Msg) {
+	if _, ok := msg.(BranchesDeletedMsg); ok && m.branchesDeleted {
+		for _, branch := range m.staleBranches {
+			fmt.Fprintf(w, "%s: deleted (PR #%d %s)\n", branch.Name, branch.PR.GetNumber(), prOutcome(branch.PR))
+		}
+		return
+	}
+
+	msgRebase, ok := msg.(RebaseCompleteMsg)
+	if !ok {
+		return
+	}
+
+	for _, item := range m.bookmarks {
+		if item.Bookmark.ChangeID != msgRebase.ChangeID {
+			continue
+		}
+		if len(item.Diverged) > 0 && item.State != StateError {
+			fmt.Fprintf(w, "%s: %s, rebased instead of abandoned\n", item.Bookmark.ShortID, divergedSummary(item.Diverged))
+		}
+
+		switch item.State {
+		case StateSuccess:
+			if len(item.Merged) > 0 {
+				fmt.Fprintf(w, "%s: %s, rest rebased onto %s\n", item.Bookmark.ShortID, mergedSummary(item.Merged), m.trunkName)
+				return
+			}
+			fmt.Fprintf(w, "%s: rebased onto %s\n", item.Bookmark.ShortID, m.trunkName)
+		case StateSkipped:
+			if len(item.Merged) > 0 {
+				fmt.Fprintf(w, "%s: %s\n", item.Bookmark.ShortID, mergedSummary(item.Merged))
+				return
+			}
+			fmt.Fprintf(w, "%s: skipped (already in trunk)\n", item.Bookmark.ShortID)
+		case StateConflict:
+			fmt.Fprintf(w, "%s: conflict\n", item.Bookmark.ShortID)
+		case StateError:
+			fmt.Fprintf(w, "%s: %v\n", item.Bookmark.ShortID, item.Error)
+		}
+		return
+	}
+}
+
+// logSubmitted writes a line for each PR of a resubmitted stack
+func (m Model) logSubmitted(w io.Writer, item BookmarkItem) {
+	for _, pr := range item.PullRequests {
+		if pr.PRNumber != 0 {
+			fmt.Fprintf(w, "%s: PR #%d: %s\n", item.Bookmark.ShortID, pr.PRNumber, pr.Action)
+		}
+	}
+}
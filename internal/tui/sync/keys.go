@@ -4,12 +4,24 @@ import "github.com/charmbracelet/bubbles/key"
 
 // KeyMap defines the key bindings for the sync TUI
 type KeyMap struct {
+	// Answers to deleting the branches of merged or closed PRs
+	Delete key.Binding
+	Keep   key.Binding
+
 	Quit key.Binding
 }
 
 // DefaultKeyMap returns the default key bindings
 func DefaultKeyMap() KeyMap {
 	return KeyMap{
+		Delete: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "delete branches"),
+		),
+		Keep: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "keep them"),
+		),
 		Quit: key.NewBinding(
 			key.WithKeys("q", "ctrl+c"),
 			key.WithHelp("q", "quit"),
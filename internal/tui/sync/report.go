@@ -0,0 +1,51 @@
+package sync
+
+import "github.com/cbrewster/jj-github/internal/report"
+
+// Report summarizes the outcome of the workflow for machine-readable output
+func (m Model) Report() report.SyncReport {
+	r := report.NewSyncReport()
+	r.Trunk = m.trunkName
+	if m.err != nil {
+		r.Error = m.err.Error()
+	}
+	if m.branchesDeleted {
+		for _, branch := range m.staleBranches {
+			r.DeletedBranches = append(r.DeletedBranches, branch.Name)
+		}
+	}
+
+	for _, item := range m.bookmarks {
+		entry := report.SyncStack{
+			ChangeID:     item.Bookmark.ChangeID,
+			CommitID:     item.Bookmark.CommitID,
+			Bookmark:     item.Bookmark.Name,
+			State:        stackState(item.State),
+			Merged:       item.Merged,
+			Diverged:     item.Diverged,
+			PullRequests: item.PullRequests,
+		}
+		if item.Error != nil {
+			entry.Error = item.Error.Error()
+		}
+		r.Stacks = append(r.Stacks, entry)
+	}
+
+	return r
+}
+
+// stackState maps a bookmark state to its report representation
+func stackState(state BookmarkState) report.StackState {
+	switch state {
+	case StateSuccess:
+		return report.StackRebased
+	case StateSkipped:
+		return report.StackSkipped
+	case StateConflict:
+		return report.StackConflict
+	case StateError:
+		return report.StackFailed
+	default:
+		return report.StackPending
+	}
+}
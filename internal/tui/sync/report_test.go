@@ -0,0 +1,30 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cbrewster/jj-github/internal/config"
+	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/report"
+	"github.com/cbrewster/jj-github/internal/report/reporttest"
+)
+
+func TestReportGolden(t *testing.T) {
+	m := NewModel(t.Context(), nil, config.Default())
+	m.phase = PhaseComplete
+	m.trunkName = "main"
+	m.bookmarks = []BookmarkItem{
+		{Bookmark: jj.Bookmark{Name: "feature", ChangeID: "aaaaaaaa", CommitID: "c1"}, State: StateSuccess, Merged: []int{7},
+			PullRequests: []report.SubmitRevision{
+				{ChangeID: "eeeeeeee", CommitID: "c5", Branch: "push-eeeeeeee", Base: "main", PRNumber: 8, Action: report.ActionUpdated},
+			}},
+		{Bookmark: jj.Bookmark{ChangeID: "bbbbbbbb", CommitID: "c2"}, State: StateSkipped},
+		{Bookmark: jj.Bookmark{Name: "push-cccccccc", ChangeID: "cccccccc", CommitID: "c3"}, State: StateConflict, Diverged: []int{6}},
+		{Bookmark: jj.Bookmark{ChangeID: "dddddddd", CommitID: "c4"}, State: StateError, Error: errors.New("rebase: exit status 1")},
+	}
+	m.staleBranches = []StaleBranch{{Name: "push-ffffffff", Remote: true}}
+	m.branchesDeleted = true
+
+	reporttest.AssertGolden(t, "report.golden", m.Report())
+}
@@ -0,0 +1,114 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cbrewster/jj-github/internal/report"
+	"github.com/cbrewster/jj-github/internal/tui/submit"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WithSubmit returns a copy of the model that resubmits each rebased stack
+// with open PRs once all stacks are rebased, so the PRs get the new commits,
+// the bases of PRs whose parent was merged move to trunk and the stack
+// comments are updated. Conflicted stacks are left alone. opts configures the
+// submits; its revset is replaced by each stack's. It needs WithGitHub.
+// Interactively the plan of each stack is shown for confirmation; RunHeadless
+// submits without asking.
+func (m Model) WithSubmit(opts submit.Options) Model {
+	opts.ExistingOnly = true
+	opts.NoFetch = true
+	m.submitOpts = &opts
+	return m
+}
+
+// nextSubmit starts submitting the next rebased stack from submitIndex on,
+// completing the workflow once there are none left, or leaving it up to date
+// if nothing was rebased
+func (m Model) nextSubmit() (Model, tea.Cmd) {
+	for m.submitOpts != nil && m.submitIndex < len(m.bookmarks) {
+		item := m.bookmarks[m.submitIndex]
+		if item.State != StateSuccess {
+			m.submitIndex++
+			continue
+		}
+
+		opts := *m.submitOpts
+		opts.Revset = stackRevset(item.Roots)
+		m.phase = PhaseSubmitting
+		m.submit = submit.NewModel(m.ctx, m.jj, m.gh, opts)
+		if m.width > 0 {
+			next, _ := m.submit.Update(tea.WindowSizeMsg{Width: m.width})
+			m.submit = next.(submit.Model)
+		}
+		return m, m.submit.Init()
+	}
+
+	m.phase = PhaseComplete
+	if len(m.bookmarks) == 0 {
+		m.phase = PhaseUpToDate
+	}
+	return m, tea.Quit
+}
+
+// updateSubmit hands msg to the submit of the current stack, moving on to the
+// next stack once it is done
+func (m Model) updateSubmit(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && key.Matches(msg, m.keys.Quit) {
+		return m, tea.Quit
+	}
+
+	next, cmd := m.submit.Update(msg)
+	m.submit = next.(submit.Model)
+
+	// Interactively the plan of each stack waits for the user to submit it
+	var confirmCmd tea.Cmd
+	if m.headless {
+		m.submit, confirmCmd = m.submit.Confirm(false)
+	}
+
+	item := &m.bookmarks[m.submitIndex]
+	switch m.submit.Phase() {
+	case submit.PhaseComplete, submit.PhaseUpToDate:
+		// Both end with tea.Quit, which would end the whole session
+		item.PullRequests = m.submit.Report().Revisions
+		m.submitIndex++
+		return m.nextSubmit()
+
+	case submit.PhaseError:
+		item.PullRequests = m.submit.Report().Revisions
+		item.State = StateError
+		item.Error = fmt.Errorf("submit: %w", m.submit.Err())
+		m.phase = PhaseError
+		m.err = item.Error
+		return m, tea.Quit
+	}
+
+	return m, tea.Batch(cmd, confirmCmd)
+}
+
+// stackRevset selects the stack above the given roots
+func stackRevset(roots []string) string {
+	revsets := make([]string, len(roots))
+	for i, root := range roots {
+		revsets[i] = fmt.Sprintf("change_id(%s)", root)
+	}
+	return fmt.Sprintf("(%s)::", strings.Join(revsets, " | "))
+}
+
+// submittedSummary names the PRs of a resubmitted stack, e.g. "submitted #3,
+// #4", or returns "" if it has none
+func submittedSummary(prs []report.SubmitRevision) string {
+	var refs []string
+	for _, pr := range prs {
+		if pr.PRNumber != 0 {
+			refs = append(refs, fmt.Sprintf("#%d", pr.PRNumber))
+		}
+	}
+	if len(refs) == 0 {
+		return ""
+	}
+	return "submitted " + strings.Join(refs, ", ")
+}
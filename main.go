@@ -2,16 +2,24 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
 	"github.com/urfave/cli/v2"
 
+	"github.com/cbrewster/jj-github/internal/config"
 	"github.com/cbrewster/jj-github/internal/github"
 	"github.com/cbrewster/jj-github/internal/jj"
+	"github.com/cbrewster/jj-github/internal/prmap"
+	"github.com/cbrewster/jj-github/internal/report"
+	"github.com/cbrewster/jj-github/internal/tui/cleanup"
+	"github.com/cbrewster/jj-github/internal/tui/land"
+	"github.com/cbrewster/jj-github/internal/tui/status"
 	"github.com/cbrewster/jj-github/internal/tui/submit"
 	"github.com/cbrewster/jj-github/internal/tui/sync"
 )
@@ -20,24 +28,207 @@ func main() {
 	app := &cli.App{
 		Name:  "jj-github",
 		Usage: "Manage stacked pull requests with Jujutsu and GitHub",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:    "github-host",
+				Usage:   "GitHub Enterprise host to accept in remote URLs (github.com is always accepted)",
+				EnvVars: []string{"JJ_GITHUB_HOSTS"},
+			},
+		},
 		Commands: []*cli.Command{
 			{
 				Name:  "sync",
 				Usage: "Fetch from remote and rebase bookmarks onto updated trunk",
+				Flags: []cli.Flag{
+					outputFlag(),
+					&cli.BoolFlag{
+						Name:  "submit",
+						Usage: "Resubmit each rebased stack that has pull requests",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "Resubmit without confirmation and print plain progress output",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					output, err := parseOutputFormat(c.String("output"))
+					if err != nil {
+						return err
+					}
+					yes := c.Bool("yes") || output == outputJSON
+					if c.Bool("submit") {
+						if err := requireConfirmation("sync --submit", yes); err != nil {
+							return err
+						}
+					}
+					return runSync(c.Context, syncOptions{
+						headless: yes,
+						output:   output,
+						hosts:    c.StringSlice("github-host"),
+						submit:   c.Bool("submit"),
+					})
+				},
+			},
+			{
+				Name:      "status",
+				Usage:     "Show the stack with the state, reviews and checks of each pull request",
+				ArgsUsage: "[revset]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "push-remote",
+						Usage: "Remote branches are pushed to (default: jj-github.push-remote, git.push or origin)",
+					},
+					&cli.StringFlag{
+						Name:  "pr-remote",
+						Usage: "Remote of the repository pull requests are opened against (default: the push remote)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return runStatus(c.Context, c.Args().First(), remoteOptions{
+						hosts:      c.StringSlice("github-host"),
+						pushRemote: c.String("push-remote"),
+						prRemote:   c.String("pr-remote"),
+					})
+				},
+			},
+			{
+				Name:      "land",
+				Usage:     "Merge the ready pull requests at the bottom of the stack and rebase the rest onto trunk",
+				ArgsUsage: "[revset]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "Land without confirmation and print plain progress output",
+					},
+					&cli.StringFlag{
+						Name:  "method",
+						Usage: "Merge method: merge, squash or rebase (default: jj-github.merge-method or squash)",
+					},
+					&cli.IntFlag{
+						Name:  "count",
+						Usage: "Merge at most this many pull requests (default: every ready one)",
+					},
+					&cli.StringFlag{
+						Name:  "push-remote",
+						Usage: "Remote branches are pushed to (default: jj-github.push-remote, git.push or origin)",
+					},
+					&cli.StringFlag{
+						Name:  "pr-remote",
+						Usage: "Remote of the repository pull requests are opened against (default: the push remote)",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					return runSync(c.Context)
+					if err := requireConfirmation("land", c.Bool("yes")); err != nil {
+						return err
+					}
+					return runLand(c.Context, c.Args().First(), landOptions{
+						headless: c.Bool("yes"),
+						method:   c.String("method"),
+						count:    c.Int("count"),
+						remotes: remoteOptions{
+							hosts:      c.StringSlice("github-host"),
+							pushRemote: c.String("push-remote"),
+							prRemote:   c.String("pr-remote"),
+						},
+					})
+				},
+			},
+			{
+				Name:  "cleanup",
+				Usage: "Close the pull requests of revisions that were abandoned or squashed away",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "Close without confirmation and print plain progress output",
+					},
+					&cli.StringFlag{
+						Name:  "push-remote",
+						Usage: "Remote branches are pushed to (default: jj-github.push-remote, git.push or origin)",
+					},
+					&cli.StringFlag{
+						Name:  "pr-remote",
+						Usage: "Remote of the repository pull requests are opened against (default: the push remote)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := requireConfirmation("cleanup", c.Bool("yes")); err != nil {
+						return err
+					}
+					return runCleanup(c.Context, cleanupOptions{
+						headless: c.Bool("yes"),
+						remotes: remoteOptions{
+							hosts:      c.StringSlice("github-host"),
+							pushRemote: c.String("push-remote"),
+							prRemote:   c.String("pr-remote"),
+						},
+					})
 				},
 			},
 			{
 				Name:      "submit",
 				Usage:     "Submit revisions as pull requests to GitHub",
 				ArgsUsage: "[revset]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "Submit without confirmation and print plain progress output",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Show the pushes and pull request changes that would be made without making them",
+					},
+					&cli.StringFlag{
+						Name:  "push-remote",
+						Usage: "Remote to push branches to, e.g. your fork (default: jj-github.push-remote, git.push or origin)",
+					},
+					&cli.StringFlag{
+						Name:  "pr-remote",
+						Usage: "Remote of the repository to open pull requests against (default: the push remote)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "reviewer",
+						Usage: "Request a review from a user or an org/team, in addition to jj-github.reviewers",
+					},
+					&cli.StringSliceFlag{
+						Name:  "assignee",
+						Usage: "Assign a user, in addition to jj-github.assignees",
+					},
+					&cli.StringSliceFlag{
+						Name:  "label",
+						Usage: "Add a label, in addition to jj-github.labels",
+					},
+					&cli.StringFlag{
+						Name:  "template",
+						Usage: "Pull request template to use from a PULL_REQUEST_TEMPLATE directory (default: jj-github.pr-template)",
+					},
+					outputFlag(),
+				},
 				Action: func(c *cli.Context) error {
-					revset := "@"
-					if c.Args().First() != "" {
-						revset = c.Args().First()
+					output, err := parseOutputFormat(c.String("output"))
+					if err != nil {
+						return err
 					}
-					return runSubmit(c.Context, revset)
+					if c.Bool("dry-run") && output == outputJSON {
+						return errors.New("--dry-run can't be combined with --output json")
+					}
+					return runSubmit(c.Context, c.Args().First(), submitOptions{
+						headless:  c.Bool("yes") || !isatty.IsTerminal(os.Stdout.Fd()),
+						dryRun:    c.Bool("dry-run"),
+						output:    output,
+						reviewers: c.StringSlice("reviewer"),
+						assignees: c.StringSlice("assignee"),
+						labels:    c.StringSlice("label"),
+						template:  c.String("template"),
+						remotes: remoteOptions{
+							hosts:      c.StringSlice("github-host"),
+							pushRemote: c.String("push-remote"),
+							prRemote:   c.String("pr-remote"),
+						},
+					})
 				},
 			},
 		},
@@ -49,37 +240,384 @@ func main() {
 	}
 }
 
-func runSync(ctx context.Context) error {
+// outputFormat selects how command results are written to stdout
+type outputFormat string
+
+const (
+	outputText outputFormat = "text"
+	outputJSON outputFormat = "json"
+)
+
+func outputFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "output",
+		Value: string(outputText),
+		Usage: "Output format: text or json (json implies --yes)",
+	}
+}
+
+func parseOutputFormat(value string) (outputFormat, error) {
+	switch format := outputFormat(value); format {
+	case outputText, outputJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q", value)
+	}
+}
+
+// requireConfirmation refuses to run a destructive command without a terminal
+// to confirm on, unless confirmation was skipped with --yes
+func requireConfirmation(command string, yes bool) error {
+	if yes || isatty.IsTerminal(os.Stdout.Fd()) {
+		return nil
+	}
+	return fmt.Errorf("%s needs a terminal to ask for confirmation; pass --yes to run it without one", command)
+}
+
+type syncOptions struct {
+	headless bool
+	output   outputFormat
+	hosts    []string
+	submit   bool
+}
+
+func runSync(ctx context.Context, opts syncOptions) error {
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	model := sync.NewModel(ctx)
+	jjClient := jj.NewClient()
+	cfg, err := loadConfig(jjClient, opts.hosts)
+	if err != nil {
+		return err
+	}
+
+	model := sync.NewModel(ctx, jjClient, cfg)
+
+	// GitHub tells which PRs were merged, but sync still works from the
+	// rebase alone without it, e.g. when no token is set up
+	setup, err := resolveGitHub(jjClient, cfg, remoteOptions{})
+	switch {
+	case err == nil:
+		model = model.WithGitHub(setup.gh, setup.repo, setup.headRepo)
+	case opts.submit:
+		return err
+	default:
+		fmt.Fprintf(os.Stderr, "Not checking GitHub for merged pull requests: %v\n", err)
+	}
+
+	if opts.submit {
+		prs, err := loadPRMap(jjClient)
+		if err != nil {
+			return err
+		}
+		model = model.WithSubmit(submit.Options{
+			Repo:     setup.repo,
+			HeadRepo: setup.headRepo,
+			Config:   setup.cfg,
+			PRs:      prs,
+		})
+	}
+
+	if opts.output == outputJSON {
+		final, err := sync.RunHeadless(model, os.Stderr)
+		if writeErr := report.Write(os.Stdout, final.Report()); writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+	if opts.headless {
+		_, err := sync.RunHeadless(model, os.Stdout)
+		return err
+	}
+
 	p := tea.NewProgram(model)
-	_, err := p.Run()
+	_, err = p.Run()
 	return err
 }
 
-func runSubmit(ctx context.Context, revset string) error {
+// loadPRMap reads the pull requests recorded for the changes of the workspace
+func loadPRMap(jjClient *jj.Client) (*prmap.Store, error) {
+	root, err := jjClient.Root()
+	if err != nil {
+		return nil, err
+	}
+	prs, err := prmap.Load(prmap.Path(root))
+	if err != nil {
+		return nil, fmt.Errorf("loading pull request map: %w", err)
+	}
+	return prs, nil
+}
+
+// loadConfig reads the jj-github config, adding hosts given on the command line
+func loadConfig(jjClient *jj.Client, hosts []string) (config.Config, error) {
+	cfg, err := config.Load(jjClient)
+	if err != nil {
+		return config.Config{}, fmt.Errorf("loading config: %w", err)
+	}
+	cfg.Hosts = append(cfg.Hosts, hosts...)
+	return cfg, nil
+}
+
+// remoteOptions selects the GitHub hosts and jj remotes to work with,
+// overriding the config
+type remoteOptions struct {
+	hosts      []string
+	pushRemote string
+	prRemote   string
+}
+
+// submitOptions controls how the submit workflow is run
+type submitOptions struct {
+	headless  bool
+	dryRun    bool
+	output    outputFormat
+	reviewers []string
+	assignees []string
+	labels    []string
+	template  string
+	remotes   remoteOptions
+}
+
+// githubSetup is everything needed to talk to the GitHub repositories behind
+// the configured remotes
+type githubSetup struct {
+	cfg      config.Config
+	repo     github.Repo
+	headRepo github.Repo
+	gh       *github.Client
+}
+
+// setupGitHub loads the config, applies the flags on top of it and resolves the
+// push and PR remotes to GitHub repositories
+func setupGitHub(jjClient *jj.Client, opts remoteOptions) (githubSetup, error) {
+	cfg, err := loadConfig(jjClient, opts.hosts)
+	if err != nil {
+		return githubSetup{}, err
+	}
+	return resolveGitHub(jjClient, cfg, opts)
+}
+
+// resolveGitHub is setupGitHub for a config that is already loaded, including
+// the hosts from the flags
+func resolveGitHub(jjClient *jj.Client, cfg config.Config, opts remoteOptions) (githubSetup, error) {
+	// Flags take precedence over the config
+	if opts.pushRemote != "" {
+		cfg.PushRemote = opts.pushRemote
+	}
+	if opts.prRemote != "" {
+		cfg.PRRemote = opts.prRemote
+	}
+	prRemote := cfg.PRRemoteOrDefault()
+
+	headRepo, err := repoForRemote(jjClient, cfg.PushRemoteOrDefault(), cfg.Hosts)
+	if err != nil {
+		return githubSetup{}, err
+	}
+
+	repo, err := repoForRemote(jjClient, prRemote, cfg.Hosts)
+	if err != nil {
+		return githubSetup{}, err
+	}
+	if headRepo.Host != repo.Host {
+		return githubSetup{}, fmt.Errorf("push remote %q and PR remote %q are on different hosts", cfg.PushRemoteOrDefault(), prRemote)
+	}
+
+	gh, err := github.NewClient(repo.Host)
+	if err != nil {
+		return githubSetup{}, fmt.Errorf("creating GitHub client: %w", err)
+	}
+
+	return githubSetup{
+		cfg:      cfg,
+		repo:     repo,
+		headRepo: headRepo,
+		gh:       gh.WithConcurrency(cfg.Concurrency),
+	}, nil
+}
+
+func runStatus(ctx context.Context, revset string, opts remoteOptions) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	jjClient := jj.NewClient()
+	setup, err := setupGitHub(jjClient, opts)
+	if err != nil {
+		return err
+	}
+	if revset == "" {
+		revset = setup.cfg.DefaultRevset
+	}
+
+	prs, err := loadPRMap(jjClient)
+	if err != nil {
+		return err
+	}
+
+	model := status.NewModel(ctx, jjClient, setup.gh, status.Options{
+		Repo:     setup.repo,
+		HeadRepo: setup.headRepo,
+		Revset:   revset,
+		Config:   setup.cfg,
+		PRs:      prs.ReadOnly(),
+	})
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		_, err := status.RunHeadless(model, os.Stdout)
+		return err
+	}
+
+	p := tea.NewProgram(model)
+	_, err = p.Run()
+	return err
+}
+
+// landOptions controls how the land workflow is run
+type landOptions struct {
+	headless bool
+	method   string
+	count    int
+	remotes  remoteOptions
+}
+
+func runLand(ctx context.Context, revset string, opts landOptions) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	jjClient := jj.NewClient()
+	setup, err := setupGitHub(jjClient, opts.remotes)
+	if err != nil {
+		return err
+	}
+	if revset == "" {
+		revset = setup.cfg.DefaultRevset
+	}
+	if opts.method != "" {
+		if setup.cfg.MergeMethod, err = github.ParseMergeMethod(opts.method); err != nil {
+			return err
+		}
+	}
+
+	prs, err := loadPRMap(jjClient)
+	if err != nil {
+		return err
+	}
+
+	model := land.NewModel(ctx, jjClient, setup.gh, land.Options{
+		Repo:     setup.repo,
+		HeadRepo: setup.headRepo,
+		Revset:   revset,
+		Limit:    opts.count,
+		Config:   setup.cfg,
+		PRs:      prs,
+	})
+	if opts.headless {
+		_, err := land.RunHeadless(model, os.Stdout)
+		return err
+	}
+
+	p := tea.NewProgram(model)
+	_, err = p.Run()
+	return err
+}
+
+// cleanupOptions controls how the cleanup workflow is run
+type cleanupOptions struct {
+	headless bool
+	remotes  remoteOptions
+}
+
+func runCleanup(ctx context.Context, opts cleanupOptions) error {
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	gh, err := github.NewClient()
+	jjClient := jj.NewClient()
+	setup, err := setupGitHub(jjClient, opts.remotes)
 	if err != nil {
-		return fmt.Errorf("creating GitHub client: %w", err)
+		return err
 	}
 
-	remote, err := jj.GetRemote("origin")
+	prs, err := loadPRMap(jjClient)
+	if err != nil {
+		return err
+	}
+
+	model := cleanup.NewModel(ctx, jjClient, setup.gh, cleanup.Options{
+		Repo:     setup.repo,
+		HeadRepo: setup.headRepo,
+		Config:   setup.cfg,
+		PRs:      prs,
+	})
+	if opts.headless {
+		_, err := cleanup.RunHeadless(model, os.Stdout)
+		return err
+	}
+
+	p := tea.NewProgram(model)
+	_, err = p.Run()
+	return err
+}
+
+func runSubmit(ctx context.Context, revset string, opts submitOptions) error {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	jjClient := jj.NewClient()
+	setup, err := setupGitHub(jjClient, opts.remotes)
 	if err != nil {
-		return fmt.Errorf("getting remote: %w", err)
+		return err
+	}
+	if revset == "" {
+		revset = setup.cfg.DefaultRevset
+	}
+	setup.cfg.Reviewers = append(setup.cfg.Reviewers, opts.reviewers...)
+	setup.cfg.Assignees = append(setup.cfg.Assignees, opts.assignees...)
+	setup.cfg.Labels = append(setup.cfg.Labels, opts.labels...)
+	if opts.template != "" {
+		setup.cfg.Template = opts.template
 	}
 
-	repo, err := github.GetRepoFromRemote(remote)
+	prs, err := loadPRMap(jjClient)
 	if err != nil {
-		return fmt.Errorf("parsing remote: %w", err)
+		return err
+	}
+
+	model := submit.NewModel(ctx, jjClient, setup.gh, submit.Options{
+		Repo:     setup.repo,
+		HeadRepo: setup.headRepo,
+		Revset:   revset,
+		Config:   setup.cfg,
+		PRs:      prs,
+	})
+	if opts.dryRun {
+		return submit.RunDryRun(model, os.Stdout)
+	}
+	if opts.output == outputJSON {
+		final, err := submit.RunHeadless(model, os.Stderr)
+		if writeErr := report.Write(os.Stdout, final.Report()); writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+	if opts.headless {
+		_, err := submit.RunHeadless(model, os.Stdout)
+		return err
 	}
 
-	model := submit.NewModel(ctx, gh, repo, revset)
 	p := tea.NewProgram(model)
 	_, err = p.Run()
 	return err
 }
+
+// repoForRemote resolves the GitHub repository behind a jj remote
+func repoForRemote(jjClient *jj.Client, name string, hosts []string) (github.Repo, error) {
+	remote, err := jjClient.GetRemote(name)
+	if err != nil {
+		return github.Repo{}, fmt.Errorf("getting remote: %w", err)
+	}
+
+	repo, err := github.GetRepoFromRemote(remote, hosts...)
+	if err != nil {
+		return github.Repo{}, fmt.Errorf("parsing remote %q: %w", name, err)
+	}
+
+	return repo, nil
+}